@@ -9,15 +9,53 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/axseem/peakstreak/internal/api"
 	"github.com/axseem/peakstreak/internal/config"
 	"github.com/axseem/peakstreak/internal/repository"
 	"github.com/axseem/peakstreak/internal/service"
 	"github.com/axseem/peakstreak/internal/storage"
+	"github.com/axseem/peakstreak/internal/templates"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// trashPurgeInterval is how often expired habits are purged from the trash.
+const trashPurgeInterval = time.Hour
+
+// purgeTrash periodically removes habits whose trash retention has expired, until
+// ctx is cancelled.
+func purgeTrash(ctx context.Context, appService *service.Service, retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := appService.PurgeDeletedHabits(ctx, retention)
+		if err != nil {
+			slog.Error("failed to purge deleted habits", "error", err)
+		} else if purged > 0 {
+			slog.Info("purged deleted habits", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backfillAchievements awards every user the achievements their existing data earns.
+// It is safe to run repeatedly.
+func backfillAchievements(appService *service.Service) error {
+	awarded, err := appService.BackfillAchievements(context.Background())
+	if err != nil {
+		return err
+	}
+	slog.Info("backfilled achievements", "awarded", awarded)
+	return nil
+}
+
 func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
@@ -39,6 +77,25 @@ func main() {
 	avatarStoragePath := "./uploads/avatars"
 	fileStorage := storage.NewLocalStorage(avatarStoragePath, "/uploads/avatars")
 	appService := service.New(postgresRepo, fileStorage)
+
+	if len(os.Args) > 1 && os.Args[1] == "backfill-achievements" {
+		if err := backfillAchievements(appService); err != nil {
+			slog.Error("failed to backfill achievements", "error", err)
+			dbpool.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	catalogue, err := templates.Load(cfg.HabitTemplatesFile)
+	if err == nil {
+		err = appService.SetTemplateCatalogue(catalogue)
+	}
+	if err != nil {
+		slog.Error("cannot load habit templates", "file", cfg.HabitTemplatesFile, "error", err)
+		os.Exit(1)
+	}
+
 	apiHandler := api.NewAPIHandler(appService, &cfg)
 	router := api.NewRouter(apiHandler)
 
@@ -49,6 +106,8 @@ func main() {
 
 	serverCtx, serverStopCtx := context.WithCancel(context.Background())
 
+	go purgeTrash(serverCtx, appService, cfg.HabitTrashRetention)
+
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	go func() {
@@ -1,12 +1,64 @@
+export const TOKEN_KEY = "peakstreak_token";
+export const REFRESH_TOKEN_KEY = "peakstreak_refresh_token";
+
+// refreshing is the token refresh in flight, shared by requests failing meanwhile.
+let refreshing: Promise<string | null> | null = null;
+
+// refreshAccessToken exchanges the stored refresh token for new tokens, resolving to
+// the new access token, or null if the session has ended.
+const refreshAccessToken = (): Promise<string | null> => {
+  if (refreshing) return refreshing;
+  const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+  if (!refreshToken) return Promise.resolve(null);
+
+  refreshing = fetch("/api/auth/refresh", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ refreshToken }),
+  })
+    .then(async (res) => {
+      if (!res.ok) return null;
+      const data = await res.json();
+      localStorage.setItem(TOKEN_KEY, data.token);
+      localStorage.setItem(REFRESH_TOKEN_KEY, data.refreshToken);
+      return data.token as string;
+    })
+    .catch(() => null)
+    .finally(() => {
+      refreshing = null;
+    });
+  return refreshing;
+};
+
+// send makes a request, refreshing the access token and retrying once if it expired.
+const send = async (
+  path: string,
+  init: RequestInit & { headers?: Record<string, string> },
+  token: string | null,
+): Promise<Response> => {
+  const withToken = (t: string | null): RequestInit =>
+    t ? { ...init, headers: { ...init.headers, Authorization: `Bearer ${t}` } } : init;
+
+  // After a refresh, the stored token is newer than the one the caller holds.
+  const current = token ? (localStorage.getItem(TOKEN_KEY) ?? token) : null;
+  const res = await fetch(path, withToken(current));
+  if (res.status !== 401 || !current) return res;
+
+  const refreshed = await refreshAccessToken();
+  return refreshed ? fetch(path, withToken(refreshed)) : res;
+};
+
 export const api = {
   async post(path: string, body: any, token: string | null = null) {
-    const headers: HeadersInit = { "Content-Type": "application/json" };
-    if (token) headers["Authorization"] = `Bearer ${token}`;
-    const res = await fetch(path, {
-      method: "POST",
-      headers,
-      body: JSON.stringify(body),
-    });
+    const res = await send(
+      path,
+      {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify(body),
+      },
+      token,
+    );
     if (!res.ok) {
       const data = await res.json().catch(() => ({}));
       throw new Error(data.error || `Request failed with status ${res.status}`);
@@ -14,21 +66,21 @@ export const api = {
     return res.status === 204 ? null : await res.json();
   },
   async get(path: string, token: string | null) {
-    const headers: HeadersInit = {};
-    if (token) headers["Authorization"] = `Bearer ${token}`;
-    const res = await fetch(path, { headers });
+    const res = await send(path, {}, token);
     const data = await res.json();
     if (!res.ok) throw new Error(data.error || "An unknown error occurred");
     return data;
   },
   async put(path: string, body: any, token: string | null) {
-    const headers: HeadersInit = { "Content-Type": "application/json" };
-    if (token) headers["Authorization"] = `Bearer ${token}`;
-    const res = await fetch(path, {
-      method: "PUT",
-      headers,
-      body: JSON.stringify(body),
-    });
+    const res = await send(
+      path,
+      {
+        method: "PUT",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify(body),
+      },
+      token,
+    );
     if (!res.ok) {
       const data = await res.json().catch(() => ({}));
       throw new Error(data.error || `Request failed with status ${res.status}`);
@@ -36,9 +88,7 @@ export const api = {
     return res.status === 204 ? null : await res.json();
   },
   async delete(path: string, token: string | null) {
-    const headers: HeadersInit = {};
-    if (token) headers["Authorization"] = `Bearer ${token}`;
-    const res = await fetch(path, { method: "DELETE", headers });
+    const res = await send(path, { method: "DELETE" }, token);
     if (!res.ok) {
       const data = await res.json().catch(() => ({}));
       throw new Error(data.error || `Request failed with status ${res.status}`);
@@ -46,9 +96,7 @@ export const api = {
     return res.status === 204 ? null : await res.json();
   },
   async upload(path: string, formData: FormData, token: string | null) {
-    const headers: HeadersInit = {};
-    if (token) headers["Authorization"] = `Bearer ${token}`;
-    const res = await fetch(path, { method: "POST", headers, body: formData });
+    const res = await send(path, { method: "POST", body: formData }, token);
     if (!res.ok) {
       const data = await res.json().catch(() => ({}));
       throw new Error(data.error || `Request failed with status ${res.status}`);
@@ -505,7 +505,8 @@ export const HabitCard = ({
   const todayLog = habit.logs.find(
     (log) => toYYYYMMDD(new Date(log.date)) === todayStr,
   );
-  const wasLoggedToday = !!todayLog && todayLog.value > 0;
+  const wasLoggedToday =
+    !!todayLog && (todayLog.value > 0 || todayLog.completed);
   const todayValue = todayLog?.value ?? 0;
 
   const logsByYear = groupLogsByYear(habit.logs);
@@ -1,4 +1,4 @@
-import { api } from "./api";
+import { api, TOKEN_KEY, REFRESH_TOKEN_KEY } from "./api";
 import type {
   State,
   User,
@@ -15,7 +15,7 @@ import { path_to_view, NavigateFx } from "./router";
 import { toYYYYMMDD } from "./lib/date";
 
 const savedUser = localStorage.getItem("peakstreak_user");
-const savedToken = localStorage.getItem("peakstreak_token");
+const savedToken = localStorage.getItem(TOKEN_KEY);
 
 const { view: initialView } = path_to_view(window.location.pathname);
 
@@ -163,10 +163,15 @@ export const HandleIsBooleanInput = (state: State, event: Event): State => ({
 
 export const SetAuth = (
   state: State,
-  { user, token }: { user: User; token: string },
+  {
+    user,
+    token,
+    refreshToken,
+  }: { user: User; token: string; refreshToken: string },
 ): [State, any] => {
   localStorage.setItem("peakstreak_user", JSON.stringify(user));
-  localStorage.setItem("peakstreak_token", token);
+  localStorage.setItem(TOKEN_KEY, token);
+  localStorage.setItem(REFRESH_TOKEN_KEY, refreshToken);
   const newState = { ...state, user, token, isLoading: false, error: null };
   return [
     newState,
@@ -238,10 +243,13 @@ export const UpdateHabitLog = (
         const existingLogIndex = h.logs.findIndex(
           (l) => toYYYYMMDD(new Date(l.date)) === logDate,
         );
+        // A zero is kept when it has a note or stays within an "at most" target.
+        const kept =
+          log.value > 0 || log.status !== "done" || log.completed || !!log.note;
         let newLogs;
 
         if (existingLogIndex > -1) {
-          if (log.value > 0) {
+          if (kept) {
             newLogs = [...h.logs];
             newLogs[existingLogIndex] = log;
           } else {
@@ -249,7 +257,7 @@ export const UpdateHabitLog = (
               (l) => toYYYYMMDD(new Date(l.date)) !== logDate,
             );
           }
-        } else if (log.value > 0) {
+        } else if (kept) {
           newLogs = [...h.logs, log];
         } else {
           newLogs = h.logs;
@@ -301,8 +309,14 @@ export const HabitDeleted = (state: State, habitId: string): State => {
 };
 
 export const Logout = (_state: State): [State, any] => {
+  const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+  if (refreshToken) {
+    // Ending the session server-side is best effort; the tokens are dropped anyway.
+    api.post("/api/auth/logout", { refreshToken }).catch(() => {});
+  }
   localStorage.removeItem("peakstreak_user");
-  localStorage.removeItem("peakstreak_token");
+  localStorage.removeItem(TOKEN_KEY);
+  localStorage.removeItem(REFRESH_TOKEN_KEY);
   const newState: State = {
     ...initialState,
     view: "login",
@@ -534,7 +548,12 @@ export const LoginFx = (dispatch: any, { identifier, password }: any) => {
 export const SignUpFx = (dispatch: any, { username, email, password }: any) => {
   dispatch(SetLoading, true);
   api
-    .post("/api/auth/signup", { username, email, password })
+    .post("/api/auth/signup", {
+      username,
+      email,
+      password,
+      timezone: Intl.DateTimeFormat().resolvedOptions().timeZone,
+    })
     .then(() => {
       const GoToLogin = (state: State): [State, any] => [
         state,
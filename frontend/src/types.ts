@@ -3,6 +3,7 @@ export type User = {
   username: string;
   email: string;
   avatarUrl?: string;
+  timezone: string;
 };
 
 export type PublicUser = {
@@ -11,24 +12,143 @@ export type PublicUser = {
   avatarUrl?: string;
 };
 
+export type Schedule = {
+  frequency: "daily" | "weekdays" | "weekly" | "monthly";
+  weekdays?: number[];
+  timesPerPeriod?: number;
+};
+
+export type Target = {
+  value?: number;
+  mode: "at_least" | "at_most" | "exactly";
+};
+
+export type HabitPolarity = "positive" | "negative";
+
 export type Habit = {
   id: string;
   userId: string;
   name: string;
   colorHue: number;
   isBoolean: boolean;
+  polarity: HabitPolarity;
+  schedule: Schedule;
+  target: Target;
+  unit?: string;
+  position: number;
+  pinned: boolean;
+  category?: HabitCategory;
+  tags?: Tag[];
+  sourceHabitId?: string;
+  adoptions?: number;
+  createdAt: string;
+  archivedAt?: string;
+  deletedAt?: string;
+};
+
+export type HabitCategory =
+  | "fitness"
+  | "health"
+  | "learning"
+  | "mindfulness"
+  | "productivity"
+  | "creativity"
+  | "finance"
+  | "social";
+
+export type Tag = {
+  id: string;
+  userId: string;
+  name: string;
+  colorHue: number;
   createdAt: string;
 };
 
+export type LogStatus = "done" | "skipped" | "failed";
+
 export type HabitLog = {
   id: string;
   habitId: string;
   date: string;
   value: number;
+  status: LogStatus;
+  note?: string;
+  notePrivate: boolean;
+  completion: number;
+  completed: boolean;
+};
+
+export type HabitLogPage = {
+  logs: HabitLog[];
+  nextCursor?: string;
+};
+
+export type LogBatchResult = {
+  index: number;
+  date: string;
+  outcome: "created" | "updated" | "cleared" | "unchanged" | "invalid";
+  error?: string;
+};
+
+export type ImportedHabit = {
+  name: string;
+  habitId?: string;
+  created: boolean;
+  isBoolean: boolean;
+  colorHue: number;
+  logs: number;
+  newLogs: number;
+  skipped: boolean;
+  from?: string;
+  to?: string;
+};
+
+export type ImportSummary = {
+  format: "loop" | "csv";
+  dryRun: boolean;
+  habits: ImportedHabit[];
+};
+
+export type CalendarFeed = {
+  token: string;
+  feedUrl: string;
+};
+
+export type HabitTemplate = {
+  id: string;
+  name: string;
+  description?: string;
+  colorHue: number;
+  isBoolean: boolean;
+  polarity?: HabitPolarity;
+  schedule: Schedule;
+  target: Target;
+  unit?: string;
+  category?: HabitCategory;
+  author?: PublicUser;
+  createdAt?: string;
+};
+
+export type HabitConversionPreview = {
+  isBoolean: boolean;
+  target: Target;
+  logs: number;
+  changedLogs: number;
+  completedBefore: number;
+  completedAfter: number;
+};
+
+export type Streak = {
+  current: number;
+  longest: number;
+  lastCompletedOn?: string;
+  lastRelapseOn?: string;
+  startedOn?: string;
 };
 
 export type HabitWithLogs = Habit & {
   logs: HabitLog[];
+  streak: Streak;
 };
 
 export type EditingHabitState = {
@@ -37,6 +157,23 @@ export type EditingHabitState = {
   colorHue: number;
 };
 
+export type Achievement = {
+  id: string;
+  name: string;
+  description: string;
+  earnedAt: string;
+};
+
+export type Session = {
+  id: string;
+  device: string;
+  ip: string;
+  createdAt: string;
+  lastSeenAt: string;
+  expiresAt: string;
+  current: boolean;
+};
+
 export type ProfileData = {
   user: User;
   habits: HabitWithLogs[];
@@ -44,6 +181,7 @@ export type ProfileData = {
   followersCount: number;
   followingCount: number;
   isFollowing: boolean;
+  achievements: Achievement[];
 };
 
 export type FollowerListState = {
@@ -2,12 +2,15 @@ package api
 
 import (
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/axseem/peakstreak/internal/config"
 	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/importer"
 	"github.com/axseem/peakstreak/internal/repository"
 	"github.com/axseem/peakstreak/internal/service"
 	"github.com/go-chi/chi/v5"
@@ -33,6 +36,7 @@ type SignUpRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50,alphanum"`
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=8,printascii"`
+	Timezone string `json:"timezone" validate:"omitempty,max=64"`
 }
 
 func (h *APIHandler) SignUp(w http.ResponseWriter, r *http.Request) {
@@ -50,6 +54,7 @@ func (h *APIHandler) SignUp(w http.ResponseWriter, r *http.Request) {
 		Username: req.Username,
 		Email:    req.Email,
 		Password: req.Password,
+		Timezone: req.Timezone,
 	}
 
 	user, err := h.service.CreateUser(r.Context(), params)
@@ -58,6 +63,10 @@ func (h *APIHandler) SignUp(w http.ResponseWriter, r *http.Request) {
 			errorResponse(w, http.StatusConflict, err.Error())
 			return
 		}
+		if errors.Is(err, service.ErrInvalidTimezone) {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		errorResponse(w, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
@@ -71,8 +80,17 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  *domain.User `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refreshToken"`
+	User         *domain.User `json:"user"`
+}
+
+func (h *APIHandler) tokenConfig() service.TokenConfig {
+	return service.TokenConfig{
+		Secret:           h.cfg.JWTSecret,
+		AccessExpiresIn:  h.cfg.JWTExpiresIn,
+		RefreshExpiresIn: h.cfg.RefreshTokenExpiresIn,
+	}
 }
 
 func (h *APIHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -89,9 +107,10 @@ func (h *APIHandler) Login(w http.ResponseWriter, r *http.Request) {
 	params := service.LoginUserParams{
 		Identifier: req.Identifier,
 		Password:   req.Password,
+		Client:     sessionClient(r),
 	}
 
-	user, token, err := h.service.LoginUser(r.Context(), params, h.cfg.JWTSecret, h.cfg.JWTExpiresIn)
+	user, tokens, err := h.service.LoginUser(r.Context(), params, h.tokenConfig())
 	if err != nil {
 		if errors.Is(err, service.ErrInvalidCredentials) {
 			errorResponse(w, http.StatusUnauthorized, "Invalid credentials")
@@ -103,23 +122,146 @@ func (h *APIHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := LoginResponse{
-		Token: token,
-		User:  user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		User:         user,
 	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type RefreshTokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+func (h *APIHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+	if err := readJSON(r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		validationErrorResponse(w, err)
+		return
+	}
+
+	tokens, err := h.service.RefreshSession(r.Context(), req.RefreshToken, sessionClient(r), h.tokenConfig())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRefreshToken), errors.Is(err, service.ErrRefreshTokenReused):
+			errorResponse(w, http.StatusUnauthorized, err.Error())
+		default:
+			slog.Error("failed to refresh session", "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to refresh session")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RefreshTokenResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken})
+}
+
+func (h *APIHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+	if err := readJSON(r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		validationErrorResponse(w, err)
+		return
+	}
+
+	if err := h.service.Logout(r.Context(), req.RefreshToken); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRefreshToken), errors.Is(err, service.ErrRefreshTokenReused):
+			errorResponse(w, http.StatusUnauthorized, err.Error())
+		default:
+			slog.Error("failed to logout", "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to logout")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *APIHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	sessions, err := h.service.GetSessions(r.Context(), userID, getSessionIDFromContext(r.Context()))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Could not retrieve sessions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (h *APIHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid session ID format")
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	if err := h.service.RevokeSession(r.Context(), sessionID, userID); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrSessionNotFound):
+			errorResponse(w, http.StatusNotFound, "Session not found")
+		default:
+			errorResponse(w, http.StatusInternalServerError, "Failed to revoke session")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *APIHandler) GetProfilePageData(w http.ResponseWriter, r *http.Request) {
 	username := chi.URLParam(r, "username")
 	authenticatedUserID, _ := getUserIDFromContext(r.Context())
 
-	profileData, err := h.service.GetProfileData(r.Context(), username, authenticatedUserID)
+	from, err := parseDateParam(r, "from")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid 'from' date, please use YYYY-MM-DD")
+		return
+	}
+	to, err := parseDateParam(r, "to")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid 'to' date, please use YYYY-MM-DD")
+		return
+	}
+
+	filter := service.HabitFilter{
+		Window: service.LogWindow{From: from, To: to},
+		Tag:    r.URL.Query().Get("tag"),
+	}
+
+	profileData, err := h.service.GetProfileData(r.Context(), username, authenticatedUserID, filter)
 	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
 			errorResponse(w, http.StatusNotFound, "User not found")
 			return
 		}
+		if errors.Is(err, service.ErrInvalidDateRange) {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		slog.Error("could not retrieve user profile", "error", err)
 		errorResponse(w, http.StatusInternalServerError, "Could not retrieve user profile")
 		return
@@ -128,10 +270,42 @@ func (h *APIHandler) GetProfilePageData(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, profileData)
 }
 
+type ScheduleRequest struct {
+	Frequency      string `json:"frequency" validate:"omitempty,oneof=daily weekdays weekly monthly"`
+	Weekdays       []int  `json:"weekdays" validate:"max=7,dive,min=0,max=6"`
+	TimesPerPeriod int    `json:"timesPerPeriod" validate:"min=0,max=31"`
+}
+
+func (req *ScheduleRequest) toDomain() domain.Schedule {
+	return domain.Schedule{
+		Frequency:      domain.ScheduleFrequency(req.Frequency),
+		Weekdays:       req.Weekdays,
+		TimesPerPeriod: req.TimesPerPeriod,
+	}
+}
+
+type TargetRequest struct {
+	Value *float64 `json:"value" validate:"omitempty,min=0,max=999999999999"`
+	Mode  string   `json:"mode" validate:"omitempty,oneof=at_least at_most exactly"`
+}
+
+func (req *TargetRequest) toDomain() domain.Target {
+	return domain.Target{
+		Value: req.Value,
+		Mode:  domain.TargetMode(req.Mode),
+	}
+}
+
 type CreateHabitRequest struct {
-	Name      string `json:"name" validate:"required,min=1,max=100"`
-	ColorHue  int    `json:"colorHue" validate:"min=0,max=360"`
-	IsBoolean bool   `json:"isBoolean"`
+	Name      string           `json:"name" validate:"required,min=1,max=100"`
+	ColorHue  int              `json:"colorHue" validate:"min=0,max=360"`
+	IsBoolean bool             `json:"isBoolean"`
+	Schedule  *ScheduleRequest `json:"schedule" validate:"omitempty"`
+	Polarity  string           `json:"polarity" validate:"omitempty,oneof=positive negative"`
+	Target    *TargetRequest   `json:"target" validate:"omitempty"`
+	Unit      string           `json:"unit" validate:"max=16"`
+	Category  string           `json:"category" validate:"omitempty,max=32"`
+	TagIDs    []uuid.UUID      `json:"tagIds" validate:"max=50"`
 }
 
 func (h *APIHandler) CreateHabit(w http.ResponseWriter, r *http.Request) {
@@ -155,10 +329,26 @@ func (h *APIHandler) CreateHabit(w http.ResponseWriter, r *http.Request) {
 		Name:      req.Name,
 		ColorHue:  req.ColorHue,
 		IsBoolean: req.IsBoolean,
+		Polarity:  req.Polarity,
+		Unit:      req.Unit,
+		Category:  req.Category,
+		TagIDs:    req.TagIDs,
+	}
+	if req.Schedule != nil {
+		params.Schedule = req.Schedule.toDomain()
+	}
+	if req.Target != nil {
+		params.Target = req.Target.toDomain()
 	}
 
 	habit, err := h.service.CreateHabit(r.Context(), params, userID)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidSchedule) || errors.Is(err, service.ErrInvalidTarget) ||
+			errors.Is(err, service.ErrInvalidUnit) || errors.Is(err, service.ErrInvalidCategory) ||
+			errors.Is(err, service.ErrInvalidPolarity) || errors.Is(err, repository.ErrTagNotFound) {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		errorResponse(w, http.StatusInternalServerError, "Failed to create habit")
 		return
 	}
@@ -167,8 +357,15 @@ func (h *APIHandler) CreateHabit(w http.ResponseWriter, r *http.Request) {
 }
 
 type UpdateHabitRequest struct {
-	Name     string `json:"name" validate:"required,min=1,max=100"`
-	ColorHue int    `json:"colorHue" validate:"required,min=0,max=360"`
+	Name     string           `json:"name" validate:"required,min=1,max=100"`
+	ColorHue int              `json:"colorHue" validate:"required,min=0,max=360"`
+	Schedule *ScheduleRequest `json:"schedule" validate:"omitempty"`
+	Target   *TargetRequest   `json:"target" validate:"omitempty"`
+	// Polarity, Unit, Category and TagIDs are left unchanged when omitted.
+	Polarity *string      `json:"polarity" validate:"omitempty,oneof=positive negative"`
+	Unit     *string      `json:"unit" validate:"omitempty,max=16"`
+	Category *string      `json:"category" validate:"omitempty,max=32"`
+	TagIDs   *[]uuid.UUID `json:"tagIds" validate:"omitempty,max=50"`
 }
 
 func (h *APIHandler) UpdateHabit(w http.ResponseWriter, r *http.Request) {
@@ -199,11 +396,27 @@ func (h *APIHandler) UpdateHabit(w http.ResponseWriter, r *http.Request) {
 	params := service.UpdateHabitParams{
 		Name:     req.Name,
 		ColorHue: req.ColorHue,
+		Polarity: req.Polarity,
+		Unit:     req.Unit,
+		Category: req.Category,
+		TagIDs:   req.TagIDs,
+	}
+	if req.Schedule != nil {
+		schedule := req.Schedule.toDomain()
+		params.Schedule = &schedule
+	}
+	if req.Target != nil {
+		target := req.Target.toDomain()
+		params.Target = &target
 	}
 
 	_, err = h.service.UpdateHabit(r.Context(), params, habitID, userID)
 	if err != nil {
 		switch {
+		case errors.Is(err, service.ErrInvalidSchedule), errors.Is(err, service.ErrInvalidTarget),
+			errors.Is(err, service.ErrInvalidUnit), errors.Is(err, service.ErrInvalidCategory),
+			errors.Is(err, service.ErrInvalidPolarity), errors.Is(err, repository.ErrTagNotFound):
+			errorResponse(w, http.StatusBadRequest, err.Error())
 		case errors.Is(err, repository.ErrHabitNotFound):
 			errorResponse(w, http.StatusNotFound, "Habit not found")
 		case errors.Is(err, service.ErrUserAccessDenied):
@@ -244,14 +457,15 @@ func (h *APIHandler) DeleteHabit(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-const DATE_FORMAT = "2006-01-02"
+func (h *APIHandler) ArchiveHabit(w http.ResponseWriter, r *http.Request) {
+	h.setHabitArchived(w, r, true)
+}
 
-type LogHabitRequest struct {
-	Date  string `json:"date" validate:"required"`
-	Value int    `json:"value" validate:"min=0"`
+func (h *APIHandler) UnarchiveHabit(w http.ResponseWriter, r *http.Request) {
+	h.setHabitArchived(w, r, false)
 }
 
-func (h *APIHandler) LogHabit(w http.ResponseWriter, r *http.Request) {
+func (h *APIHandler) setHabitArchived(w http.ResponseWriter, r *http.Request, archived bool) {
 	habitIDStr := chi.URLParam(r, "habitId")
 	habitID, err := uuid.Parse(habitIDStr)
 	if err != nil {
@@ -259,7 +473,54 @@ func (h *APIHandler) LogHabit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req LogHabitRequest
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	if archived {
+		err = h.service.ArchiveHabit(r.Context(), habitID, userID)
+	} else {
+		err = h.service.UnarchiveHabit(r.Context(), habitID, userID)
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrUserAccessDenied) {
+			errorResponse(w, http.StatusForbidden, "You do not have permission to modify this habit")
+		} else {
+			slog.Error("failed to update habit archive state", "habitID", habitID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to update habit")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ConvertHabitRequest struct {
+	IsBoolean *bool `json:"isBoolean" validate:"required"`
+	// DoneValue is recorded for done days when converting to a numeric habit.
+	DoneValue float64        `json:"doneValue" validate:"min=0,max=999999999999"`
+	Target    *TargetRequest `json:"target" validate:"omitempty"`
+	Unit      string         `json:"unit" validate:"max=16"`
+}
+
+func (h *APIHandler) ConvertHabit(w http.ResponseWriter, r *http.Request) {
+	h.convertHabit(w, r, false)
+}
+
+func (h *APIHandler) PreviewHabitConversion(w http.ResponseWriter, r *http.Request) {
+	h.convertHabit(w, r, true)
+}
+
+func (h *APIHandler) convertHabit(w http.ResponseWriter, r *http.Request, preview bool) {
+	habitID, err := uuid.Parse(chi.URLParam(r, "habitId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+
+	var req ConvertHabitRequest
 	if err := readJSON(r, &req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
 		return
@@ -275,52 +536,71 @@ func (h *APIHandler) LogHabit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logDate, err := time.Parse(DATE_FORMAT, req.Date)
-	if err != nil {
-		errorResponse(w, http.StatusBadRequest, "Invalid date format, please use YYYY-MM-DD")
-		return
+	params := service.ConvertHabitParams{IsBoolean: *req.IsBoolean, DoneValue: req.DoneValue, Unit: req.Unit}
+	if req.Target != nil {
+		params.Target = req.Target.toDomain()
 	}
 
-	params := service.LogHabitParams{
-		HabitID: habitID,
-		Date:    logDate,
-		Value:   req.Value,
+	var result any
+	if preview {
+		result, err = h.service.PreviewHabitConversion(r.Context(), habitID, userID, params)
+	} else {
+		result, err = h.service.ConvertHabit(r.Context(), habitID, userID, params)
 	}
-
-	log, err := h.service.LogHabit(r.Context(), params, userID)
 	if err != nil {
 		switch {
 		case errors.Is(err, repository.ErrHabitNotFound):
 			errorResponse(w, http.StatusNotFound, "Habit not found")
 		case errors.Is(err, service.ErrUserAccessDenied):
-			errorResponse(w, http.StatusForbidden, "You do not have permission to log this habit")
+			errorResponse(w, http.StatusForbidden, "You do not have permission to modify this habit")
+		case errors.Is(err, service.ErrHabitTypeUnchanged), errors.Is(err, service.ErrHabitArchived):
+			errorResponse(w, http.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrInvalidDoneValue), errors.Is(err, service.ErrInvalidTarget),
+			errors.Is(err, service.ErrInvalidUnit), errors.Is(err, service.ErrInvalidPolarity):
+			errorResponse(w, http.StatusBadRequest, err.Error())
 		default:
-			errorResponse(w, http.StatusInternalServerError, "Failed to log habit")
+			slog.Error("failed to convert habit", "habitID", habitID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to convert habit")
 		}
 		return
 	}
 
-	writeJSON(w, http.StatusOK, log)
+	writeJSON(w, http.StatusOK, result)
 }
 
-func (h *APIHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
-	usernameToFollow := chi.URLParam(r, "username")
+func (h *APIHandler) PinHabit(w http.ResponseWriter, r *http.Request) {
+	h.setHabitPinned(w, r, true)
+}
 
-	followerID, ok := getUserIDFromContext(r.Context())
+func (h *APIHandler) UnpinHabit(w http.ResponseWriter, r *http.Request) {
+	h.setHabitPinned(w, r, false)
+}
+
+func (h *APIHandler) setHabitPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	habitIDStr := chi.URLParam(r, "habitId")
+	habitID, err := uuid.Parse(habitIDStr)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
 	if !ok {
 		errorResponse(w, http.StatusUnauthorized, "Authentication error")
 		return
 	}
 
-	if err := h.service.FollowUserByUsername(r.Context(), followerID, usernameToFollow); err != nil {
-		switch {
-		case errors.Is(err, service.ErrCannotFollowSelf):
-			errorResponse(w, http.StatusBadRequest, err.Error())
-		case errors.Is(err, repository.ErrUserNotFound):
-			errorResponse(w, http.StatusNotFound, "User to follow not found")
-		default:
-			slog.Error("failed to follow user", "error", err)
-			errorResponse(w, http.StatusInternalServerError, "Failed to follow user")
+	if pinned {
+		err = h.service.PinHabit(r.Context(), habitID, userID)
+	} else {
+		err = h.service.UnpinHabit(r.Context(), habitID, userID)
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrUserAccessDenied) {
+			errorResponse(w, http.StatusForbidden, "You do not have permission to modify this habit")
+		} else {
+			slog.Error("failed to update habit pin", "habitID", habitID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to update habit")
 		}
 		return
 	}
@@ -328,137 +608,1177 @@ func (h *APIHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *APIHandler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
-	usernameToUnfollow := chi.URLParam(r, "username")
+type ReorderHabitsRequest struct {
+	HabitIDs []uuid.UUID `json:"habitIds" validate:"required,min=1,max=1000"`
+}
 
-	followerID, ok := getUserIDFromContext(r.Context())
+func (h *APIHandler) ReorderHabits(w http.ResponseWriter, r *http.Request) {
+	var req ReorderHabitsRequest
+	if err := readJSON(r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		validationErrorResponse(w, err)
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
 	if !ok {
 		errorResponse(w, http.StatusUnauthorized, "Authentication error")
 		return
 	}
 
-	if err := h.service.UnfollowUserByUsername(r.Context(), followerID, usernameToUnfollow); err != nil {
-		if errors.Is(err, repository.ErrUserNotFound) {
-			errorResponse(w, http.StatusNotFound, "User to unfollow not found")
-			return
+	err := h.service.ReorderHabits(r.Context(), userID, req.HabitIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidHabitOrder):
+			errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrUserAccessDenied):
+			errorResponse(w, http.StatusForbidden, "You can only reorder your own habits")
+		default:
+			slog.Error("failed to reorder habits", "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to reorder habits")
 		}
-		slog.Error("failed to unfollow user", "error", err)
-		errorResponse(w, http.StatusInternalServerError, "Failed to unfollow user")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *APIHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
-	username := chi.URLParam(r, "username")
-	users, err := h.service.GetFollowers(r.Context(), username)
+func (h *APIHandler) RestoreHabit(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := chi.URLParam(r, "habitId")
+	habitID, err := uuid.Parse(habitIDStr)
 	if err != nil {
-		if errors.Is(err, repository.ErrUserNotFound) {
-			errorResponse(w, http.StatusNotFound, "User not found")
-			return
-		}
-		slog.Error("could not retrieve followers", "error", err)
-		errorResponse(w, http.StatusInternalServerError, "Unexpected internal server error")
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
 		return
 	}
-	writeJSON(w, http.StatusOK, users)
-}
 
-func (h *APIHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
-	username := chi.URLParam(r, "username")
-	users, err := h.service.GetFollowing(r.Context(), username)
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	err = h.service.RestoreHabit(r.Context(), habitID, userID, h.cfg.HabitTrashRetention)
 	if err != nil {
-		if errors.Is(err, repository.ErrUserNotFound) {
-			errorResponse(w, http.StatusNotFound, "User not found")
-			return
+		if errors.Is(err, repository.ErrHabitNotFound) {
+			errorResponse(w, http.StatusNotFound, "Habit not found in trash")
+		} else {
+			slog.Error("failed to restore habit", "habitID", habitID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to restore habit")
 		}
-		slog.Error("could not retrieve following list", "error", err)
-		errorResponse(w, http.StatusInternalServerError, "Unexpected internal server error")
 		return
 	}
-	writeJSON(w, http.StatusOK, users)
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *APIHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+func (h *APIHandler) GetArchivedHabits(w http.ResponseWriter, r *http.Request) {
 	userID, ok := getUserIDFromContext(r.Context())
 	if !ok {
 		errorResponse(w, http.StatusUnauthorized, "Authentication error")
 		return
 	}
 
-	if err := r.ParseMultipartForm(service.MAX_AVATAR_SIZE); err != nil {
-		errorResponse(w, http.StatusBadRequest, "File too large, max 2MB")
+	habits, err := h.service.GetArchivedHabits(r.Context(), userID)
+	if err != nil {
+		slog.Error("could not retrieve archived habits", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Could not retrieve archived habits")
 		return
 	}
 
-	file, header, err := r.FormFile("avatar")
-	if err != nil {
-		errorResponse(w, http.StatusBadRequest, "Could not get avatar from form")
+	writeJSON(w, http.StatusOK, habits)
+}
+
+func (h *APIHandler) GetDeletedHabits(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
 		return
 	}
-	defer file.Close()
 
-	avatarURL, err := h.service.UpdateUserAvatar(r.Context(), userID, file, header)
+	habits, err := h.service.GetDeletedHabits(r.Context(), userID, h.cfg.HabitTrashRetention)
 	if err != nil {
-		slog.Error("failed to update avatar", "error", err, "userID", userID)
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		slog.Error("could not retrieve deleted habits", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Could not retrieve deleted habits")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, habits)
+}
+
+const DATE_FORMAT = "2006-01-02"
+
+type LogHabitRequest struct {
+	Date  string  `json:"date" validate:"required"`
+	Value float64 `json:"value" validate:"min=0,max=999999999999"`
+	// Status defaults to "done". Skipped and failed days carry no value.
+	Status string `json:"status" validate:"omitempty,oneof=done skipped failed"`
+	// Note is left unchanged when omitted and removed when empty.
+	Note        *string `json:"note" validate:"omitempty,max=500"`
+	NotePrivate *bool   `json:"notePrivate"`
+}
+
+func (h *APIHandler) LogHabit(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := chi.URLParam(r, "habitId")
+	habitID, err := uuid.Parse(habitIDStr)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+
+	var req LogHabitRequest
+	if err := readJSON(r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		validationErrorResponse(w, err)
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	logDate, err := time.Parse(DATE_FORMAT, req.Date)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid date format, please use YYYY-MM-DD")
+		return
+	}
+
+	params := service.LogHabitParams{
+		HabitID:     habitID,
+		Date:        logDate,
+		Value:       req.Value,
+		Status:      domain.LogStatus(req.Status),
+		Note:        req.Note,
+		NotePrivate: req.NotePrivate,
+	}
+
+	log, err := h.service.LogHabit(r.Context(), params, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrHabitNotFound):
+			errorResponse(w, http.StatusNotFound, "Habit not found")
+		case errors.Is(err, service.ErrUserAccessDenied):
+			errorResponse(w, http.StatusForbidden, "You do not have permission to log this habit")
+		case errors.Is(err, service.ErrFutureLogDate), errors.Is(err, service.ErrNoteTooLong),
+			errors.Is(err, service.ErrInvalidLogStatus):
+			errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrHabitArchived):
+			errorResponse(w, http.StatusConflict, "Archived habits cannot be logged")
+		default:
+			errorResponse(w, http.StatusInternalServerError, "Failed to log habit")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, log)
+}
+
+type IncrementHabitLogRequest struct {
+	Delta float64 `json:"delta" validate:"required,min=-999999999999,max=999999999999"`
+}
+
+func (h *APIHandler) IncrementHabitLog(w http.ResponseWriter, r *http.Request) {
+	habitID, err := uuid.Parse(chi.URLParam(r, "habitId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+	logDate, err := time.Parse(DATE_FORMAT, chi.URLParam(r, "date"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid date format, please use YYYY-MM-DD")
+		return
+	}
+
+	var req IncrementHabitLogRequest
+	if err := readJSON(r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		validationErrorResponse(w, err)
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	params := service.IncrementHabitLogParams{HabitID: habitID, Date: logDate, Delta: req.Delta}
+	log, err := h.service.IncrementHabitLog(r.Context(), params, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrHabitNotFound):
+			errorResponse(w, http.StatusNotFound, "Habit not found")
+		case errors.Is(err, service.ErrUserAccessDenied):
+			errorResponse(w, http.StatusForbidden, "You do not have permission to log this habit")
+		case errors.Is(err, service.ErrFutureLogDate), errors.Is(err, service.ErrInvalidIncrement):
+			errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrHabitArchived):
+			errorResponse(w, http.StatusConflict, "Archived habits cannot be logged")
+		default:
+			errorResponse(w, http.StatusInternalServerError, "Failed to increment log")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, log)
+}
+
+func (h *APIHandler) GetHabitLogHistory(w http.ResponseWriter, r *http.Request) {
+	habitID, err := uuid.Parse(chi.URLParam(r, "habitId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+	logDate, err := time.Parse(DATE_FORMAT, chi.URLParam(r, "date"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid date format, please use YYYY-MM-DD")
+		return
+	}
+
+	viewerID, _ := getUserIDFromContext(r.Context())
+
+	revisions, err := h.service.GetHabitLogHistory(r.Context(), habitID, logDate, viewerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrHabitNotFound):
+			errorResponse(w, http.StatusNotFound, "Habit not found")
+		default:
+			errorResponse(w, http.StatusInternalServerError, "Could not retrieve log history")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+func (h *APIHandler) UndoHabitLog(w http.ResponseWriter, r *http.Request) {
+	habitID, err := uuid.Parse(chi.URLParam(r, "habitId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+	logDate, err := time.Parse(DATE_FORMAT, chi.URLParam(r, "date"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid date format, please use YYYY-MM-DD")
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	revision, err := h.service.UndoHabitLog(r.Context(), habitID, logDate, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrHabitNotFound):
+			errorResponse(w, http.StatusNotFound, "Habit not found")
+		case errors.Is(err, repository.ErrNothingToUndo):
+			errorResponse(w, http.StatusConflict, "There is no change to undo")
+		case errors.Is(err, service.ErrUserAccessDenied):
+			errorResponse(w, http.StatusForbidden, "You do not have permission to modify this habit")
+		case errors.Is(err, service.ErrHabitArchived):
+			errorResponse(w, http.StatusConflict, "Archived habits cannot be logged")
+		default:
+			errorResponse(w, http.StatusInternalServerError, "Failed to undo log change")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revision)
+}
+
+func (h *APIHandler) DeleteHabitLog(w http.ResponseWriter, r *http.Request) {
+	habitID, err := uuid.Parse(chi.URLParam(r, "habitId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+	logDate, err := time.Parse(DATE_FORMAT, chi.URLParam(r, "date"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid date format, please use YYYY-MM-DD")
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	if err := h.service.DeleteHabitLog(r.Context(), habitID, logDate, userID); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrHabitNotFound):
+			errorResponse(w, http.StatusNotFound, "Habit not found")
+		case errors.Is(err, repository.ErrHabitLogNotFound):
+			errorResponse(w, http.StatusNotFound, "Log not found")
+		case errors.Is(err, service.ErrUserAccessDenied):
+			errorResponse(w, http.StatusForbidden, "You do not have permission to modify this habit")
+		case errors.Is(err, service.ErrHabitArchived):
+			errorResponse(w, http.StatusConflict, "Archived habits cannot be logged")
+		default:
+			errorResponse(w, http.StatusInternalServerError, "Failed to delete log")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type BatchLogRequest struct {
+	// Entries are validated by the service, which reports on each of them.
+	Logs []BatchLogEntryRequest `json:"logs" validate:"required,min=1"`
+}
+
+type BatchLogEntryRequest struct {
+	Date   string  `json:"date"`
+	Value  float64 `json:"value"`
+	Status string  `json:"status"`
+}
+
+func (h *APIHandler) BackfillHabitLogs(w http.ResponseWriter, r *http.Request) {
+	habitID, err := uuid.Parse(chi.URLParam(r, "habitId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+
+	var req BatchLogRequest
+	if err := readJSON(r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		validationErrorResponse(w, err)
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	entries := make([]service.BatchLogEntry, len(req.Logs))
+	for i, entry := range req.Logs {
+		entries[i] = service.BatchLogEntry{Date: entry.Date, Value: entry.Value, Status: domain.LogStatus(entry.Status)}
+	}
+
+	results, err := h.service.BackfillHabitLogs(r.Context(), habitID, entries, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidBatch):
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": err.Error(), "details": results})
+		case errors.Is(err, service.ErrBatchTooLarge):
+			errorResponse(w, http.StatusRequestEntityTooLarge, "A batch holds at most "+strconv.Itoa(service.MaxBatchLogs)+" logs")
+		case errors.Is(err, repository.ErrHabitNotFound):
+			errorResponse(w, http.StatusNotFound, "Habit not found")
+		case errors.Is(err, service.ErrUserAccessDenied):
+			errorResponse(w, http.StatusForbidden, "You do not have permission to log this habit")
+		case errors.Is(err, service.ErrHabitArchived):
+			errorResponse(w, http.StatusConflict, "Archived habits cannot be logged")
+		default:
+			errorResponse(w, http.StatusInternalServerError, "Failed to save logs")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// parseDateParam parses an optional YYYY-MM-DD query parameter, returning the zero
+// time when it is absent.
+func parseDateParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(DATE_FORMAT, value)
+}
+
+func (h *APIHandler) GetHabitStats(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := chi.URLParam(r, "habitId")
+	habitID, err := uuid.Parse(habitIDStr)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+
+	from, err := parseDateParam(r, "from")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid 'from' date, please use YYYY-MM-DD")
+		return
+	}
+	to, err := parseDateParam(r, "to")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid 'to' date, please use YYYY-MM-DD")
+		return
+	}
+
+	viewerID, _ := getUserIDFromContext(r.Context())
+
+	params := service.HabitStatsParams{
+		HabitID: habitID,
+		From:    from,
+		To:      to,
+	}
+
+	stats, err := h.service.GetHabitStats(r.Context(), params, viewerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrHabitNotFound):
+			errorResponse(w, http.StatusNotFound, "Habit not found")
+		case errors.Is(err, service.ErrUserAccessDenied):
+			errorResponse(w, http.StatusForbidden, "You do not have permission to view this habit")
+		case errors.Is(err, service.ErrInvalidDateRange):
+			errorResponse(w, http.StatusBadRequest, err.Error())
+		default:
+			slog.Error("could not retrieve habit stats", "habitID", habitID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Could not retrieve habit stats")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (h *APIHandler) GetHabitLogs(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := chi.URLParam(r, "habitId")
+	habitID, err := uuid.Parse(habitIDStr)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+
+	from, err := parseDateParam(r, "from")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid 'from' date, please use YYYY-MM-DD")
+		return
+	}
+	to, err := parseDateParam(r, "to")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid 'to' date, please use YYYY-MM-DD")
+		return
+	}
+
+	var limit int
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			errorResponse(w, http.StatusBadRequest, "Invalid 'limit', must be a positive integer")
+			return
+		}
+	}
+
+	viewerID, _ := getUserIDFromContext(r.Context())
+
+	params := service.HabitLogsParams{
+		HabitID: habitID,
+		From:    from,
+		To:      to,
+		Cursor:  r.URL.Query().Get("cursor"),
+		Limit:   limit,
+	}
+
+	page, err := h.service.GetHabitLogs(r.Context(), params, viewerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrHabitNotFound):
+			errorResponse(w, http.StatusNotFound, "Habit not found")
+		case errors.Is(err, service.ErrInvalidDateRange), errors.Is(err, service.ErrInvalidCursor):
+			errorResponse(w, http.StatusBadRequest, err.Error())
+		default:
+			slog.Error("could not retrieve habit logs", "habitID", habitID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Could not retrieve habit logs")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (h *APIHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
+	usernameToFollow := chi.URLParam(r, "username")
+
+	followerID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	if err := h.service.FollowUserByUsername(r.Context(), followerID, usernameToFollow); err != nil {
+		switch {
+		case errors.Is(err, service.ErrCannotFollowSelf):
+			errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrUserNotFound):
+			errorResponse(w, http.StatusNotFound, "User to follow not found")
+		default:
+			slog.Error("failed to follow user", "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to follow user")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *APIHandler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	usernameToUnfollow := chi.URLParam(r, "username")
+
+	followerID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	if err := h.service.UnfollowUserByUsername(r.Context(), followerID, usernameToUnfollow); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			errorResponse(w, http.StatusNotFound, "User to unfollow not found")
+			return
+		}
+		slog.Error("failed to unfollow user", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to unfollow user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *APIHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	users, err := h.service.GetFollowers(r.Context(), username)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			errorResponse(w, http.StatusNotFound, "User not found")
+			return
+		}
+		slog.Error("could not retrieve followers", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Unexpected internal server error")
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+func (h *APIHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	users, err := h.service.GetFollowing(r.Context(), username)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			errorResponse(w, http.StatusNotFound, "User not found")
+			return
+		}
+		slog.Error("could not retrieve following list", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Unexpected internal server error")
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+func (h *APIHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	if err := r.ParseMultipartForm(service.MAX_AVATAR_SIZE); err != nil {
+		errorResponse(w, http.StatusBadRequest, "File too large, max 2MB")
+		return
+	}
+
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Could not get avatar from form")
+		return
+	}
+	defer file.Close()
+
+	avatarURL, err := h.service.UpdateUserAvatar(r.Context(), userID, file, header)
+	if err != nil {
+		slog.Error("failed to update avatar", "error", err, "userID", userID)
+		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]string{"avatarUrl": avatarURL})
 }
 
-func (h *APIHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
+type UpdateSettingsRequest struct {
+	Timezone string `json:"timezone" validate:"required,max=64"`
+}
+
+func (h *APIHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	var req UpdateSettingsRequest
+	if err := readJSON(r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		validationErrorResponse(w, err)
+		return
+	}
+
+	if err := h.service.UpdateUserTimezone(r.Context(), userID, req.Timezone); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTimezone):
+			errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrUserNotFound):
+			errorResponse(w, http.StatusNotFound, "User not found")
+		default:
+			slog.Error("failed to update settings", "userID", userID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to update settings")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	// NewPassword follows the same rules as SignUpRequest.Password.
+	NewPassword string `json:"newPassword" validate:"required,min=8,printascii"`
+}
+
+// ChangePassword signs the user out everywhere and responds with fresh tokens for the
+// device making the change.
+func (h *APIHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := readJSON(r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		validationErrorResponse(w, err)
+		return
+	}
+
+	params := service.ChangePasswordParams{
+		CurrentPassword: req.CurrentPassword,
+		NewPassword:     req.NewPassword,
+		Client:          sessionClient(r),
+	}
+	tokens, err := h.service.ChangePassword(r.Context(), userID, params, h.tokenConfig())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrIncorrectPassword):
+			errorResponse(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, repository.ErrUserNotFound):
+			errorResponse(w, http.StatusNotFound, "User not found")
+		default:
+			slog.Error("failed to change password", "userID", userID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to change password")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RefreshTokenResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken})
+}
+
+func (h *APIHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+
+	users, err := h.service.SearchUsers(r.Context(), query)
+	if err != nil {
+		slog.Error("failed to search users", "error", err, "query", query)
+		errorResponse(w, http.StatusInternalServerError, "Failed to search for users")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, users)
+}
+
+func (h *APIHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	leaderboardData, err := h.service.GetLeaderboard(r.Context())
+	if err != nil {
+		slog.Error("could not retrieve leaderboard data", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Could not retrieve leaderboard")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, leaderboardData)
+}
+
+func (h *APIHandler) GetExplorePage(w http.ResponseWriter, r *http.Request) {
+	exploreData, err := h.service.GetExplorePage(r.Context(), r.URL.Query().Get("category"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCategory) {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("could not retrieve explore page data", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Could not retrieve explore page data")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, exploreData)
+}
+
+func (h *APIHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	if err := h.service.DeleteUser(r.Context(), userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			// This shouldn't happen if the token is valid, but handle it defensively.
+			errorResponse(w, http.StatusNotFound, "User not found")
+			return
+		}
+		slog.Error("failed to delete user", "userID", userID, "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExportAccount streams a zip archive of all of the user's data.
+func (h *APIHandler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	export, err := h.service.ExportAccount(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			errorResponse(w, http.StatusNotFound, "User not found")
+			return
+		}
+		slog.Error("failed to export account", "userID", userID, "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to export account")
+		return
+	}
+
+	filename := "peakstreak-" + export.User.Username + "-" + time.Now().UTC().Format(DATE_FORMAT) + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	// The status is already sent, so a failure midway can only cut the archive short.
+	if err := export.WriteZip(r.Context(), w); err != nil {
+		slog.Error("failed to write account export", "userID", userID, "error", err)
+	}
+}
+
+// ImportHabits imports habits and their history from an uploaded file. With
+// ?dryRun=true it only reports what the import would do.
+func (h *APIHandler) ImportHabits(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
 
-	users, err := h.service.SearchUsers(r.Context(), query)
-	if err != nil {
-		slog.Error("failed to search users", "error", err, "query", query)
-		errorResponse(w, http.StatusInternalServerError, "Failed to search for users")
+	dryRun := false
+	if value := r.URL.Query().Get("dryRun"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid dryRun parameter")
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, service.MAX_IMPORT_SIZE+service.MB)
+	if err := r.ParseMultipartForm(service.MAX_IMPORT_SIZE); err != nil {
+		errorResponse(w, http.StatusBadRequest, "File too large, max 10MB")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, users)
-}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Could not get file from form")
+		return
+	}
+	defer file.Close()
 
-func (h *APIHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
-	leaderboardData, err := h.service.GetLeaderboard(r.Context())
+	summary, err := h.service.ImportHabits(r.Context(), userID, file, header.Size, dryRun)
 	if err != nil {
-		slog.Error("could not retrieve leaderboard data", "error", err)
-		errorResponse(w, http.StatusInternalServerError, "Could not retrieve leaderboard")
+		switch {
+		case errors.Is(err, importer.ErrUnknownFormat), errors.Is(err, importer.ErrInvalidFile):
+			errorResponse(w, http.StatusBadRequest, err.Error())
+		default:
+			slog.Error("failed to import habits", "userID", userID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to import habits")
+		}
 		return
 	}
 
-	writeJSON(w, http.StatusOK, leaderboardData)
+	writeJSON(w, http.StatusOK, summary)
 }
 
-func (h *APIHandler) GetExplorePage(w http.ResponseWriter, r *http.Request) {
-	exploreData, err := h.service.GetExplorePage(r.Context())
+type CalendarTokenResponse struct {
+	Token string `json:"token"`
+	// FeedURL is the path of the feed of all habits. Appending /habit/{habitId}.ics
+	// in place of .ics gives the feed of a single habit.
+	FeedURL string `json:"feedUrl"`
+}
+
+func calendarTokenResponse(token string) CalendarTokenResponse {
+	return CalendarTokenResponse{Token: token, FeedURL: "/api/calendar/" + token + ".ics"}
+}
+
+func (h *APIHandler) GetCalendarToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	token, err := h.service.GetCalendarToken(r.Context(), userID)
 	if err != nil {
-		slog.Error("could not retrieve explore page data", "error", err)
-		errorResponse(w, http.StatusInternalServerError, "Could not retrieve explore page data")
+		if errors.Is(err, repository.ErrUserNotFound) {
+			errorResponse(w, http.StatusNotFound, "User not found")
+			return
+		}
+		errorResponse(w, http.StatusInternalServerError, "Failed to get calendar feed")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, exploreData)
+	writeJSON(w, http.StatusOK, calendarTokenResponse(token))
 }
 
-func (h *APIHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+func (h *APIHandler) RegenerateCalendarToken(w http.ResponseWriter, r *http.Request) {
 	userID, ok := getUserIDFromContext(r.Context())
 	if !ok {
 		errorResponse(w, http.StatusUnauthorized, "Authentication error")
 		return
 	}
 
-	if err := h.service.DeleteUser(r.Context(), userID); err != nil {
+	token, err := h.service.RegenerateCalendarToken(r.Context(), userID)
+	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
-			// This shouldn't happen if the token is valid, but handle it defensively.
 			errorResponse(w, http.StatusNotFound, "User not found")
 			return
 		}
-		slog.Error("failed to delete user", "userID", userID, "error", err)
-		errorResponse(w, http.StatusInternalServerError, "Failed to delete account")
+		errorResponse(w, http.StatusInternalServerError, "Failed to regenerate calendar feed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, calendarTokenResponse(token))
+}
+
+func (h *APIHandler) GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	var habitID *uuid.UUID
+	if value := chi.URLParam(r, "habitId"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+			return
+		}
+		habitID = &id
+	}
+
+	cal, err := h.service.CalendarFeed(r.Context(), chi.URLParam(r, "token"), habitID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUserNotFound):
+			errorResponse(w, http.StatusNotFound, "Calendar not found")
+		case errors.Is(err, repository.ErrHabitNotFound):
+			errorResponse(w, http.StatusNotFound, "Habit not found")
+		default:
+			errorResponse(w, http.StatusInternalServerError, "Failed to build calendar")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=900")
+	w.WriteHeader(http.StatusOK)
+	if err := cal.Write(w); err != nil {
+		slog.Error("failed to write calendar feed", "error", err)
+	}
+}
+
+func (h *APIHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, domain.HabitCategories)
+}
+
+func (h *APIHandler) CloneHabit(w http.ResponseWriter, r *http.Request) {
+	habitID, err := uuid.Parse(chi.URLParam(r, "habitId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	habit, err := h.service.CloneHabit(r.Context(), habitID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrHabitNotFound) {
+			errorResponse(w, http.StatusNotFound, "Habit not found")
+			return
+		}
+		slog.Error("failed to clone habit", "habitID", habitID, "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to clone habit")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, habit)
+}
+
+func (h *APIHandler) GetHabitTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.service.GetHabitTemplates(r.Context(), r.URL.Query().Get("category"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCategory) {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("could not retrieve habit templates", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Could not retrieve habit templates")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, templates)
+}
+
+func (h *APIHandler) CreateHabitFromTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	habit, err := h.service.CreateHabitFromTemplate(r.Context(), chi.URLParam(r, "templateId"), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrHabitTemplateNotFound) {
+			errorResponse(w, http.StatusNotFound, "Habit template not found")
+			return
+		}
+		slog.Error("failed to create habit from template", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to create habit")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, habit)
+}
+
+type PublishHabitTemplateRequest struct {
+	Description string `json:"description" validate:"max=280"`
+}
+
+func (h *APIHandler) PublishHabitTemplate(w http.ResponseWriter, r *http.Request) {
+	habitID, err := uuid.Parse(chi.URLParam(r, "habitId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid habit ID format")
+		return
+	}
+
+	// The body is optional, as the description is.
+	var req PublishHabitTemplateRequest
+	if err := readJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		validationErrorResponse(w, err)
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	template, err := h.service.PublishHabitTemplate(r.Context(), habitID, userID, req.Description)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrHabitNotFound):
+			errorResponse(w, http.StatusNotFound, "Habit not found")
+		case errors.Is(err, service.ErrUserAccessDenied):
+			errorResponse(w, http.StatusForbidden, "You do not have permission to publish this habit")
+		case errors.Is(err, service.ErrInvalidTemplate):
+			errorResponse(w, http.StatusBadRequest, err.Error())
+		default:
+			slog.Error("failed to publish habit template", "habitID", habitID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to publish habit")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, template)
+}
+
+func (h *APIHandler) DeleteHabitTemplate(w http.ResponseWriter, r *http.Request) {
+	// Curated templates cannot be deleted, and they are the only ones without a UUID.
+	templateID, err := uuid.Parse(chi.URLParam(r, "templateId"))
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Habit template not found")
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	if err := h.service.DeleteHabitTemplate(r.Context(), templateID, userID); err != nil {
+		if errors.Is(err, repository.ErrHabitTemplateNotFound) {
+			errorResponse(w, http.StatusNotFound, "Habit template not found")
+		} else {
+			slog.Error("failed to delete habit template", "templateID", templateID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to delete habit template")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type TagRequest struct {
+	Name     string `json:"name" validate:"required,min=1,max=32"`
+	ColorHue int    `json:"colorHue" validate:"min=0,max=360"`
+}
+
+func (h *APIHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	tags, err := h.service.GetTags(r.Context(), userID)
+	if err != nil {
+		slog.Error("could not retrieve tags", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Could not retrieve tags")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tags)
+}
+
+func (h *APIHandler) CreateTag(w http.ResponseWriter, r *http.Request) {
+	var req TagRequest
+	if err := readJSON(r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		validationErrorResponse(w, err)
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	params := service.TagParams{Name: req.Name, ColorHue: req.ColorHue}
+	tag, err := h.service.CreateTag(r.Context(), params, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTagName):
+			errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrDuplicateTagName):
+			errorResponse(w, http.StatusConflict, "A tag with this name already exists")
+		default:
+			slog.Error("failed to create tag", "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to create tag")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, tag)
+}
+
+func (h *APIHandler) UpdateTag(w http.ResponseWriter, r *http.Request) {
+	tagID, err := uuid.Parse(chi.URLParam(r, "tagId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid tag ID format")
+		return
+	}
+
+	var req TagRequest
+	if err := readJSON(r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		validationErrorResponse(w, err)
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	params := service.TagParams{Name: req.Name, ColorHue: req.ColorHue}
+	tag, err := h.service.UpdateTag(r.Context(), tagID, params, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTagName):
+			errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrTagNotFound):
+			errorResponse(w, http.StatusNotFound, "Tag not found")
+		case errors.Is(err, repository.ErrDuplicateTagName):
+			errorResponse(w, http.StatusConflict, "A tag with this name already exists")
+		default:
+			slog.Error("failed to update tag", "tagID", tagID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to update tag")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tag)
+}
+
+func (h *APIHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	tagID, err := uuid.Parse(chi.URLParam(r, "tagId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid tag ID format")
+		return
+	}
+
+	userID, ok := getUserIDFromContext(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Authentication error")
+		return
+	}
+
+	if err := h.service.DeleteTag(r.Context(), tagID, userID); err != nil {
+		if errors.Is(err, repository.ErrTagNotFound) {
+			errorResponse(w, http.StatusNotFound, "Tag not found")
+		} else {
+			slog.Error("failed to delete tag", "tagID", tagID, "error", err)
+			errorResponse(w, http.StatusInternalServerError, "Failed to delete tag")
+		}
 		return
 	}
 
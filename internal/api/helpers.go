@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 
+	"github.com/axseem/peakstreak/internal/service"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -21,6 +23,16 @@ func errorResponse(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, map[string]string{"error": message})
 }
 
+// sessionClient describes the device making a request. RealIP has already replaced
+// the remote address with the forwarded one, if any.
+func sessionClient(r *http.Request) service.SessionClient {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return service.SessionClient{UserAgent: r.UserAgent(), IP: ip}
+}
+
 func readJSON(r *http.Request, dst any) error {
 	return json.NewDecoder(r.Body).Decode(dst)
 }
@@ -2,16 +2,22 @@ package api
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/axseem/peakstreak/internal/auth"
+	"github.com/axseem/peakstreak/internal/service"
 	"github.com/google/uuid"
 )
 
 type contextKey string
 
-const userContextKey = contextKey("userID")
+const (
+	userContextKey    = contextKey("userID")
+	sessionContextKey = contextKey("sessionID")
+)
 
 func (h *APIHandler) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -28,13 +34,19 @@ func (h *APIHandler) authMiddleware(next http.Handler) http.Handler {
 		}
 
 		tokenString := parts[1]
-		claims, err := auth.ValidateToken(tokenString, h.cfg.JWTSecret)
+		claims, err := h.validateAccessToken(r.Context(), tokenString)
 		if err != nil {
-			errorResponse(w, http.StatusUnauthorized, "invalid or expired token")
+			if errors.Is(err, errInvalidAccessToken) {
+				errorResponse(w, http.StatusUnauthorized, "invalid or expired token")
+			} else {
+				slog.Error("failed to check access token", "error", err)
+				errorResponse(w, http.StatusInternalServerError, "Authentication error")
+			}
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), userContextKey, claims.UserID)
+		ctx = context.WithValue(ctx, sessionContextKey, claims.SessionID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -56,7 +68,7 @@ func (h *APIHandler) authOptionalMiddleware(next http.Handler) http.Handler {
 		}
 
 		tokenString := parts[1]
-		claims, err := auth.ValidateToken(tokenString, h.cfg.JWTSecret)
+		claims, err := h.validateAccessToken(r.Context(), tokenString)
 		if err != nil {
 			next.ServeHTTP(w, r)
 			return
@@ -67,7 +79,34 @@ func (h *APIHandler) authOptionalMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+var errInvalidAccessToken = errors.New("invalid access token")
+
+// validateAccessToken checks an access token's signature and expiry, and that its
+// session is still active and it was issued in the user's current token generation.
+// Tokens failing any check yield errInvalidAccessToken.
+func (h *APIHandler) validateAccessToken(ctx context.Context, tokenString string) (*auth.Claims, error) {
+	claims, err := auth.ValidateToken(tokenString, h.cfg.JWTSecret)
+	if err != nil {
+		return nil, errInvalidAccessToken
+	}
+	err = h.service.CheckAccessToken(ctx, claims.UserID, claims.SessionID, claims.Generation)
+	if errors.Is(err, service.ErrTokenRevoked) {
+		return nil, errInvalidAccessToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
 func getUserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
 	userID, ok := ctx.Value(userContextKey).(uuid.UUID)
 	return userID, ok
 }
+
+// getSessionIDFromContext returns the session of the request's access token, set by
+// authMiddleware.
+func getSessionIDFromContext(ctx context.Context) uuid.UUID {
+	sessionID, _ := ctx.Value(sessionContextKey).(uuid.UUID)
+	return sessionID
+}
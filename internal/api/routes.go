@@ -11,6 +11,13 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 )
 
+// requestTimeout bounds how long a request may take. The account export streams its
+// archive for as long as it takes to write, so it gets exportTimeout instead.
+const (
+	requestTimeout = 60 * time.Second
+	exportTimeout  = 30 * time.Minute
+)
+
 func NewRouter(handler *APIHandler) http.Handler {
 	r := chi.NewRouter()
 
@@ -18,8 +25,18 @@ func NewRouter(handler *APIHandler) http.Handler {
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
 
+	r.With(handler.authMiddleware, middleware.Timeout(exportTimeout)).Get("/api/user/export", handler.ExportAccount)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(requestTimeout))
+		routes(r, handler)
+	})
+
+	return r
+}
+
+func routes(r chi.Router, handler *APIHandler) {
 	workDir, _ := os.Getwd()
 	uploadsDir := http.Dir(filepath.Join(workDir, "uploads"))
 	r.Handle("/uploads/*", http.StripPrefix("/uploads/", http.FileServer(uploadsDir)))
@@ -31,12 +48,19 @@ func NewRouter(handler *APIHandler) http.Handler {
 
 		// Public routes
 		r.Get("/explore", handler.GetExplorePage)
+		r.Get("/categories", handler.GetCategories)
+		r.Get("/habit-templates", handler.GetHabitTemplates)
 		r.Get("/leaderboard", handler.GetLeaderboard)
 		r.Get("/users/search", handler.SearchUsers)
+		// Calendar feeds are authenticated by the secret token in their URL.
+		r.Get("/calendar/{token}.ics", handler.GetCalendarFeed)
+		r.Get("/calendar/{token}/habit/{habitId}.ics", handler.GetCalendarFeed)
 
 		r.Route("/auth", func(r chi.Router) {
 			r.Post("/signup", handler.SignUp)
 			r.Post("/login", handler.Login)
+			r.Post("/refresh", handler.RefreshToken)
+			r.Post("/logout", handler.Logout)
 		})
 
 		// Public routes that can be enhanced by authentication
@@ -45,6 +69,9 @@ func NewRouter(handler *APIHandler) http.Handler {
 			r.Get("/profile/{username}", handler.GetProfilePageData)
 			r.Get("/profile/{username}/followers", handler.GetFollowers)
 			r.Get("/profile/{username}/following", handler.GetFollowing)
+			r.Get("/habit/{habitId}/stats", handler.GetHabitStats)
+			r.Get("/habit/{habitId}/logs", handler.GetHabitLogs)
+			r.Get("/habit/{habitId}/log/{date}/history", handler.GetHabitLogHistory)
 		})
 
 		// Strictly authenticated routes
@@ -52,12 +79,42 @@ func NewRouter(handler *APIHandler) http.Handler {
 			r.Use(handler.authMiddleware)
 
 			r.Post("/user/avatar", handler.UploadAvatar)
+			r.Put("/user/settings", handler.UpdateSettings)
+			r.Put("/user/password", handler.ChangePassword)
 			r.Delete("/user", handler.DeleteUser)
+			r.Post("/user/import", handler.ImportHabits)
+			r.Get("/user/calendar", handler.GetCalendarToken)
+			r.Post("/user/calendar/token", handler.RegenerateCalendarToken)
+			r.Get("/user/sessions", handler.GetSessions)
+			r.Delete("/user/sessions/{sessionId}", handler.RevokeSession)
 
 			r.Post("/habit", handler.CreateHabit)
+			r.Post("/habit/from-template/{templateId}", handler.CreateHabitFromTemplate)
+			r.Post("/habit/{habitId}/publish", handler.PublishHabitTemplate)
+			r.Post("/habit/{habitId}/clone", handler.CloneHabit)
+			r.Delete("/habit-templates/{templateId}", handler.DeleteHabitTemplate)
 			r.Put("/habit/{habitId}", handler.UpdateHabit)
 			r.Delete("/habit/{habitId}", handler.DeleteHabit)
+			r.Post("/habit/{habitId}/archive", handler.ArchiveHabit)
+			r.Delete("/habit/{habitId}/archive", handler.UnarchiveHabit)
+			r.Post("/habit/{habitId}/restore", handler.RestoreHabit)
+			r.Post("/habit/{habitId}/convert", handler.ConvertHabit)
+			r.Post("/habit/{habitId}/convert/preview", handler.PreviewHabitConversion)
+			r.Get("/habits/archived", handler.GetArchivedHabits)
+			r.Get("/habits/trash", handler.GetDeletedHabits)
+			r.Put("/habits/order", handler.ReorderHabits)
+
+			r.Get("/tags", handler.GetTags)
+			r.Post("/tags", handler.CreateTag)
+			r.Put("/tags/{tagId}", handler.UpdateTag)
+			r.Delete("/tags/{tagId}", handler.DeleteTag)
+			r.Post("/habit/{habitId}/pin", handler.PinHabit)
+			r.Delete("/habit/{habitId}/pin", handler.UnpinHabit)
 			r.Post("/habit/{habitId}/log", handler.LogHabit)
+			r.Delete("/habit/{habitId}/log/{date}", handler.DeleteHabitLog)
+			r.Post("/habit/{habitId}/log/{date}/increment", handler.IncrementHabitLog)
+			r.Post("/habit/{habitId}/log/{date}/undo", handler.UndoHabitLog)
+			r.Post("/habit/{habitId}/logs:batch", handler.BackfillHabitLogs)
 
 			r.Post("/profile/{username}/follow", handler.FollowUser)
 			r.Delete("/profile/{username}/follow", handler.UnfollowUser)
@@ -65,8 +122,6 @@ func NewRouter(handler *APIHandler) http.Handler {
 	})
 
 	ServeSPA(r, "./frontend/build")
-
-	return r
 }
 
 func ServeSPA(r chi.Router, staticPath string) {
@@ -10,12 +10,19 @@ import (
 
 type Claims struct {
 	UserID uuid.UUID `json:"user_id"`
+	// SessionID is the session the token was issued to.
+	SessionID uuid.UUID `json:"sid"`
+	// Generation is the user's token generation when the token was issued. The token
+	// is rejected once the user's generation moves past it.
+	Generation int `json:"gen"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uuid.UUID, secret string, expiresIn time.Duration) (string, error) {
+func GenerateToken(userID, sessionID uuid.UUID, generation int, secret string, expiresIn time.Duration) (string, error) {
 	claims := &Claims{
-		UserID: userID,
+		UserID:     userID,
+		SessionID:  sessionID,
+		Generation: generation,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrMalformedRefreshToken = errors.New("malformed refresh token")
+
+// A refresh token is the ID of its session and a random secret, joined by a dot. Only
+// a hash of the secret is stored, so tokens cannot be recovered from the database.
+
+// NewRefreshToken returns a new refresh token for a session and the hash to store.
+func NewRefreshToken(sessionID uuid.UUID) (token string, hash []byte, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	secret := hex.EncodeToString(b)
+	return sessionID.String() + "." + secret, hashSecret(secret), nil
+}
+
+// ParseRefreshToken splits a refresh token into its session ID and the hash of its
+// secret.
+func ParseRefreshToken(token string) (sessionID uuid.UUID, hash []byte, err error) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || secret == "" {
+		return uuid.Nil, nil, ErrMalformedRefreshToken
+	}
+	sessionID, err = uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, nil, ErrMalformedRefreshToken
+	}
+	return sessionID, hashSecret(secret), nil
+}
+
+func hashSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
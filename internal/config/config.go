@@ -11,6 +11,14 @@ type Config struct {
 	ServerPort   string        `mapstructure:"SERVER_PORT"`
 	JWTSecret    string        `mapstructure:"JWT_SECRET"`
 	JWTExpiresIn time.Duration `mapstructure:"JWT_EXPIRES_IN"`
+	// RefreshTokenExpiresIn is how long a session stays signed in without being used.
+	RefreshTokenExpiresIn time.Duration `mapstructure:"REFRESH_TOKEN_EXPIRES_IN"`
+	// HabitTrashRetention is how long deleted habits can be restored before they are
+	// purged.
+	HabitTrashRetention time.Duration `mapstructure:"HABIT_TRASH_RETENTION"`
+	// HabitTemplatesFile is a JSON file replacing the built-in catalogue of habit
+	// templates. The built-in one is used when empty.
+	HabitTemplatesFile string `mapstructure:"HABIT_TEMPLATES_FILE"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -19,7 +27,10 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.SetConfigType("env")
 
 	viper.SetDefault("SERVER_PORT", "8080")
-	viper.SetDefault("JWT_EXPIRES_IN", "24h")
+	viper.SetDefault("JWT_EXPIRES_IN", "15m")
+	viper.SetDefault("REFRESH_TOKEN_EXPIRES_IN", "720h")
+	viper.SetDefault("HABIT_TRASH_RETENTION", "720h")
+	viper.SetDefault("HABIT_TEMPLATES_FILE", "")
 
 	viper.AutomaticEnv()
 
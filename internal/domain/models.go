@@ -12,7 +12,27 @@ type User struct {
 	Email          string    `json:"email"`
 	HashedPassword string    `json:"-"`
 	AvatarURL      *string   `json:"avatarUrl,omitempty"`
+	Timezone       string    `json:"timezone"`
 	CreatedAt      time.Time `json:"createdAt"`
+	// TokenGeneration is embedded in the user's access tokens; tokens from an earlier
+	// generation are no longer accepted.
+	TokenGeneration int `json:"-"`
+}
+
+// Session is a device a user signed in on. Its refresh token is rotated on every
+// use, and only the hash of the current one is kept.
+type Session struct {
+	ID               uuid.UUID  `json:"id"`
+	UserID           uuid.UUID  `json:"-"`
+	RefreshTokenHash []byte     `json:"-"`
+	UserAgent        string     `json:"device"`
+	IPAddress        string     `json:"ip"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	LastSeenAt       time.Time  `json:"lastSeenAt"`
+	ExpiresAt        time.Time  `json:"expiresAt"`
+	RevokedAt        *time.Time `json:"-"`
+	// Current marks the session of the request listing the sessions.
+	Current bool `json:"current" db:"-"`
 }
 
 type PublicUser struct {
@@ -21,27 +41,273 @@ type PublicUser struct {
 	AvatarURL *string   `json:"avatarUrl,omitempty"`
 }
 
-type Habit struct {
+type ScheduleFrequency string
+
+const (
+	FrequencyDaily    ScheduleFrequency = "daily"
+	FrequencyWeekdays ScheduleFrequency = "weekdays"
+	FrequencyWeekly   ScheduleFrequency = "weekly"
+	FrequencyMonthly  ScheduleFrequency = "monthly"
+)
+
+// Schedule describes when a habit is expected to be completed.
+type Schedule struct {
+	Frequency ScheduleFrequency `json:"frequency" db:"schedule_frequency"`
+	// Weekdays lists the days a "weekdays" habit is due on, 0 being Sunday.
+	Weekdays []int `json:"weekdays,omitempty" db:"schedule_weekdays"`
+	// TimesPerPeriod is how many completions a "weekly" or "monthly" habit needs per period.
+	TimesPerPeriod int `json:"timesPerPeriod,omitempty" db:"schedule_times"`
+}
+
+type TargetMode string
+
+const (
+	TargetAtLeast TargetMode = "at_least"
+	TargetAtMost  TargetMode = "at_most"
+	TargetExactly TargetMode = "exactly"
+)
+
+// Target is the daily goal a numeric habit's logged value is measured against.
+// A nil Value means any positive value completes the day.
+type Target struct {
+	Value *float64   `json:"value,omitempty" db:"target_value"`
+	Mode  TargetMode `json:"mode" db:"target_mode"`
+}
+
+// HabitPolarity says whether a habit is one to build or one to quit.
+type HabitPolarity string
+
+const (
+	PolarityPositive HabitPolarity = "positive"
+	// PolarityNegative habits are things to avoid. A log records a relapse, and
+	// success is the clean days since the last one.
+	PolarityNegative HabitPolarity = "negative"
+)
+
+// HabitCategory is one of a fixed, global set of categories the explore page can be
+// filtered by.
+type HabitCategory string
+
+const (
+	CategoryFitness      HabitCategory = "fitness"
+	CategoryHealth       HabitCategory = "health"
+	CategoryLearning     HabitCategory = "learning"
+	CategoryMindfulness  HabitCategory = "mindfulness"
+	CategoryProductivity HabitCategory = "productivity"
+	CategoryCreativity   HabitCategory = "creativity"
+	CategoryFinance      HabitCategory = "finance"
+	CategorySocial       HabitCategory = "social"
+)
+
+var HabitCategories = []HabitCategory{
+	CategoryFitness, CategoryHealth, CategoryLearning, CategoryMindfulness,
+	CategoryProductivity, CategoryCreativity, CategoryFinance, CategorySocial,
+}
+
+// Tag is a user-defined label for grouping habits.
+type Tag struct {
 	ID        uuid.UUID `json:"id"`
 	UserID    uuid.UUID `json:"userId"`
 	Name      string    `json:"name"`
 	ColorHue  int       `json:"colorHue"`
-	IsBoolean bool      `json:"isBoolean"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+type Habit struct {
+	ID        uuid.UUID     `json:"id"`
+	UserID    uuid.UUID     `json:"userId"`
+	Name      string        `json:"name"`
+	ColorHue  int           `json:"colorHue"`
+	IsBoolean bool          `json:"isBoolean"`
+	Polarity  HabitPolarity `json:"polarity"`
+	Schedule  `json:"schedule"`
+	Target    `json:"target"`
+	// Unit labels the values of a numeric habit, such as "km" or "pages".
+	Unit *string `json:"unit,omitempty"`
+	// Habits are listed pinned first, then by ascending Position.
+	Position int            `json:"position"`
+	Pinned   bool           `json:"pinned"`
+	Category *HabitCategory `json:"category,omitempty"`
+	Tags     []Tag          `json:"tags,omitempty" db:"-"`
+	// SourceHabitID is the habit this one was cloned from, if any. Adoptions counts
+	// the other users who cloned this habit; it is only filled in for the owner.
+	SourceHabitID *uuid.UUID `json:"sourceHabitId,omitempty" db:"source_habit_id"`
+	Adoptions     int        `json:"adoptions,omitempty" db:"-"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	// ArchivedAt is set while the habit is hidden from the profile, DeletedAt while
+	// it sits in the trash awaiting restore or purge.
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+	DeletedAt  *time.Time `json:"deletedAt,omitempty"`
+}
+
+// LogStatus records how a day went. A skipped day is excused: it neither counts
+// towards nor breaks a streak.
+type LogStatus string
+
+const (
+	LogDone    LogStatus = "done"
+	LogSkipped LogStatus = "skipped"
+	LogFailed  LogStatus = "failed"
+)
+
 type HabitLog struct {
-	ID        uuid.UUID `json:"id"`
-	HabitID   uuid.UUID `json:"habitId"`
-	LogDate   time.Time `json:"date"`
-	Value     int       `json:"value"`
+	ID      uuid.UUID `json:"id"`
+	HabitID uuid.UUID `json:"habitId"`
+	LogDate time.Time `json:"date"`
+	Value   float64   `json:"value"`
+	Status  LogStatus `json:"status"`
+	// Note is an optional journal entry. Private notes are only shown to the owner.
+	Note        *string   `json:"note,omitempty"`
+	NotePrivate bool      `json:"notePrivate"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	// Completion is the fraction of the habit's target reached, derived from Value.
+	Completion float64 `json:"completion" db:"-"`
+	Completed  bool    `json:"completed" db:"-"`
+}
+
+// HabitLogRevision records one change to the log of a day. The old fields are unset
+// when the log was created and the new fields when it was deleted.
+type HabitLogRevision struct {
+	ID        int64      `json:"id"`
+	HabitID   uuid.UUID  `json:"habitId"`
+	LogDate   time.Time  `json:"date"`
+	OldValue  *float64   `json:"oldValue"`
+	OldStatus *LogStatus `json:"oldStatus"`
+	NewValue  *float64   `json:"newValue"`
+	NewStatus *LogStatus `json:"newStatus"`
+	// ActorID is the user who made the change, unset if they deleted their account.
+	ActorID *uuid.UUID `json:"actorId"`
+	// Undoes is the revision this one reverted, if it was an undo.
+	Undoes    *int64    `json:"undoes,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Streak summarises a habit's completion history relative to the owner's current day.
+// Current and Longest count schedule periods: days for daily and weekday habits,
+// weeks or months for weekly and monthly ones. For negative habits they count clean
+// days between relapses.
+type Streak struct {
+	Current         int        `json:"current"`
+	Longest         int        `json:"longest"`
+	LastCompletedOn *time.Time `json:"lastCompletedOn,omitempty"`
+	LastRelapseOn   *time.Time `json:"lastRelapseOn,omitempty"`
+	StartedOn       *time.Time `json:"startedOn,omitempty"`
 }
 
 type HabitWithLogs struct {
 	Habit
-	Logs []HabitLog `json:"logs"`
+	Logs   []HabitLog `json:"logs"`
+	Streak Streak     `json:"streak"`
+}
+
+// HabitLogPage is one page of a habit's logs, newest first. NextCursor is set when
+// older logs remain.
+type HabitLogPage struct {
+	Logs       []HabitLog `json:"logs"`
+	NextCursor *string    `json:"nextCursor,omitempty"`
+}
+
+// HabitConversionPreview describes what converting a habit between the boolean and
+// numeric types would do to its history.
+type HabitConversionPreview struct {
+	IsBoolean bool   `json:"isBoolean"`
+	Target    Target `json:"target"`
+	// Logs counts all of the habit's logs, ChangedLogs those whose value would change.
+	Logs            int `json:"logs"`
+	ChangedLogs     int `json:"changedLogs"`
+	CompletedBefore int `json:"completedBefore"`
+	CompletedAfter  int `json:"completedAfter"`
+}
+
+// HabitTemplate is a ready-made habit that users can adopt. Curated templates come
+// from the server's catalogue and have a readable ID; the others are habits users
+// published, identified by a UUID.
+type HabitTemplate struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	ColorHue    int            `json:"colorHue"`
+	IsBoolean   bool           `json:"isBoolean"`
+	Polarity    HabitPolarity  `json:"polarity,omitempty"`
+	Schedule    Schedule       `json:"schedule"`
+	Target      Target         `json:"target"`
+	Unit        *string        `json:"unit,omitempty"`
+	Category    *HabitCategory `json:"category,omitempty"`
+	// Author is the user who published the template, nil for curated ones.
+	Author    *PublicUser `json:"author,omitempty"`
+	CreatedAt *time.Time  `json:"createdAt,omitempty"`
+}
+
+type AchievementID string
+
+const (
+	AchievementFirstLog    AchievementID = "first_log"
+	AchievementStreak7     AchievementID = "streak_7"
+	AchievementStreak30    AchievementID = "streak_30"
+	AchievementStreak100   AchievementID = "streak_100"
+	AchievementStreak365   AchievementID = "streak_365"
+	AchievementTotal1000   AchievementID = "total_1000"
+	AchievementFollowers10 AchievementID = "followers_10"
+)
+
+// Achievement is a milestone badge users earn once.
+type Achievement struct {
+	ID          AchievementID `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+}
+
+type UserAchievement struct {
+	Achievement
+	EarnedAt time.Time `json:"earnedAt"`
+}
+
+// LogBatchOutcome is what a batch write did to one of its entries.
+type LogBatchOutcome string
+
+const (
+	BatchCreated   LogBatchOutcome = "created"
+	BatchUpdated   LogBatchOutcome = "updated"
+	BatchCleared   LogBatchOutcome = "cleared"
+	BatchUnchanged LogBatchOutcome = "unchanged"
+	BatchInvalid   LogBatchOutcome = "invalid"
+)
+
+// LogBatchResult reports on the entry at Index of a batch of logs. Error explains why
+// an invalid entry was rejected.
+type LogBatchResult struct {
+	Index   int             `json:"index"`
+	Date    string          `json:"date"`
+	Outcome LogBatchOutcome `json:"outcome"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// ImportSummary describes what an import did, or on a dry run what it would do.
+type ImportSummary struct {
+	Format string          `json:"format"`
+	DryRun bool            `json:"dryRun"`
+	Habits []ImportedHabit `json:"habits"`
+}
+
+// ImportedHabit is one habit of an import. Habits are matched to the user's existing
+// habits by name, so importing the same file twice creates nothing new.
+type ImportedHabit struct {
+	Name string `json:"name"`
+	// HabitID is the existing habit the logs go to, or the habit created for them.
+	HabitID   *uuid.UUID `json:"habitId,omitempty"`
+	Created   bool       `json:"created"`
+	IsBoolean bool       `json:"isBoolean"`
+	ColorHue  int        `json:"colorHue"`
+	Logs      int        `json:"logs"`
+	// NewLogs counts the days that had no log before the import, the only days it
+	// writes. It is zero on a dry run.
+	NewLogs int `json:"newLogs"`
+	// Skipped is set when the name matched an archived habit or an existing habit of
+	// the other type, yes/no or numeric. None of its logs are imported.
+	Skipped bool       `json:"skipped"`
+	From    *time.Time `json:"from,omitempty"`
+	To      *time.Time `json:"to,omitempty"`
 }
 
 // LeaderboardEntry is the model returned directly from the database query
@@ -49,10 +315,60 @@ type LeaderboardEntry struct {
 	User            PublicUser      `json:"user" db:"user"`
 	TotalLoggedDays int64           `json:"totalLoggedDays" db:"total_logged_days"`
 	Habits          []HabitWithLogs `json:"habits" db:"habits"`
+	// Timezone is the user's timezone, used to derive their local day. It is not exposed.
+	Timezone string `json:"-" db:"timezone"`
 }
 
 // ExploreEntry is the model returned directly from the database query
 type ExploreEntry struct {
-	User  PublicUser    `json:"user" db:"user"`
-	Habit HabitWithLogs `json:"habit" db:"habit"`
+	User     PublicUser    `json:"user" db:"user"`
+	Habit    HabitWithLogs `json:"habit" db:"habit"`
+	Timezone string        `json:"-" db:"timezone"`
+}
+
+// HabitStats aggregates a habit's logs over an inclusive date range.
+type HabitStats struct {
+	HabitID       uuid.UUID `json:"habitId"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	LoggedDays    int       `json:"loggedDays"`
+	CompletedDays int       `json:"completedDays"`
+	SkippedDays   int       `json:"skippedDays"`
+	// RelapseDays counts the logged days of a negative habit, whose CompletedDays
+	// are the clean days in the range.
+	RelapseDays    int            `json:"relapseDays"`
+	CompletionRate float64        `json:"completionRate"`
+	Values         *ValueStats    `json:"values,omitempty"`
+	ByWeekday      []WeekdayStats `json:"byWeekday"`
+	ByWeek         []PeriodStats  `json:"byWeek"`
+	ByMonth        []PeriodStats  `json:"byMonth"`
+	BestWeek       *PeriodStats   `json:"bestWeek,omitempty"`
+	WorstWeek      *PeriodStats   `json:"worstWeek,omitempty"`
+	// PerfectDays counts the days on which every habit of the owner that was due got done.
+	PerfectDays int `json:"perfectDays"`
+}
+
+// ValueStats summarises the values logged for a numeric habit.
+type ValueStats struct {
+	Total  float64 `json:"total"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	Max    float64 `json:"max"`
+}
+
+type WeekdayStats struct {
+	Weekday       int     `json:"weekday"`
+	LoggedDays    int     `json:"loggedDays"`
+	CompletedDays int     `json:"completedDays"`
+	SkippedDays   int     `json:"skippedDays"`
+	Total         float64 `json:"total"`
+}
+
+// PeriodStats aggregates the logs of a week or month starting at Start.
+type PeriodStats struct {
+	Start         time.Time `json:"start"`
+	LoggedDays    int       `json:"loggedDays"`
+	CompletedDays int       `json:"completedDays"`
+	SkippedDays   int       `json:"skippedDays"`
+	Total         float64   `json:"total"`
 }
@@ -0,0 +1,111 @@
+// Package ical writes iCalendar (RFC 5545) feeds of all-day events.
+package ical
+
+import (
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest a content line may be before it has to be folded.
+const maxLineOctets = 75
+
+const (
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405Z"
+)
+
+type Calendar struct {
+	// ProdID identifies the product that created the calendar.
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Event is an all-day event.
+type Event struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+	// Stamp is when the event was last modified.
+	Stamp time.Time
+}
+
+// Write writes the calendar to w.
+func (c Calendar) Write(w io.Writer) error {
+	lw := &lineWriter{w: w}
+	lw.line("BEGIN:VCALENDAR")
+	lw.line("VERSION:2.0")
+	lw.line("PRODID:" + c.ProdID)
+	lw.line("CALSCALE:GREGORIAN")
+	lw.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		lw.line("X-WR-CALNAME:" + Escape(c.Name))
+	}
+	for _, e := range c.Events {
+		lw.line("BEGIN:VEVENT")
+		lw.line("UID:" + Escape(e.UID))
+		lw.line("DTSTAMP:" + e.Stamp.UTC().Format(dateTimeFormat))
+		lw.line("DTSTART;VALUE=DATE:" + e.Date.Format(dateFormat))
+		lw.line("DTEND;VALUE=DATE:" + e.Date.AddDate(0, 0, 1).Format(dateFormat))
+		lw.line("SUMMARY:" + Escape(e.Summary))
+		if e.Description != "" {
+			lw.line("DESCRIPTION:" + Escape(e.Description))
+		}
+		lw.line("TRANSP:TRANSPARENT")
+		lw.line("END:VEVENT")
+	}
+	lw.line("END:VCALENDAR")
+	return lw.err
+}
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// Escape escapes a TEXT property value.
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// Fold splits a content line into lines of at most 75 octets, each continuation line
+// starting with a space, without splitting a UTF-8 character. The lines are joined
+// and terminated with CRLF.
+func Fold(line string) string {
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space counts towards the length of continuation lines.
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// lineWriter writes folded content lines, remembering the first error.
+type lineWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (lw *lineWriter) line(s string) {
+	if lw.err != nil {
+		return
+	}
+	_, lw.err = io.WriteString(lw.w, Fold(s))
+}
@@ -0,0 +1,89 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscape(t *testing.T) {
+	tests := map[string]string{
+		"plain":               "plain",
+		"a, b; c":             `a\, b\; c`,
+		`back\slash`:          `back\\slash`,
+		"two\nlines":          `two\nlines`,
+		"windows\r\nnewline":  `windows\nnewline`,
+		`already \n escaped,`: `already \\n escaped\,`,
+		"ünïcödé stays as is": "ünïcödé stays as is",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, Escape(in), in)
+	}
+}
+
+func TestFold(t *testing.T) {
+	t.Run("short lines are not folded", func(t *testing.T) {
+		assert.Equal(t, "SUMMARY:Run\r\n", Fold("SUMMARY:Run"))
+	})
+
+	t.Run("exactly 75 octets is not folded", func(t *testing.T) {
+		line := strings.Repeat("a", 75)
+		assert.Equal(t, line+"\r\n", Fold(line))
+	})
+
+	t.Run("long lines are folded at 75 octets", func(t *testing.T) {
+		line := strings.Repeat("a", 75) + strings.Repeat("b", 74) + "c"
+		assert.Equal(t, strings.Repeat("a", 75)+"\r\n "+strings.Repeat("b", 74)+"\r\n c\r\n", Fold(line))
+	})
+
+	t.Run("multi-byte characters are not split", func(t *testing.T) {
+		// Each "é" is two octets, so the 38th would straddle the 75 octet limit.
+		line := "X" + strings.Repeat("é", 40)
+		folded := Fold(line)
+		for _, l := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+			assert.LessOrEqual(t, len(l), 75)
+			assert.True(t, utf8.ValidString(l), "line %q is not valid UTF-8", l)
+		}
+		assert.Equal(t, line, strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", ""))
+	})
+}
+
+func TestCalendarWrite(t *testing.T) {
+	cal := Calendar{
+		ProdID: "-//PeakStreak//Habits//EN",
+		Name:   "Run, daily",
+		Events: []Event{{
+			UID:         "1234@peakstreak",
+			Date:        time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+			Summary:     "Run",
+			Description: "Value: 5",
+			Stamp:       time.Date(2024, 3, 1, 8, 30, 0, 0, time.FixedZone("CET", 3600)),
+		}},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, cal.Write(&buf))
+
+	assert.Equal(t, strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//PeakStreak//Habits//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		`X-WR-CALNAME:Run\, daily`,
+		"BEGIN:VEVENT",
+		"UID:1234@peakstreak",
+		"DTSTAMP:20240301T073000Z",
+		"DTSTART;VALUE=DATE:20240229",
+		"DTEND;VALUE=DATE:20240301",
+		"SUMMARY:Run",
+		"DESCRIPTION:Value: 5",
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")+"\r\n", buf.String())
+}
@@ -0,0 +1,141 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/axseem/peakstreak/internal/domain"
+)
+
+// csvColumns locates the columns of a generic CSV file by their header names.
+type csvColumns struct {
+	date, habit, value, status int
+}
+
+func readCSVHeader(header []string) (csvColumns, bool) {
+	cols := csvColumns{date: -1, habit: -1, value: -1, status: -1}
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case "date":
+			cols.date = i
+		case "habit":
+			cols.habit = i
+		case "value":
+			cols.value = i
+		case "status":
+			cols.status = i
+		}
+	}
+	return cols, cols.date >= 0 && cols.habit >= 0 && cols.value >= 0
+}
+
+func isGenericCSV(head []byte) bool {
+	line, _, _ := bytes.Cut(head, []byte("\n"))
+	header, err := csv.NewReader(bytes.NewReader(line)).Read()
+	if err != nil {
+		return false
+	}
+	_, ok := readCSVHeader(header)
+	return ok
+}
+
+// parseCSV reads a CSV file with a header naming date, habit and value columns, and
+// optionally a status column. Dates are formatted as YYYY-MM-DD. A habit whose values
+// are all 0 or 1 is imported as a yes/no habit.
+func parseCSV(r io.Reader) ([]Habit, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, lineError(1, "could not read header")
+	}
+	cols, ok := readCSVHeader(header)
+	if !ok {
+		return nil, lineError(1, "header must name date, habit and value columns")
+	}
+
+	var order []string
+	names := make(map[string]string)
+	histories := make(map[string]history)
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, lineError(line, "%v", err)
+		}
+		if len(record) <= max(cols.date, cols.habit, cols.value) {
+			return nil, lineError(line, "missing columns")
+		}
+
+		name := habitName(strings.TrimSpace(record[cols.habit]))
+		if name == "" {
+			return nil, lineError(line, "habit name is empty")
+		}
+		date, err := parseDate(strings.TrimSpace(record[cols.date]))
+		if err != nil {
+			return nil, lineError(line, "invalid date %q, please use YYYY-MM-DD", record[cols.date])
+		}
+
+		entry := Entry{Date: date, Status: domain.LogDone}
+		if cols.status >= 0 && cols.status < len(record) {
+			if status := strings.ToLower(strings.TrimSpace(record[cols.status])); status != "" {
+				entry.Status = domain.LogStatus(status)
+			}
+		}
+		switch entry.Status {
+		case domain.LogDone:
+			value, err := strconv.ParseFloat(strings.TrimSpace(record[cols.value]), 64)
+			if err != nil || value < 0 || math.IsInf(value, 0) {
+				return nil, lineError(line, "invalid value %q", record[cols.value])
+			}
+			if value > maxValue {
+				return nil, lineError(line, "value %q exceeds %d", record[cols.value], maxValue)
+			}
+			entry.Value = value
+		case domain.LogSkipped, domain.LogFailed:
+		default:
+			return nil, lineError(line, "invalid status %q", record[cols.status])
+		}
+
+		key := strings.ToLower(name)
+		if _, ok := histories[key]; !ok {
+			order = append(order, key)
+			names[key] = name
+			histories[key] = make(history)
+		}
+		// A zero value records nothing; it creates the habit but leaves the day empty.
+		if entry.Status != domain.LogDone || entry.Value > 0 {
+			histories[key].add(entry)
+		}
+	}
+
+	habits := make([]Habit, 0, len(order))
+	for _, key := range order {
+		entries := histories[key].entries()
+		isBoolean := true
+		for _, e := range entries {
+			if e.Status == domain.LogDone && e.Value != 1 {
+				isBoolean = false
+				break
+			}
+		}
+		habits = append(habits, Habit{
+			Name:      names[key],
+			ColorHue:  hueOf(names[key]),
+			IsBoolean: isBoolean,
+			Schedule:  domain.Schedule{Frequency: domain.FrequencyDaily},
+			Target:    domain.Target{Mode: domain.TargetAtLeast},
+			Entries:   entries,
+		})
+	}
+	return habits, nil
+}
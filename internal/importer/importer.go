@@ -0,0 +1,142 @@
+// Package importer reads habit histories exported by other apps.
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/axseem/peakstreak/internal/domain"
+)
+
+type Format string
+
+const (
+	// FormatLoop is the zip archive exported by Loop Habit Tracker.
+	FormatLoop Format = "loop"
+	// FormatCSV is a CSV file with date, habit and value columns.
+	FormatCSV Format = "csv"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unrecognised import format")
+	ErrInvalidFile   = errors.New("invalid import file")
+)
+
+// maxNameLength, maxUnitLength and maxValue match the longest habit name and unit,
+// and the largest log value, the API accepts.
+const (
+	maxNameLength = 100
+	maxUnitLength = 16
+	maxValue      = 999999999999
+)
+
+// Habit is a habit found in an import file, together with its history.
+type Habit struct {
+	Name      string
+	ColorHue  int
+	IsBoolean bool
+	Schedule  domain.Schedule
+	Target    domain.Target
+	Unit      string
+	// Entries are in date order, with at most one per day.
+	Entries []Entry
+}
+
+// Entry is one day of an imported habit's history.
+type Entry struct {
+	Date   time.Time
+	Value  float64
+	Status domain.LogStatus
+}
+
+var zipMagic = []byte("PK\x03\x04")
+
+// Detect works out the format of an import file.
+func Detect(r io.ReaderAt, size int64) (Format, error) {
+	head := make([]byte, 512)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	head = head[:n]
+
+	if bytes.HasPrefix(head, zipMagic) {
+		if isLoopArchive(r, size) {
+			return FormatLoop, nil
+		}
+		return "", ErrUnknownFormat
+	}
+	if isGenericCSV(head) {
+		return FormatCSV, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// Parse detects the format of an import file and reads the habits in it.
+func Parse(r io.ReaderAt, size int64) (Format, []Habit, error) {
+	format, err := Detect(r, size)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var habits []Habit
+	switch format {
+	case FormatLoop:
+		habits, err = parseLoop(r, size)
+	case FormatCSV:
+		habits, err = parseCSV(io.NewSectionReader(r, 0, size))
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return format, habits, nil
+}
+
+// history collects the entries of a habit, keeping the last one seen for each day.
+type history map[time.Time]Entry
+
+func (h history) add(e Entry) {
+	h[e.Date] = e
+}
+
+func (h history) entries() []Entry {
+	entries := make([]Entry, 0, len(h))
+	for _, e := range h {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Date.Before(entries[j].Date) })
+	return entries
+}
+
+func parseDate(s string) (time.Time, error) {
+	return time.Parse(time.DateOnly, s)
+}
+
+// habitName trims a name to the longest one the API accepts.
+func habitName(name string) string {
+	return truncate(name, maxNameLength)
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// hueOf picks a stable colour for a habit that comes without one.
+func hueOf(name string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % 360)
+}
+
+func lineError(line int, format string, args ...any) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidFile, line, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func zipOf(t *testing.T, files map[string]string) *bytes.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestParse_Loop(t *testing.T) {
+	r := zipOf(t, map[string]string{
+		"Habits.csv": "Position,Name,Type,Question,Description,FrequencyNumerator,FrequencyDenominator,Color,Unit,Target Type,Target Value,Archived?\n" +
+			"001,Meditate,YES_NO,,,1,1,#388E3C,,AT_LEAST,0,false\n" +
+			"002,Push-ups,NUMERICAL,,,3,7,#1976D2,reps,AT_LEAST,20,false\n" +
+			"003,Run,NUMERICAL,,,1,1,#1976D2,km,AT_LEAST,2.5,false\n",
+		"001 Meditate/Checkmarks.csv": "2024-03-03,2\n2024-03-02,1\n2024-03-01,3\n2024-02-29,0\n",
+		"002 Push-ups/Checkmarks.csv": "2024-03-02,25000\n2024-03-01,0\n",
+		"003 Run/Checkmarks.csv":      "2024-03-02,5250\n",
+	})
+
+	format, habits, err := Parse(r, r.Size())
+
+	require.NoError(t, err)
+	assert.Equal(t, FormatLoop, format)
+	require.Len(t, habits, 3)
+
+	meditate := habits[0]
+	assert.Equal(t, "Meditate", meditate.Name)
+	assert.True(t, meditate.IsBoolean)
+	assert.Equal(t, 123, meditate.ColorHue)
+	assert.Equal(t, domain.FrequencyDaily, meditate.Schedule.Frequency)
+	assert.Equal(t, []Entry{
+		{Date: day("2024-03-01"), Status: domain.LogSkipped},
+		{Date: day("2024-03-03"), Value: 1, Status: domain.LogDone},
+	}, meditate.Entries)
+
+	pushups := habits[1]
+	assert.False(t, pushups.IsBoolean)
+	assert.Equal(t, domain.Schedule{Frequency: domain.FrequencyWeekly, TimesPerPeriod: 3}, pushups.Schedule)
+	require.NotNil(t, pushups.Target.Value)
+	assert.Equal(t, 20.0, *pushups.Target.Value)
+	assert.Equal(t, "reps", pushups.Unit)
+	assert.Equal(t, []Entry{{Date: day("2024-03-02"), Value: 25, Status: domain.LogDone}}, pushups.Entries)
+
+	run := habits[2]
+	require.NotNil(t, run.Target.Value)
+	assert.Equal(t, 2.5, *run.Target.Value)
+	assert.Equal(t, []Entry{{Date: day("2024-03-02"), Value: 5.25, Status: domain.LogDone}}, run.Entries)
+}
+
+func TestParse_CSV(t *testing.T) {
+	r := bytes.NewReader([]byte("Date,Habit,Value\n" +
+		"2024-03-01,Read,30\n" +
+		"2024-03-01,Floss,1\n" +
+		"2024-03-02,read,45\n" +
+		"2024-03-02,Floss,0\n" +
+		"2024-03-01,Read,35\n"))
+
+	format, habits, err := Parse(r, r.Size())
+
+	require.NoError(t, err)
+	assert.Equal(t, FormatCSV, format)
+	require.Len(t, habits, 2)
+	assert.Equal(t, "Read", habits[0].Name)
+	assert.False(t, habits[0].IsBoolean)
+	assert.Equal(t, []Entry{
+		{Date: day("2024-03-01"), Value: 35, Status: domain.LogDone},
+		{Date: day("2024-03-02"), Value: 45, Status: domain.LogDone},
+	}, habits[0].Entries)
+	assert.Equal(t, "Floss", habits[1].Name)
+	assert.True(t, habits[1].IsBoolean)
+	assert.Len(t, habits[1].Entries, 1)
+}
+
+func TestParse_CSVInvalidRow(t *testing.T) {
+	r := bytes.NewReader([]byte("date,habit,value\n2024-03-01,Read,30\n01/03/2024,Read,10\n"))
+
+	_, _, err := Parse(r, r.Size())
+
+	assert.ErrorIs(t, err, ErrInvalidFile)
+	assert.Contains(t, err.Error(), "line 3")
+}
+
+func TestParse_CSVValueTooLarge(t *testing.T) {
+	r := bytes.NewReader([]byte("date,habit,value\n2024-03-01,Read,30\n2024-03-02,Read,1e20\n"))
+
+	_, _, err := Parse(r, r.Size())
+
+	assert.ErrorIs(t, err, ErrInvalidFile)
+	assert.Contains(t, err.Error(), "line 3")
+}
+
+func TestDetect_Unknown(t *testing.T) {
+	for name, r := range map[string]*bytes.Reader{
+		"unrelated csv":          bytes.NewReader([]byte("name,email\njane,jane@example.com\n")),
+		"zip without Habits.csv": zipOf(t, map[string]string{"notes.txt": "hello"}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Detect(r, r.Size())
+			assert.ErrorIs(t, err, ErrUnknownFormat)
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/axseem/peakstreak/internal/domain"
+)
+
+// Loop Habit Tracker stores checkmarks of yes/no habits as these values. Automatic
+// checkmarks are ones Loop infers from the habit's frequency, so they are not imported.
+const (
+	loopUnknown   = -1
+	loopNo        = 0
+	loopYesAuto   = 1
+	loopYesManual = 2
+	loopSkip      = 3
+)
+
+// Loop stores numerical values multiplied by this factor.
+const loopValueScale = 1000
+
+// loopPalette is the colour palette of older Loop exports, which store a palette
+// index rather than a hex colour.
+var loopPalette = []string{
+	"#D32F2F", "#E64A19", "#F57C00", "#FF8F00", "#F9A825", "#AFB42B", "#7CB342",
+	"#388E3C", "#00897B", "#00ACC1", "#039BE5", "#1976D2", "#303F9F", "#5E35B1",
+	"#8E24AA", "#D81B60", "#5D4037", "#303030", "#757575", "#AAAAAA",
+}
+
+func isLoopArchive(r io.ReaderAt, size int64) bool {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return false
+	}
+	return findLoopHabits(zr) != nil
+}
+
+func findLoopHabits(zr *zip.Reader) *zip.File {
+	for _, f := range zr.File {
+		if path.Base(f.Name) == "Habits.csv" {
+			return f
+		}
+	}
+	return nil
+}
+
+// parseLoop reads a Loop Habit Tracker export: Habits.csv lists the habits, and a
+// folder per habit, named after its position and name, holds its Checkmarks.csv.
+func parseLoop(r io.ReaderAt, size int64) ([]Habit, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	habitsFile := findLoopHabits(zr)
+	root := path.Dir(habitsFile.Name)
+
+	records, err := readZipCSV(habitsFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, lineError(1, "Habits.csv is empty")
+	}
+	col := make(map[string]int)
+	for i, name := range records[0] {
+		col[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	field := func(record []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := col[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+		}
+		return ""
+	}
+	if _, ok := col["Name"]; !ok {
+		return nil, lineError(1, "Habits.csv has no Name column")
+	}
+
+	// Checkmark files are matched to habits by the position their folder starts with.
+	checkmarks := make(map[int]*zip.File)
+	for _, f := range zr.File {
+		if path.Base(f.Name) != "Checkmarks.csv" || path.Dir(path.Dir(f.Name)) != root {
+			continue
+		}
+		prefix, _, _ := strings.Cut(path.Base(path.Dir(f.Name)), " ")
+		if position, err := strconv.Atoi(prefix); err == nil {
+			checkmarks[position] = f
+		}
+	}
+
+	habits := make([]Habit, 0, len(records)-1)
+	for i, record := range records[1:] {
+		line := i + 2
+		name := habitName(field(record, "Name"))
+		if name == "" {
+			return nil, lineError(line, "habit name is empty")
+		}
+		numerical := field(record, "Type") == "NUMERICAL" || field(record, "Type") == "1"
+
+		habit := Habit{
+			Name:      name,
+			ColorHue:  loopHue(field(record, "Color"), name),
+			IsBoolean: !numerical,
+			Schedule:  loopSchedule(field(record, "FrequencyNumerator", "NumRepetitions"), field(record, "FrequencyDenominator", "Interval")),
+			Target:    domain.Target{Mode: domain.TargetAtLeast},
+		}
+		if numerical {
+			habit.Target = loopTarget(field(record, "Target Type"), field(record, "Target Value"))
+			habit.Unit = truncate(field(record, "Unit"), maxUnitLength)
+		}
+
+		position, _ := strconv.Atoi(field(record, "Position"))
+		if f, ok := checkmarks[position]; ok {
+			if habit.Entries, err = readLoopCheckmarks(f, numerical); err != nil {
+				return nil, err
+			}
+		}
+		habits = append(habits, habit)
+	}
+	return habits, nil
+}
+
+func readZipCSV(f *zip.File) ([][]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	defer rc.Close()
+
+	cr := csv.NewReader(rc)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	return records, nil
+}
+
+// readLoopCheckmarks reads the date,value rows of a habit's Checkmarks.csv. Rows
+// that do not start with a date, such as a header, are ignored.
+func readLoopCheckmarks(f *zip.File, numerical bool) ([]Entry, error) {
+	records, err := readZipCSV(f)
+	if err != nil {
+		return nil, err
+	}
+
+	h := make(history)
+	for i, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		date, err := parseDate(strings.TrimSpace(record[0]))
+		if err != nil {
+			continue
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			continue
+		}
+
+		if numerical {
+			v := float64(value) / loopValueScale
+			if v > maxValue {
+				return nil, lineError(i+1, "%s: value %v exceeds %d", f.Name, v, maxValue)
+			}
+			if v > 0 {
+				h.add(Entry{Date: date, Value: v, Status: domain.LogDone})
+			}
+			continue
+		}
+		switch value {
+		case loopYesManual:
+			h.add(Entry{Date: date, Value: 1, Status: domain.LogDone})
+		case loopSkip:
+			h.add(Entry{Date: date, Status: domain.LogSkipped})
+		case loopYesAuto, loopNo, loopUnknown:
+		}
+	}
+	return h.entries(), nil
+}
+
+// loopSchedule maps Loop's "numerator times every denominator days" frequency onto the
+// closest schedule.
+func loopSchedule(numerator, denominator string) domain.Schedule {
+	num, err1 := strconv.Atoi(numerator)
+	den, err2 := strconv.Atoi(denominator)
+	if err1 != nil || err2 != nil || num < 1 || den <= num {
+		return domain.Schedule{Frequency: domain.FrequencyDaily}
+	}
+	if den <= 7 {
+		times := int(math.Round(float64(num) * 7 / float64(den)))
+		return domain.Schedule{Frequency: domain.FrequencyWeekly, TimesPerPeriod: min(max(times, 1), 7)}
+	}
+	times := int(math.Round(float64(num) * 30 / float64(den)))
+	return domain.Schedule{Frequency: domain.FrequencyMonthly, TimesPerPeriod: min(max(times, 1), 31)}
+}
+
+func loopTarget(targetType, targetValue string) domain.Target {
+	target := domain.Target{Mode: domain.TargetAtLeast}
+	if targetType == "AT_MOST" {
+		target.Mode = domain.TargetAtMost
+	}
+	if v, err := strconv.ParseFloat(targetValue, 64); err == nil && v > 0 {
+		target.Value = &v
+	}
+	return target
+}
+
+// loopHue converts a Loop colour, either a hex colour or a palette index, to a hue.
+func loopHue(color, name string) int {
+	if i, err := strconv.Atoi(color); err == nil && i >= 0 && i < len(loopPalette) {
+		color = loopPalette[i]
+	}
+	hex := strings.TrimPrefix(color, "#")
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return hueOf(name)
+	}
+	return hueFromRGB(float64(rgb>>16&0xff)/255, float64(rgb>>8&0xff)/255, float64(rgb&0xff)/255)
+}
+
+func hueFromRGB(r, g, b float64) int {
+	hi, lo := max(r, g, b), min(r, g, b)
+	delta := hi - lo
+	if delta == 0 {
+		return 0
+	}
+	var hue float64
+	switch hi {
+	case r:
+		hue = math.Mod((g-b)/delta, 6)
+	case g:
+		hue = (b-r)/delta + 2
+	default:
+		hue = (r-g)/delta + 4
+	}
+	hue *= 60
+	if hue < 0 {
+		hue += 360
+	}
+	return int(math.Round(hue)) % 360
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/axseem/peakstreak/internal/domain"
 	"github.com/google/uuid"
@@ -24,8 +25,8 @@ func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
 const UNIQUE_VIOLATION_CODE = "23505"
 
 func (r *PostgresRepository) CreateUser(ctx context.Context, user *domain.User) error {
-	query := `INSERT INTO users (id, username, email, hashed_password) VALUES ($1, $2, $3, $4)`
-	_, err := r.db.Exec(ctx, query, user.ID, user.Username, user.Email, user.HashedPassword)
+	query := `INSERT INTO users (id, username, email, hashed_password, timezone) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.Exec(ctx, query, user.ID, user.Username, user.Email, user.HashedPassword, user.Timezone)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == UNIQUE_VIOLATION_CODE {
@@ -42,9 +43,9 @@ func (r *PostgresRepository) CreateUser(ctx context.Context, user *domain.User)
 }
 
 func (r *PostgresRepository) GetUserByEmailOrUsername(ctx context.Context, identifier string) (*domain.User, error) {
-	query := `SELECT id, username, email, hashed_password, avatar_url, created_at FROM users WHERE username = $1 OR email = $1`
+	query := `SELECT id, username, email, hashed_password, avatar_url, timezone, created_at, token_generation FROM users WHERE username = $1 OR email = $1`
 	var user domain.User
-	err := r.db.QueryRow(ctx, query, identifier).Scan(&user.ID, &user.Username, &user.Email, &user.HashedPassword, &user.AvatarURL, &user.CreatedAt)
+	err := r.db.QueryRow(ctx, query, identifier).Scan(&user.ID, &user.Username, &user.Email, &user.HashedPassword, &user.AvatarURL, &user.Timezone, &user.CreatedAt, &user.TokenGeneration)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrUserNotFound
@@ -55,9 +56,9 @@ func (r *PostgresRepository) GetUserByEmailOrUsername(ctx context.Context, ident
 }
 
 func (r *PostgresRepository) GetUserByUsername(ctx context.Context, username string) (*domain.User, error) {
-	query := `SELECT id, username, email, hashed_password, avatar_url, created_at FROM users WHERE username = $1`
+	query := `SELECT id, username, email, hashed_password, avatar_url, timezone, created_at FROM users WHERE username = $1`
 	var user domain.User
-	err := r.db.QueryRow(ctx, query, username).Scan(&user.ID, &user.Username, &user.Email, &user.HashedPassword, &user.AvatarURL, &user.CreatedAt)
+	err := r.db.QueryRow(ctx, query, username).Scan(&user.ID, &user.Username, &user.Email, &user.HashedPassword, &user.AvatarURL, &user.Timezone, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrUserNotFound
@@ -68,7 +69,7 @@ func (r *PostgresRepository) GetUserByUsername(ctx context.Context, username str
 }
 
 func (r *PostgresRepository) GetUsers(ctx context.Context) ([]domain.User, error) {
-	query := `SELECT id, username, email, avatar_url, created_at FROM users`
+	query := `SELECT id, username, email, avatar_url, timezone, created_at FROM users ORDER BY created_at`
 
 	rows, err := r.db.Query(ctx, query)
 	if err != nil {
@@ -76,11 +77,15 @@ func (r *PostgresRepository) GetUsers(ctx context.Context) ([]domain.User, error
 	}
 	defer rows.Close()
 
-	users, err := pgx.CollectRows(rows, pgx.RowToStructByName[domain.User])
-	if err != nil {
-		return nil, err
+	var users []domain.User
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.AvatarURL, &user.Timezone, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
 	}
-	return users, nil
+	return users, rows.Err()
 }
 
 func (r *PostgresRepository) DeleteUser(ctx context.Context, userID uuid.UUID) error {
@@ -118,9 +123,9 @@ func (r *PostgresRepository) SearchUsersByUsername(ctx context.Context, query st
 }
 
 func (r *PostgresRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
-	query := `SELECT id, username, email, avatar_url, created_at FROM users WHERE id = $1`
+	query := `SELECT id, username, email, avatar_url, timezone, created_at FROM users WHERE id = $1`
 	var user domain.User
-	err := r.db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.AvatarURL, &user.CreatedAt)
+	err := r.db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.AvatarURL, &user.Timezone, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrUserNotFound
@@ -143,20 +148,204 @@ func (r *PostgresRepository) GetUserAvatar(ctx context.Context, userID uuid.UUID
 	return avatarURL, nil
 }
 
+func (r *PostgresRepository) GetUserPasswordHash(ctx context.Context, userID uuid.UUID) (string, error) {
+	var hashedPassword string
+	err := r.db.QueryRow(ctx, `SELECT hashed_password FROM users WHERE id = $1`, userID).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+	return hashedPassword, nil
+}
+
+func (r *PostgresRepository) UpdateUserPassword(ctx context.Context, userID uuid.UUID, hashedPassword string) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+        UPDATE users SET hashed_password = $2, token_generation = token_generation + 1
+        WHERE id = $1
+        RETURNING token_generation`
+	var generation int
+	if err := tx.QueryRow(ctx, query, userID, hashedPassword).Scan(&generation); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrUserNotFound
+		}
+		return 0, err
+	}
+
+	query = `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := tx.Exec(ctx, query, userID); err != nil {
+		return 0, err
+	}
+	return generation, tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) GetTokenGeneration(ctx context.Context, userID uuid.UUID) (int, error) {
+	var generation int
+	err := r.db.QueryRow(ctx, `SELECT token_generation FROM users WHERE id = $1`, userID).Scan(&generation)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrUserNotFound
+		}
+		return 0, err
+	}
+	return generation, nil
+}
+
 func (r *PostgresRepository) UpdateUserAvatar(ctx context.Context, userID uuid.UUID, avatarURL *string) error {
 	query := `UPDATE users SET avatar_url = $1 WHERE id = $2`
 	_, err := r.db.Exec(ctx, query, avatarURL, userID)
 	return err
 }
 
+func (r *PostgresRepository) UpdateUserTimezone(ctx context.Context, userID uuid.UUID, timezone string) error {
+	query := `UPDATE users SET timezone = $1 WHERE id = $2`
+	tag, err := r.db.Exec(ctx, query, timezone, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) EnsureCalendarToken(ctx context.Context, userID uuid.UUID, token string) (string, error) {
+	query := `UPDATE users SET calendar_token = COALESCE(calendar_token, $2) WHERE id = $1 RETURNING calendar_token`
+	var current string
+	if err := r.db.QueryRow(ctx, query, userID, token).Scan(&current); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+	return current, nil
+}
+
+func (r *PostgresRepository) SetCalendarToken(ctx context.Context, userID uuid.UUID, token string) error {
+	tag, err := r.db.Exec(ctx, `UPDATE users SET calendar_token = $2 WHERE id = $1`, userID, token)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetUserByCalendarToken(ctx context.Context, token string) (*domain.User, error) {
+	query := `SELECT id, username, email, avatar_url, timezone, created_at FROM users WHERE calendar_token = $1`
+	var user domain.User
+	err := r.db.QueryRow(ctx, query, token).Scan(&user.ID, &user.Username, &user.Email, &user.AvatarURL, &user.Timezone, &user.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// habitColumns lists the columns scanned into a domain.Habit.
+const habitColumns = `id, user_id, name, color_hue, is_boolean, polarity, schedule_frequency, schedule_weekdays, schedule_times,
+    target_value, target_mode, unit, position, pinned, category, source_habit_id, created_at, archived_at, deleted_at`
+
+// habitOrder is the user-defined order in which habits are listed.
+const habitOrder = `pinned DESC, position ASC, created_at DESC`
+
+// activeHabit matches habits (h) that are neither archived nor in the trash.
+const activeHabit = `h.archived_at IS NULL AND h.deleted_at IS NULL`
+
+// ownerToday is the current calendar day in the timezone of the user (u) owning a habit.
+const ownerToday = `(NOW() AT TIME ZONE u.timezone)::date`
+
+// embeddedLogWindow limits the logs embedded in leaderboard and explore responses
+// to the last 53 weeks of the owner's calendar, matching the service's default window.
+const embeddedLogWindow = `hl.log_date BETWEEN ` + ownerToday + ` - 370 AND ` + ownerToday
+
+// doneLog matches logs (hl) recording a positive value for a day marked as done.
+const doneLog = `hl.status = 'done' AND hl.value > 0`
+
+// positiveHabit matches habits (h) to build. Logs of negative habits record relapses,
+// so they are not rewarded on the leaderboard or featured on the explore page.
+const positiveHabit = `h.polarity = 'positive'`
+
+// completedLogCondition matches done logs (hl) up to the owner's (u) current day that
+// meet the target of their habit (h). Under an "at most" target, a zero value stays
+// within the limit; otherwise only positive values count.
+const completedLogCondition = `hl.status = 'done' AND hl.log_date <= ` + ownerToday + ` AND (
+        (NOT h.is_boolean AND h.target_mode = 'at_most' AND hl.value <= h.target_value)
+        OR (hl.value > 0 AND (
+            h.is_boolean OR h.target_value IS NULL
+            OR (h.target_mode = 'at_least' AND hl.value >= h.target_value)
+            OR (h.target_mode = 'exactly' AND hl.value = h.target_value)
+        ))
+    )`
+
 func (r *PostgresRepository) CreateHabit(ctx context.Context, habit *domain.Habit) error {
-	query := `INSERT INTO habits (id, user_id, name, color_hue, is_boolean) VALUES ($1, $2, $3, $4, $5) RETURNING created_at`
-	return r.db.QueryRow(ctx, query, habit.ID, habit.UserID, habit.Name, habit.ColorHue, habit.IsBoolean).Scan(&habit.CreatedAt)
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+        INSERT INTO habits (
+            id, user_id, name, color_hue, is_boolean, polarity,
+            schedule_frequency, schedule_weekdays, schedule_times,
+            target_value, target_mode, unit, category, source_habit_id, position
+        )
+        VALUES (
+            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+            (SELECT COALESCE(MIN(position), 0) - 1 FROM habits WHERE user_id = $2 AND deleted_at IS NULL)
+        )
+        RETURNING position, created_at`
+	err = tx.QueryRow(ctx, query,
+		habit.ID, habit.UserID, habit.Name, habit.ColorHue, habit.IsBoolean, habit.Polarity,
+		habit.Frequency, habit.Weekdays, habit.TimesPerPeriod,
+		habit.Target.Value, habit.Mode, habit.Unit, habit.Category, habit.SourceHabitID,
+	).Scan(&habit.Position, &habit.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if len(habit.Tags) > 0 {
+		if err := setHabitTags(ctx, tx, habit.ID, habit.Tags); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (r *PostgresRepository) GetHabitsByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Habit, error) {
-	query := `SELECT id, user_id, name, color_hue, is_boolean, created_at FROM habits WHERE user_id = $1 ORDER BY created_at DESC`
-	rows, err := r.db.Query(ctx, query, userID)
+	query := `SELECT ` + habitColumns + ` FROM habits h WHERE user_id = $1 AND ` + activeHabit + ` ORDER BY ` + habitOrder
+	return r.queryHabits(ctx, query, userID)
+}
+
+func (r *PostgresRepository) GetArchivedHabitsByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Habit, error) {
+	query := `
+        SELECT ` + habitColumns + ` FROM habits
+        WHERE user_id = $1 AND archived_at IS NOT NULL AND deleted_at IS NULL
+        ORDER BY ` + habitOrder
+	return r.queryHabits(ctx, query, userID)
+}
+
+func (r *PostgresRepository) GetDeletedHabitsByUserID(ctx context.Context, userID uuid.UUID, deletedAfter time.Time) ([]domain.Habit, error) {
+	query := `
+        SELECT ` + habitColumns + ` FROM habits
+        WHERE user_id = $1 AND deleted_at > $2
+        ORDER BY deleted_at DESC`
+	return r.queryHabits(ctx, query, userID, deletedAfter)
+}
+
+func (r *PostgresRepository) queryHabits(ctx context.Context, query string, args ...any) ([]domain.Habit, error) {
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -170,9 +359,12 @@ func (r *PostgresRepository) GetHabitsByUserID(ctx context.Context, userID uuid.
 }
 
 func (r *PostgresRepository) GetHabitByID(ctx context.Context, habitID uuid.UUID) (*domain.Habit, error) {
-	query := `SELECT id, user_id, name, color_hue, is_boolean, created_at FROM habits WHERE id = $1`
-	var habit domain.Habit
-	err := r.db.QueryRow(ctx, query, habitID).Scan(&habit.ID, &habit.UserID, &habit.Name, &habit.ColorHue, &habit.IsBoolean, &habit.CreatedAt)
+	query := `SELECT ` + habitColumns + ` FROM habits WHERE id = $1 AND deleted_at IS NULL`
+	rows, err := r.db.Query(ctx, query, habitID)
+	if err != nil {
+		return nil, err
+	}
+	habit, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[domain.Habit])
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrHabitNotFound
@@ -182,9 +374,114 @@ func (r *PostgresRepository) GetHabitByID(ctx context.Context, habitID uuid.UUID
 	return &habit, nil
 }
 
-func (r *PostgresRepository) UpdateHabit(ctx context.Context, habit *domain.Habit) error {
-	query := `UPDATE habits SET name = $1, color_hue = $2 WHERE id = $3`
-	tag, err := r.db.Exec(ctx, query, habit.Name, habit.ColorHue, habit.ID)
+func (r *PostgresRepository) UpdateHabit(ctx context.Context, habit *domain.Habit, setTags bool) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+        UPDATE habits
+        SET name = $1, color_hue = $2, polarity = $3,
+            schedule_frequency = $4, schedule_weekdays = $5, schedule_times = $6,
+            target_value = $7, target_mode = $8, unit = $9, category = $10
+        WHERE id = $11 AND deleted_at IS NULL`
+	tag, err := tx.Exec(ctx, query,
+		habit.Name, habit.ColorHue, habit.Polarity,
+		habit.Frequency, habit.Weekdays, habit.TimesPerPeriod,
+		habit.Target.Value, habit.Mode, habit.Unit, habit.Category,
+		habit.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrHabitNotFound
+	}
+	if setTags {
+		if _, err := tx.Exec(ctx, `DELETE FROM habit_tags WHERE habit_id = $1`, habit.ID); err != nil {
+			return err
+		}
+		if err := setHabitTags(ctx, tx, habit.ID, habit.Tags); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) ConvertHabitType(ctx context.Context, habit *domain.Habit, doneValue float64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+        UPDATE habits
+        SET is_boolean = $2, target_value = $3, target_mode = $4, unit = $5
+        WHERE id = $1 AND is_boolean <> $2 AND deleted_at IS NULL`
+	tag, err := tx.Exec(ctx, query, habit.ID, habit.IsBoolean, habit.Target.Value, habit.Mode, habit.Unit)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrHabitNotFound
+	}
+
+	value := doneValue
+	if habit.IsBoolean {
+		value = 1
+	}
+	// The owner converting the habit is the actor of the rewritten logs' revisions.
+	query = `
+        WITH log AS (
+            UPDATE habit_logs hl
+            SET value = $2, updated_at = NOW()
+            FROM habit_logs old
+            WHERE old.id = hl.id AND hl.habit_id = $1 AND hl.status = 'done' AND hl.value > 0 AND hl.value <> $2
+            RETURNING hl.log_date, old.value AS old_value, hl.value, hl.status
+        )
+        INSERT INTO habit_log_revisions (habit_id, log_date, old_value, old_status, new_value, new_status, actor_id)
+        SELECT $1, log_date, old_value, status, value, status, $3 FROM log`
+	if _, err := tx.Exec(ctx, query, habit.ID, value, habit.UserID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) DeleteHabit(ctx context.Context, habitID, userID uuid.UUID) error {
+	query := `UPDATE habits SET deleted_at = NOW() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
+	tag, err := r.db.Exec(ctx, query, habitID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrHabitNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) SetHabitArchived(ctx context.Context, habitID, userID uuid.UUID, archived bool) error {
+	query := `
+        UPDATE habits
+        SET archived_at = CASE WHEN $3::boolean THEN COALESCE(archived_at, NOW()) END
+        WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
+	tag, err := r.db.Exec(ctx, query, habitID, userID, archived)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrHabitNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) SetHabitPinned(ctx context.Context, habitID, userID uuid.UUID, pinned bool) error {
+	query := `UPDATE habits SET pinned = $3 WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
+	tag, err := r.db.Exec(ctx, query, habitID, userID, pinned)
 	if err != nil {
 		return err
 	}
@@ -194,43 +491,546 @@ func (r *PostgresRepository) UpdateHabit(ctx context.Context, habit *domain.Habi
 	return nil
 }
 
-func (r *PostgresRepository) DeleteHabit(ctx context.Context, habitID, userID uuid.UUID) error {
-	query := `DELETE FROM habits WHERE id = $1 AND user_id = $2`
-	tag, err := r.db.Exec(ctx, query, habitID, userID)
+func (r *PostgresRepository) ReorderHabits(ctx context.Context, userID uuid.UUID, habitIDs []uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the user's habits so concurrent reorders and new habits cannot interleave.
+	rows, err := tx.Query(ctx, `SELECT id FROM habits WHERE user_id = $1 AND deleted_at IS NULL FOR UPDATE`, userID)
+	if err != nil {
+		return err
+	}
+	owned, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return err
+	}
+	ownedSet := make(map[uuid.UUID]bool, len(owned))
+	for _, id := range owned {
+		ownedSet[id] = true
+	}
+	for _, id := range habitIDs {
+		if !ownedSet[id] {
+			return ErrHabitNotFound
+		}
+	}
+
+	// Listed habits take the first positions in the given order; any others follow
+	// in their previous order.
+	query := `
+        WITH requested AS (
+            SELECT id, ord FROM unnest($2::uuid[]) WITH ORDINALITY AS r(id, ord)
+        ),
+        ranked AS (
+            SELECT h.id, ROW_NUMBER() OVER (
+                ORDER BY r.ord IS NULL, r.ord, h.position, h.created_at DESC
+            ) - 1 AS position
+            FROM habits h
+            LEFT JOIN requested r ON r.id = h.id
+            WHERE h.user_id = $1 AND h.deleted_at IS NULL
+        )
+        UPDATE habits h
+        SET position = ranked.position
+        FROM ranked
+        WHERE h.id = ranked.id`
+	if _, err := tx.Exec(ctx, query, userID, habitIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) RestoreHabit(ctx context.Context, habitID, userID uuid.UUID, deletedAfter time.Time) error {
+	query := `UPDATE habits SET deleted_at = NULL WHERE id = $1 AND user_id = $2 AND deleted_at > $3`
+	tag, err := r.db.Exec(ctx, query, habitID, userID, deletedAfter)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrHabitNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) PurgeDeletedHabits(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM habits WHERE deleted_at <= $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// logColumns lists the habit_logs columns scanned into a domain.HabitLog.
+const logColumns = `id, habit_id, log_date, value, status, note, note_private, created_at, updated_at`
+
+// publicNote is the note of a log (hl) as shown to anyone but its owner.
+const publicNote = `CASE WHEN hl.note_private THEN NULL ELSE hl.note END`
+
+// UpsertHabitLog writes a log, keeping the existing note when log.Note is nil and
+// clearing it when log.Note is empty.
+func (r *PostgresRepository) UpsertHabitLog(ctx context.Context, log *domain.HabitLog, actorID uuid.UUID) error {
+	query := `
+        WITH old AS (
+            SELECT value, status FROM habit_logs WHERE habit_id = $2 AND log_date = $3
+        ), log AS (
+            INSERT INTO habit_logs (id, habit_id, log_date, value, status, note, note_private)
+            VALUES ($1, $2, $3, $4, $7, NULLIF($5, ''), $6)
+            ON CONFLICT (habit_id, log_date) DO UPDATE SET
+                value = EXCLUDED.value,
+                status = EXCLUDED.status,
+                note = CASE WHEN $5::text IS NULL THEN habit_logs.note ELSE EXCLUDED.note END,
+                note_private = CASE WHEN $5::text IS NULL THEN habit_logs.note_private ELSE EXCLUDED.note_private END,
+                updated_at = NOW()
+            RETURNING id, log_date, value, status, note, note_private, created_at, updated_at
+        ), revision AS (
+            INSERT INTO habit_log_revisions (habit_id, log_date, old_value, old_status, new_value, new_status, actor_id)
+            SELECT $2, log.log_date, old.value, old.status, log.value, log.status, $8
+            FROM log LEFT JOIN old ON TRUE
+            WHERE old.value IS DISTINCT FROM log.value OR old.status IS DISTINCT FROM log.status
+        )
+        SELECT id, note, note_private, created_at, updated_at FROM log`
+
+	err := r.db.QueryRow(ctx, query, log.ID, log.HabitID, log.LogDate, log.Value, log.Note, log.NotePrivate, log.Status, actorID).Scan(
+		&log.ID, &log.Note, &log.NotePrivate, &log.CreatedAt, &log.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *PostgresRepository) DeleteHabitLog(ctx context.Context, habitID uuid.UUID, date time.Time, actorID uuid.UUID) error {
+	query := `
+        WITH log AS (
+            DELETE FROM habit_logs WHERE habit_id = $1 AND log_date = $2
+            RETURNING log_date, value, status
+        )
+        INSERT INTO habit_log_revisions (habit_id, log_date, old_value, old_status, actor_id)
+        SELECT $1, log_date, value, status, $3 FROM log`
+	tag, err := r.db.Exec(ctx, query, habitID, date, actorID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrHabitLogNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ClearHabitLog(ctx context.Context, habitID uuid.UUID, date time.Time, actorID uuid.UUID) (*domain.HabitLog, error) {
+	query := `
+        WITH old AS (
+            SELECT value, status FROM habit_logs WHERE habit_id = $1 AND log_date = $2
+        ), deleted AS (
+            DELETE FROM habit_logs WHERE habit_id = $1 AND log_date = $2 AND note IS NULL
+        ), log AS (
+            UPDATE habit_logs SET value = 0, status = $3, updated_at = NOW()
+            WHERE habit_id = $1 AND log_date = $2 AND note IS NOT NULL
+            RETURNING ` + logColumns + `
+        ), revision AS (
+            INSERT INTO habit_log_revisions (habit_id, log_date, old_value, old_status, new_value, new_status, actor_id)
+            SELECT $1, $2, old.value, old.status, log.value, log.status, $4
+            FROM old LEFT JOIN log ON TRUE
+            WHERE old.value IS DISTINCT FROM log.value OR old.status IS DISTINCT FROM log.status
+        )
+        SELECT ` + logColumns + ` FROM log`
+
+	var log domain.HabitLog
+	err := r.db.QueryRow(ctx, query, habitID, date, domain.LogDone, actorID).Scan(
+		&log.ID, &log.HabitID, &log.LogDate, &log.Value, &log.Status, &log.Note, &log.NotePrivate, &log.CreatedAt, &log.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+func (r *PostgresRepository) IncrementHabitLog(ctx context.Context, log *domain.HabitLog, delta, maxValue float64, keepZero bool, actorID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	old, err := lockLog(ctx, tx, log.HabitID, log.LogDate)
+	if err != nil {
+		return err
+	}
+	if err := checkIncrement(old, delta, maxValue); err != nil {
+		return err
+	}
+
+	// The conditions repeat checkIncrement for a log written since lockLog found none.
+	query := `
+        INSERT INTO habit_logs (id, habit_id, log_date, value, status, note_private)
+        VALUES ($1, $2, $3, GREATEST($4::numeric, 0), $5, $6)
+        ON CONFLICT (habit_id, log_date) DO UPDATE SET
+            value = GREATEST(habit_logs.value + $4::numeric, 0),
+            updated_at = NOW()
+        WHERE habit_logs.status = $5 AND habit_logs.value + $4::numeric <= $7
+        RETURNING id, value, note, note_private, created_at, updated_at`
+
+	err = tx.QueryRow(ctx, query, log.ID, log.HabitID, log.LogDate, delta, domain.LogDone, log.NotePrivate, maxValue).Scan(
+		&log.ID, &log.Value, &log.Note, &log.NotePrivate, &log.CreatedAt, &log.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The conflicting log is locked now, so its state explains the refusal.
+		if old, err = lockLog(ctx, tx, log.HabitID, log.LogDate); err != nil {
+			return err
+		}
+		if err := checkIncrement(old, delta, maxValue); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err != nil {
+		return err
+	}
+	log.Status = domain.LogDone
+
+	updated := &logState{value: log.Value, status: log.Status}
+	if log.Value == 0 && log.Note == nil && !keepZero {
+		if _, err := tx.Exec(ctx, `DELETE FROM habit_logs WHERE id = $1`, log.ID); err != nil {
+			return err
+		}
+		updated = nil
+	}
+	if _, err := recordLogRevision(ctx, tx, log.HabitID, log.LogDate, old, updated, actorID, nil); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) UpsertHabitLogs(ctx context.Context, habitID uuid.UUID, logs []domain.HabitLog, cleared []time.Time, actorID uuid.UUID) (created, removed []time.Time, err error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if len(logs) > 0 {
+		ids := make([]uuid.UUID, len(logs))
+		dates := make([]time.Time, len(logs))
+		values := make([]float64, len(logs))
+		statuses := make([]string, len(logs))
+		for i, log := range logs {
+			ids[i], dates[i], values[i], statuses[i] = log.ID, log.LogDate, log.Value, string(log.Status)
+		}
+
+		// Notes are left as they are; xmax is zero only for freshly inserted rows.
+		query := `
+            WITH old AS (
+                SELECT log_date, value, status FROM habit_logs
+                WHERE habit_id = $1 AND log_date = ANY($3::date[])
+            ), log AS (
+                INSERT INTO habit_logs (id, habit_id, log_date, value, status)
+                SELECT b.id, $1, b.log_date, b.value, b.status
+                FROM unnest($2::uuid[], $3::date[], $4::numeric[], $5::text[]) AS b(id, log_date, value, status)
+                ON CONFLICT (habit_id, log_date) DO UPDATE SET
+                    value = EXCLUDED.value,
+                    status = EXCLUDED.status,
+                    updated_at = NOW()
+                RETURNING log_date, value, status, xmax = 0 AS inserted
+            ), revision AS (
+                INSERT INTO habit_log_revisions (habit_id, log_date, old_value, old_status, new_value, new_status, actor_id)
+                SELECT $1, log.log_date, old.value, old.status, log.value, log.status, $6
+                FROM log LEFT JOIN old ON old.log_date = log.log_date
+                WHERE old.value IS DISTINCT FROM log.value OR old.status IS DISTINCT FROM log.status
+            )
+            SELECT log_date, inserted FROM log`
+		rows, err := tx.Query(ctx, query, habitID, ids, dates, values, statuses, actorID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			var date time.Time
+			var inserted bool
+			if err := rows.Scan(&date, &inserted); err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			if inserted {
+				created = append(created, date)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(cleared) > 0 {
+		rows, err := tx.Query(ctx, `
+            WITH old AS (
+                SELECT log_date, value, status FROM habit_logs
+                WHERE habit_id = $1 AND log_date = ANY($2::date[])
+            ), deleted AS (
+                DELETE FROM habit_logs
+                WHERE habit_id = $1 AND log_date = ANY($2::date[]) AND note IS NULL
+            ), log AS (
+                UPDATE habit_logs SET value = 0, status = $4, updated_at = NOW()
+                WHERE habit_id = $1 AND log_date = ANY($2::date[]) AND note IS NOT NULL
+                RETURNING log_date, value, status
+            )
+            INSERT INTO habit_log_revisions (habit_id, log_date, old_value, old_status, new_value, new_status, actor_id)
+            SELECT $1, old.log_date, old.value, old.status, log.value, log.status, $3
+            FROM old LEFT JOIN log ON log.log_date = old.log_date
+            WHERE old.value IS DISTINCT FROM log.value OR old.status IS DISTINCT FROM log.status
+            RETURNING log_date`, habitID, cleared, actorID, domain.LogDone)
+		if err != nil {
+			return nil, nil, err
+		}
+		removed, err = pgx.CollectRows(rows, pgx.RowTo[time.Time])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+	return created, removed, nil
+}
+
+func (r *PostgresRepository) InsertHabitLogs(ctx context.Context, habitID uuid.UUID, logs []domain.HabitLog, actorID uuid.UUID) ([]time.Time, error) {
+	ids := make([]uuid.UUID, len(logs))
+	dates := make([]time.Time, len(logs))
+	values := make([]float64, len(logs))
+	statuses := make([]string, len(logs))
+	for i, log := range logs {
+		ids[i], dates[i], values[i], statuses[i] = log.ID, log.LogDate, log.Value, string(log.Status)
+	}
+
+	query := `
+        WITH log AS (
+            INSERT INTO habit_logs (id, habit_id, log_date, value, status)
+            SELECT b.id, $1, b.log_date, b.value, b.status
+            FROM unnest($2::uuid[], $3::date[], $4::numeric[], $5::text[]) AS b(id, log_date, value, status)
+            ON CONFLICT (habit_id, log_date) DO NOTHING
+            RETURNING log_date, value, status
+        )
+        INSERT INTO habit_log_revisions (habit_id, log_date, new_value, new_status, actor_id)
+        SELECT $1, log_date, value, status, $6 FROM log
+        RETURNING log_date`
+	rows, err := r.db.Query(ctx, query, habitID, ids, dates, values, statuses, actorID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[time.Time])
+}
+
+// logState is the part of a log its revisions record.
+type logState struct {
+	value  float64
+	status domain.LogStatus
+}
+
+func (s *logState) fields() (*float64, *domain.LogStatus) {
+	if s == nil {
+		return nil, nil
+	}
+	return &s.value, &s.status
+}
+
+// checkIncrement reports why delta cannot be added to the log in state old, if it
+// cannot.
+func checkIncrement(old *logState, delta, maxValue float64) error {
+	value := delta
+	if old != nil {
+		if old.status != domain.LogDone {
+			return ErrHabitLogNotDone
+		}
+		value += old.value
+	}
+	if value > maxValue {
+		return ErrLogValueTooLarge
+	}
+	return nil
+}
+
+// lockLog returns the state of a habit's log on date, or nil if the day has no log,
+// locking the log until tx ends.
+func lockLog(ctx context.Context, tx pgx.Tx, habitID uuid.UUID, date time.Time) (*logState, error) {
+	var state logState
+	err := tx.QueryRow(ctx, `SELECT value, status FROM habit_logs WHERE habit_id = $1 AND log_date = $2 FOR UPDATE`, habitID, date).
+		Scan(&state.value, &state.status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// recordLogRevision records the change of a log from old to new. A change that left
+// the log as it was is not recorded, unless it undoes another revision.
+func recordLogRevision(ctx context.Context, tx pgx.Tx, habitID uuid.UUID, date time.Time, old, new *logState, actorID uuid.UUID, undoes *int64) (*domain.HabitLogRevision, error) {
+	unchanged := old == nil && new == nil || old != nil && new != nil && *old == *new
+	if unchanged && undoes == nil {
+		return nil, nil
+	}
+
+	revision := &domain.HabitLogRevision{HabitID: habitID, LogDate: date, ActorID: &actorID, Undoes: undoes}
+	revision.OldValue, revision.OldStatus = old.fields()
+	revision.NewValue, revision.NewStatus = new.fields()
+
+	query := `
+        INSERT INTO habit_log_revisions (habit_id, log_date, old_value, old_status, new_value, new_status, actor_id, undoes)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, created_at`
+	err := tx.QueryRow(ctx, query, habitID, date, revision.OldValue, revision.OldStatus, revision.NewValue, revision.NewStatus, actorID, undoes).
+		Scan(&revision.ID, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// revisionColumns lists the habit_log_revisions columns scanned into a
+// domain.HabitLogRevision.
+const revisionColumns = `id, habit_id, log_date, old_value, old_status, new_value, new_status, actor_id, undoes, created_at`
+
+func (r *PostgresRepository) GetHabitLogRevisions(ctx context.Context, habitID uuid.UUID, date time.Time) ([]domain.HabitLogRevision, error) {
+	query := `
+        SELECT ` + revisionColumns + `
+        FROM habit_log_revisions
+        WHERE habit_id = $1 AND log_date = $2
+        ORDER BY id DESC`
+	rows, err := r.db.Query(ctx, query, habitID, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []domain.HabitLogRevision{}
+	for rows.Next() {
+		var rev domain.HabitLogRevision
+		if err := rows.Scan(&rev.ID, &rev.HabitID, &rev.LogDate, &rev.OldValue, &rev.OldStatus, &rev.NewValue, &rev.NewStatus, &rev.ActorID, &rev.Undoes, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (r *PostgresRepository) UndoHabitLog(ctx context.Context, habitID uuid.UUID, date time.Time, actorID uuid.UUID) (*domain.HabitLogRevision, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	current, err := lockLog(ctx, tx, habitID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	// Undos themselves are not undone; undoing walks back through the changes.
+	query := `
+        SELECT r.id, r.old_value, r.old_status
+        FROM habit_log_revisions r
+        WHERE r.habit_id = $1 AND r.log_date = $2 AND r.undoes IS NULL
+          AND NOT EXISTS (SELECT 1 FROM habit_log_revisions u WHERE u.undoes = r.id)
+        ORDER BY r.id DESC
+        LIMIT 1`
+	var undoes int64
+	var oldValue *float64
+	var oldStatus *domain.LogStatus
+	err = tx.QueryRow(ctx, query, habitID, date).Scan(&undoes, &oldValue, &oldStatus)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNothingToUndo
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var restored *logState
+	if oldStatus == nil {
+		if _, err := tx.Exec(ctx, `DELETE FROM habit_logs WHERE habit_id = $1 AND log_date = $2`, habitID, date); err != nil {
+			return nil, err
+		}
+	} else {
+		// A habit converted to boolean since keeps done values at 1. A restored log
+		// keeps the note it has, if it still exists.
+		restored = &logState{status: *oldStatus}
+		query := `
+            INSERT INTO habit_logs (id, habit_id, log_date, value, status)
+            SELECT $1, h.id, $3, CASE WHEN h.is_boolean AND $5 = 'done' THEN 1 ELSE $4::numeric END, $5
+            FROM habits h WHERE h.id = $2
+            ON CONFLICT (habit_id, log_date) DO UPDATE SET
+                value = EXCLUDED.value,
+                status = EXCLUDED.status,
+                updated_at = NOW()
+            RETURNING value`
+		err := tx.QueryRow(ctx, query, uuid.New(), habitID, date, *oldValue, *oldStatus).Scan(&restored.value)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrHabitNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	revision, err := recordLogRevision(ctx, tx, habitID, date, current, restored, actorID, &undoes)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if tag.RowsAffected() == 0 {
-		return ErrHabitNotFound
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
 	}
-	return nil
+	return revision, nil
 }
 
-func (r *PostgresRepository) UpsertHabitLog(ctx context.Context, log *domain.HabitLog) error {
-	query := `
-        INSERT INTO habit_logs (id, habit_id, log_date, value)
-        VALUES ($1, $2, $3, $4)
-        ON CONFLICT (habit_id, log_date) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()
-        RETURNING id, created_at, updated_at`
-
-	err := r.db.QueryRow(ctx, query, log.ID, log.HabitID, log.LogDate, log.Value).Scan(&log.ID, &log.CreatedAt, &log.UpdatedAt)
-	if err != nil {
-		return err
+// nullableDate maps the zero time to NULL, for optional date bounds.
+func nullableDate(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
 	}
-	return nil
+	return &t
 }
 
-func (r *PostgresRepository) GetLogsForHabits(ctx context.Context, habitIDs []uuid.UUID) ([]domain.HabitLog, error) {
+func (r *PostgresRepository) GetLogsForHabits(ctx context.Context, habitIDs []uuid.UUID, filter LogFilter) ([]domain.HabitLog, error) {
 	if len(habitIDs) == 0 {
 		return []domain.HabitLog{}, nil
 	}
 	query := `
-        SELECT id, habit_id, log_date, value, created_at, updated_at
+        SELECT ` + logColumns + `
         FROM habit_logs
-        WHERE habit_id = ANY($1) AND value > 0
+        WHERE habit_id = ANY($1)
+            AND ($2::date IS NULL OR log_date >= $2)
+            AND ($3::date IS NULL OR log_date <= $3)
         ORDER BY habit_id, log_date ASC`
 
-	rows, err := r.db.Query(ctx, query, habitIDs)
+	rows, err := r.db.Query(ctx, query, habitIDs, nullableDate(filter.From), nullableDate(filter.To))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs, err := pgx.CollectRows(rows, pgx.RowToStructByName[domain.HabitLog])
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (r *PostgresRepository) GetHabitLogsPage(ctx context.Context, habitID uuid.UUID, filter LogFilter, before time.Time, limit int) ([]domain.HabitLog, error) {
+	query := `
+        SELECT ` + logColumns + `
+        FROM habit_logs
+        WHERE habit_id = $1
+            AND ($2::date IS NULL OR log_date >= $2)
+            AND ($3::date IS NULL OR log_date <= $3)
+            AND ($4::date IS NULL OR log_date < $4)
+        ORDER BY log_date DESC
+        LIMIT $5`
+
+	rows, err := r.db.Query(ctx, query, habitID, nullableDate(filter.From), nullableDate(filter.To), nullableDate(before), limit)
 	if err != nil {
 		return nil, err
 	}
@@ -324,6 +1124,7 @@ WITH RankedUsers AS (
         u.id,
         u.username,
         u.avatar_url,
+        u.timezone,
         COUNT(hl.id) as total_logged_days
     FROM
         users u
@@ -332,7 +1133,7 @@ WITH RankedUsers AS (
     JOIN
         habit_logs hl ON h.id = hl.habit_id
     WHERE
-        hl.value > 0
+        h.deleted_at IS NULL AND ` + positiveHabit + ` AND ` + completedLogCondition + `
     GROUP BY
         u.id
     ORDER BY
@@ -349,6 +1150,17 @@ HabitsWithLogs AS (
                 'name', h.name,
                 'colorHue', h.color_hue,
                 'isBoolean', h.is_boolean,
+                'polarity', h.polarity,
+                'schedule', json_build_object(
+                    'frequency', h.schedule_frequency,
+                    'weekdays', h.schedule_weekdays,
+                    'timesPerPeriod', h.schedule_times
+                ),
+                'target', json_build_object('value', h.target_value::float8, 'mode', h.target_mode),
+                'unit', h.unit,
+                'position', h.position,
+                'pinned', h.pinned,
+                'category', h.category,
                 'createdAt', to_jsonb(h.created_at),
                 'logs', COALESCE(
                     (SELECT json_agg(
@@ -356,19 +1168,24 @@ HabitsWithLogs AS (
                             'id', hl.id,
                             'habitId', hl.habit_id,
                             'date', to_jsonb(hl.log_date::timestamp AT TIME ZONE 'UTC'),
-                            'value', hl.value,
+                            'value', hl.value::float8,
+                            'status', hl.status,
+                            'note', ` + publicNote + `,
+                            'notePrivate', hl.note_private,
                             'createdAt', to_jsonb(hl.created_at),
                             'updatedAt', to_jsonb(hl.updated_at)
                         ) ORDER BY hl.log_date ASC
-                    ) FROM habit_logs hl WHERE hl.habit_id = h.id AND hl.value > 0),
+                    ) FROM habit_logs hl WHERE hl.habit_id = h.id AND ` + embeddedLogWindow + `),
                     '[]'::json
                 )
-            )
+            ) ORDER BY h.pinned DESC, h.position ASC, h.created_at DESC
         ) AS habits
     FROM
         habits h
+    JOIN
+        users u ON u.id = h.user_id
     WHERE
-        h.user_id IN (SELECT id FROM RankedUsers)
+        h.user_id IN (SELECT id FROM RankedUsers) AND ` + activeHabit + `
     GROUP BY
         h.user_id
 )
@@ -376,7 +1193,7 @@ SELECT json_build_object(
     'user', json_build_object('id', ru.id, 'username', ru.username, 'avatarUrl', ru.avatar_url),
     'totalLoggedDays', ru.total_logged_days,
     'habits', COALESCE(hwl.habits, '[]'::json)
-)
+), ru.timezone
 FROM RankedUsers ru
 LEFT JOIN HabitsWithLogs hwl ON ru.id = hwl.user_id
 ORDER BY ru.total_logged_days DESC;
@@ -390,7 +1207,8 @@ ORDER BY ru.total_logged_days DESC;
 	var entries []domain.LeaderboardEntry
 	for rows.Next() {
 		var jsonData []byte
-		if err := rows.Scan(&jsonData); err != nil {
+		var timezone string
+		if err := rows.Scan(&jsonData, &timezone); err != nil {
 			return nil, err
 		}
 
@@ -398,13 +1216,14 @@ ORDER BY ru.total_logged_days DESC;
 		if err := json.Unmarshal(jsonData, &entry); err != nil {
 			return nil, err
 		}
+		entry.Timezone = timezone
 		entries = append(entries, entry)
 	}
 
 	return entries, rows.Err()
 }
 
-func (r *PostgresRepository) GetExplorePage(ctx context.Context, limit int) ([]domain.ExploreEntry, error) {
+func (r *PostgresRepository) GetExplorePage(ctx context.Context, limit int, category domain.HabitCategory) ([]domain.ExploreEntry, error) {
 	query := `
 WITH LatestUserLogs AS (
     SELECT DISTINCT ON (h.user_id)
@@ -413,7 +1232,9 @@ WITH LatestUserLogs AS (
         hl.updated_at
     FROM habit_logs hl
     JOIN habits h ON hl.habit_id = h.id
-    WHERE hl.value > 0
+    JOIN users u ON h.user_id = u.id
+    WHERE ` + doneLog + ` AND hl.log_date <= ` + ownerToday + ` AND ` + activeHabit + ` AND ` + positiveHabit + `
+        AND ($2::text = '' OR h.category = $2)
     ORDER BY h.user_id, hl.updated_at DESC
 ),
 ExploreHabits AS (
@@ -423,6 +1244,16 @@ ExploreHabits AS (
         h.name,
         h.color_hue,
         h.is_boolean,
+        h.polarity,
+        h.schedule_frequency,
+        h.schedule_weekdays,
+        h.schedule_times,
+        h.target_value,
+        h.target_mode,
+        h.unit,
+        h.position,
+        h.pinned,
+        h.category,
         h.created_at,
         COALESCE(
             (SELECT json_agg(
@@ -430,15 +1261,19 @@ ExploreHabits AS (
                     'id', hl.id,
                     'habitId', hl.habit_id,
                     'date', to_jsonb(hl.log_date::timestamp AT TIME ZONE 'UTC'),
-                    'value', hl.value,
+                    'value', hl.value::float8,
+                    'status', hl.status,
+                    'note', ` + publicNote + `,
+                    'notePrivate', hl.note_private,
                     'createdAt', to_jsonb(hl.created_at),
                     'updatedAt', to_jsonb(hl.updated_at)
                 ) ORDER BY hl.log_date ASC
-            ) FROM habit_logs hl WHERE hl.habit_id = h.id AND hl.value > 0),
+            ) FROM habit_logs hl WHERE hl.habit_id = h.id AND ` + embeddedLogWindow + `),
             '[]'::json
         ) AS logs
     FROM LatestUserLogs lul
     JOIN habits h ON lul.habit_id = h.id
+    JOIN users u ON h.user_id = u.id
     ORDER BY lul.updated_at DESC
     LIMIT $1
 )
@@ -450,15 +1285,26 @@ SELECT json_build_object(
         'name', eh.name,
         'colorHue', eh.color_hue,
         'isBoolean', eh.is_boolean,
+        'polarity', eh.polarity,
+        'schedule', json_build_object(
+            'frequency', eh.schedule_frequency,
+            'weekdays', eh.schedule_weekdays,
+            'timesPerPeriod', eh.schedule_times
+        ),
+        'target', json_build_object('value', eh.target_value::float8, 'mode', eh.target_mode),
+        'unit', eh.unit,
+        'position', eh.position,
+        'pinned', eh.pinned,
+        'category', eh.category,
         'createdAt', to_jsonb(eh.created_at),
         'logs', eh.logs
     )
-)
+), u.timezone
 FROM ExploreHabits eh
 JOIN users u ON eh.user_id = u.id;
 `
 
-	rows, err := r.db.Query(ctx, query, limit)
+	rows, err := r.db.Query(ctx, query, limit, category)
 	if err != nil {
 		return nil, err
 	}
@@ -467,7 +1313,8 @@ JOIN users u ON eh.user_id = u.id;
 	var entries []domain.ExploreEntry
 	for rows.Next() {
 		var jsonData []byte
-		if err := rows.Scan(&jsonData); err != nil {
+		var timezone string
+		if err := rows.Scan(&jsonData, &timezone); err != nil {
 			return nil, err
 		}
 
@@ -475,8 +1322,540 @@ JOIN users u ON eh.user_id = u.id;
 		if err := json.Unmarshal(jsonData, &entry); err != nil {
 			return nil, err
 		}
+		entry.Timezone = timezone
 		entries = append(entries, entry)
 	}
 
 	return entries, rows.Err()
 }
+
+// habitLogsInRange joins logs (hl) with their habit (h) and owner (u), so
+// completedLogCondition can be applied.
+const habitLogsInRange = `
+    habit_logs hl
+    JOIN habits h ON h.id = hl.habit_id
+    JOIN users u ON u.id = h.user_id`
+
+func (r *PostgresRepository) GetHabitStats(ctx context.Context, habitID uuid.UUID, from, to time.Time) (*domain.HabitStats, error) {
+	stats := &domain.HabitStats{
+		HabitID: habitID,
+		From:    from,
+		To:      to,
+		Values:  &domain.ValueStats{},
+	}
+
+	summaryQuery := `
+        SELECT
+            COUNT(*) FILTER (WHERE ` + doneLog + `),
+            COUNT(*) FILTER (WHERE ` + completedLogCondition + `),
+            COUNT(*) FILTER (WHERE hl.status = 'skipped'),
+            COALESCE(SUM(hl.value) FILTER (WHERE ` + doneLog + `), 0)::float8,
+            COALESCE(AVG(hl.value) FILTER (WHERE ` + doneLog + `), 0)::float8,
+            COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY hl.value) FILTER (WHERE ` + doneLog + `), 0)::float8,
+            COALESCE(MAX(hl.value) FILTER (WHERE ` + doneLog + `), 0)::float8
+        FROM ` + habitLogsInRange + `
+        WHERE hl.habit_id = $1 AND hl.log_date BETWEEN $2 AND $3`
+	err := r.db.QueryRow(ctx, summaryQuery, habitID, from, to).Scan(
+		&stats.LoggedDays, &stats.CompletedDays, &stats.SkippedDays,
+		&stats.Values.Total, &stats.Values.Mean, &stats.Values.Median, &stats.Values.Max,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	weekdayQuery := `
+        SELECT
+            EXTRACT(DOW FROM hl.log_date)::int,
+            COUNT(*) FILTER (WHERE ` + doneLog + `),
+            COUNT(*) FILTER (WHERE ` + completedLogCondition + `),
+            COUNT(*) FILTER (WHERE hl.status = 'skipped'),
+            COALESCE(SUM(hl.value) FILTER (WHERE ` + doneLog + `), 0)::float8
+        FROM ` + habitLogsInRange + `
+        WHERE hl.habit_id = $1 AND hl.log_date BETWEEN $2 AND $3
+        GROUP BY 1`
+	rows, err := r.db.Query(ctx, weekdayQuery, habitID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	stats.ByWeekday = make([]domain.WeekdayStats, 7)
+	for i := range stats.ByWeekday {
+		stats.ByWeekday[i].Weekday = i
+	}
+	for rows.Next() {
+		var s domain.WeekdayStats
+		if err := rows.Scan(&s.Weekday, &s.LoggedDays, &s.CompletedDays, &s.SkippedDays, &s.Total); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.ByWeekday[s.Weekday] = s
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.ByWeek, err = r.getHabitPeriodStats(ctx, habitID, from, to, "week"); err != nil {
+		return nil, err
+	}
+	if stats.ByMonth, err = r.getHabitPeriodStats(ctx, habitID, from, to, "month"); err != nil {
+		return nil, err
+	}
+
+	// Weekly and monthly habits are not due on any particular day, so only daily and
+	// weekday habits take part in deciding whether a day was perfect.
+	perfectDaysQuery := `
+        SELECT COUNT(*) FROM (
+            SELECT d.day
+            FROM habits owner_habit
+            JOIN users u ON u.id = owner_habit.user_id
+            CROSS JOIN generate_series($2::timestamp, $3::timestamp, '1 day') AS d(day)
+            JOIN habits h ON h.user_id = u.id
+                AND (h.created_at AT TIME ZONE u.timezone)::date <= d.day::date
+                AND (h.archived_at IS NULL OR (h.archived_at AT TIME ZONE u.timezone)::date > d.day::date)
+                AND h.deleted_at IS NULL
+                AND (
+                    h.schedule_frequency = 'daily'
+                    OR (h.schedule_frequency = 'weekdays' AND EXTRACT(DOW FROM d.day)::smallint = ANY(h.schedule_weekdays))
+                )
+            LEFT JOIN habit_logs hl ON hl.habit_id = h.id AND hl.log_date = d.day::date
+            WHERE owner_habit.id = $1
+            GROUP BY d.day
+            -- Skipped habits are excused, and negative habits only need to be free of
+            -- relapses, but at least one positive habit must have been done.
+            HAVING bool_and(CASE
+                    WHEN h.polarity = 'negative' THEN NOT COALESCE(` + doneLog + `, FALSE)
+                    ELSE COALESCE(` + completedLogCondition + ` OR hl.status = 'skipped', FALSE)
+                END)
+                AND bool_or(` + positiveHabit + ` AND COALESCE(` + completedLogCondition + `, FALSE))
+        ) perfect`
+	if err := r.db.QueryRow(ctx, perfectDaysQuery, habitID, from, to).Scan(&stats.PerfectDays); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// getHabitPeriodStats aggregates a habit's logs per week (starting on Monday) or month,
+// including periods without any logs.
+func (r *PostgresRepository) getHabitPeriodStats(ctx context.Context, habitID uuid.UUID, from, to time.Time, unit string) ([]domain.PeriodStats, error) {
+	query := `
+        SELECT
+            p.start::date,
+            COUNT(hl.id) FILTER (WHERE ` + doneLog + `),
+            COUNT(hl.id) FILTER (WHERE ` + completedLogCondition + `),
+            COUNT(hl.id) FILTER (WHERE hl.status = 'skipped'),
+            COALESCE(SUM(hl.value) FILTER (WHERE ` + doneLog + `), 0)::float8
+        FROM generate_series(date_trunc($4, $2::timestamp), $3::timestamp, ('1 ' || $4)::interval) AS p(start)
+        LEFT JOIN (` + habitLogsInRange + `)
+            ON hl.habit_id = $1
+            AND hl.log_date BETWEEN $2 AND $3
+            AND hl.log_date >= p.start AND hl.log_date < p.start + ('1 ' || $4)::interval
+        GROUP BY p.start
+        ORDER BY p.start`
+
+	rows, err := r.db.Query(ctx, query, habitID, from, to, unit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []domain.PeriodStats{}
+	for rows.Next() {
+		var p domain.PeriodStats
+		if err := rows.Scan(&p.Start, &p.LoggedDays, &p.CompletedDays, &p.SkippedDays, &p.Total); err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+func (r *PostgresRepository) CreateTag(ctx context.Context, tag *domain.Tag) error {
+	query := `INSERT INTO tags (id, user_id, name, color_hue) VALUES ($1, $2, $3, $4) RETURNING created_at`
+	err := r.db.QueryRow(ctx, query, tag.ID, tag.UserID, tag.Name, tag.ColorHue).Scan(&tag.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicateTagName
+	}
+	return err
+}
+
+func (r *PostgresRepository) GetTagsByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Tag, error) {
+	query := `SELECT id, user_id, name, color_hue, created_at FROM tags WHERE user_id = $1 ORDER BY name`
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags, err := pgx.CollectRows(rows, pgx.RowToStructByName[domain.Tag])
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r *PostgresRepository) UpdateTag(ctx context.Context, tag *domain.Tag) error {
+	query := `UPDATE tags SET name = $1, color_hue = $2 WHERE id = $3 AND user_id = $4 RETURNING created_at`
+	err := r.db.QueryRow(ctx, query, tag.Name, tag.ColorHue, tag.ID, tag.UserID).Scan(&tag.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTagNotFound
+	}
+	if isUniqueViolation(err) {
+		return ErrDuplicateTagName
+	}
+	return err
+}
+
+func (r *PostgresRepository) DeleteTag(ctx context.Context, tagID, userID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM tags WHERE id = $1 AND user_id = $2`, tagID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// setHabitTags tags a habit that has no tags yet with the given tags, failing with
+// ErrTagNotFound if any of them does not belong to the habit's owner.
+func setHabitTags(ctx context.Context, tx pgx.Tx, habitID uuid.UUID, tags []domain.Tag) error {
+	tagIDs := make([]uuid.UUID, len(tags))
+	for i, tag := range tags {
+		tagIDs[i] = tag.ID
+	}
+
+	query := `
+        INSERT INTO habit_tags (habit_id, tag_id)
+        SELECT h.id, t.id
+        FROM habits h
+        JOIN tags t ON t.user_id = h.user_id
+        WHERE h.id = $1 AND t.id = ANY($2)`
+	tag, err := tx.Exec(ctx, query, habitID, tagIDs)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() != int64(len(tagIDs)) {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetTagsForHabits(ctx context.Context, habitIDs []uuid.UUID) (map[uuid.UUID][]domain.Tag, error) {
+	tagsByHabitID := make(map[uuid.UUID][]domain.Tag)
+	if len(habitIDs) == 0 {
+		return tagsByHabitID, nil
+	}
+
+	query := `
+        SELECT ht.habit_id, t.id, t.user_id, t.name, t.color_hue, t.created_at
+        FROM habit_tags ht
+        JOIN tags t ON t.id = ht.tag_id
+        WHERE ht.habit_id = ANY($1)
+        ORDER BY t.name`
+	rows, err := r.db.Query(ctx, query, habitIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var habitID uuid.UUID
+		var tag domain.Tag
+		if err := rows.Scan(&habitID, &tag.ID, &tag.UserID, &tag.Name, &tag.ColorHue, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		tagsByHabitID[habitID] = append(tagsByHabitID[habitID], tag)
+	}
+	return tagsByHabitID, rows.Err()
+}
+
+func (r *PostgresRepository) GetHabitAdoptionCounts(ctx context.Context, habitIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	counts := make(map[uuid.UUID]int)
+	if len(habitIDs) == 0 {
+		return counts, nil
+	}
+
+	query := `
+        SELECT c.source_habit_id, COUNT(DISTINCT c.user_id)
+        FROM habits c
+        JOIN habits s ON s.id = c.source_habit_id
+        WHERE c.source_habit_id = ANY($1) AND c.user_id <> s.user_id AND c.deleted_at IS NULL
+        GROUP BY c.source_habit_id`
+	rows, err := r.db.Query(ctx, query, habitIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var habitID uuid.UUID
+		var count int
+		if err := rows.Scan(&habitID, &count); err != nil {
+			return nil, err
+		}
+		counts[habitID] = count
+	}
+	return counts, rows.Err()
+}
+
+// templateColumns lists the columns of a published template (t) and its author (u)
+// read by scanHabitTemplate.
+const templateColumns = `t.id, t.name, COALESCE(t.description, ''), t.color_hue, t.is_boolean, t.polarity,
+    t.schedule_frequency, t.schedule_weekdays, t.schedule_times, t.target_value, t.target_mode,
+    t.unit, t.category, t.created_at, u.id, u.username, u.avatar_url`
+
+func scanHabitTemplate(row pgx.Row) (*domain.HabitTemplate, error) {
+	var t domain.HabitTemplate
+	var id uuid.UUID
+	var createdAt time.Time
+	var author domain.PublicUser
+	err := row.Scan(
+		&id, &t.Name, &t.Description, &t.ColorHue, &t.IsBoolean, &t.Polarity,
+		&t.Schedule.Frequency, &t.Schedule.Weekdays, &t.Schedule.TimesPerPeriod, &t.Target.Value, &t.Target.Mode,
+		&t.Unit, &t.Category, &createdAt, &author.ID, &author.Username, &author.AvatarURL,
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.ID = id.String()
+	t.CreatedAt = &createdAt
+	t.Author = &author
+	return &t, nil
+}
+
+func (r *PostgresRepository) PublishHabitTemplate(ctx context.Context, template *domain.HabitTemplate, userID, habitID uuid.UUID) error {
+	query := `
+        INSERT INTO habit_templates (
+            id, user_id, habit_id, name, description, color_hue, is_boolean, polarity,
+            schedule_frequency, schedule_weekdays, schedule_times,
+            target_value, target_mode, unit, category
+        )
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+        ON CONFLICT (habit_id) DO UPDATE SET
+            name = EXCLUDED.name, description = EXCLUDED.description,
+            color_hue = EXCLUDED.color_hue, is_boolean = EXCLUDED.is_boolean, polarity = EXCLUDED.polarity,
+            schedule_frequency = EXCLUDED.schedule_frequency, schedule_weekdays = EXCLUDED.schedule_weekdays,
+            schedule_times = EXCLUDED.schedule_times, target_value = EXCLUDED.target_value,
+            target_mode = EXCLUDED.target_mode, unit = EXCLUDED.unit, category = EXCLUDED.category
+        RETURNING id, created_at`
+	var id uuid.UUID
+	var createdAt time.Time
+	err := r.db.QueryRow(ctx, query,
+		uuid.New(), userID, habitID, template.Name, template.Description,
+		template.ColorHue, template.IsBoolean, template.Polarity,
+		template.Schedule.Frequency, template.Schedule.Weekdays, template.Schedule.TimesPerPeriod,
+		template.Target.Value, template.Target.Mode, template.Unit, template.Category,
+	).Scan(&id, &createdAt)
+	if err != nil {
+		return err
+	}
+	template.ID = id.String()
+	template.CreatedAt = &createdAt
+	return nil
+}
+
+func (r *PostgresRepository) GetHabitTemplates(ctx context.Context, category domain.HabitCategory, limit int) ([]domain.HabitTemplate, error) {
+	query := `
+        SELECT ` + templateColumns + `
+        FROM habit_templates t
+        JOIN users u ON u.id = t.user_id
+        JOIN habits h ON h.id = t.habit_id
+        WHERE h.deleted_at IS NULL AND ($1::text = '' OR t.category = $1)
+        ORDER BY t.created_at DESC
+        LIMIT $2`
+	rows, err := r.db.Query(ctx, query, category, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []domain.HabitTemplate{}
+	for rows.Next() {
+		t, err := scanHabitTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	return templates, rows.Err()
+}
+
+func (r *PostgresRepository) GetHabitTemplateByID(ctx context.Context, templateID uuid.UUID) (*domain.HabitTemplate, error) {
+	query := `
+        SELECT ` + templateColumns + `
+        FROM habit_templates t
+        JOIN users u ON u.id = t.user_id
+        JOIN habits h ON h.id = t.habit_id
+        WHERE t.id = $1 AND h.deleted_at IS NULL`
+	t, err := scanHabitTemplate(r.db.QueryRow(ctx, query, templateID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrHabitTemplateNotFound
+	}
+	return t, err
+}
+
+func (r *PostgresRepository) DeleteHabitTemplate(ctx context.Context, templateID, userID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM habit_templates WHERE id = $1 AND user_id = $2`, templateID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrHabitTemplateNotFound
+	}
+	return nil
+}
+
+// sessionColumns lists the sessions columns scanned into a domain.Session.
+const sessionColumns = `id, user_id, refresh_token_hash, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at`
+
+func scanSession(row pgx.Row) (*domain.Session, error) {
+	var s domain.Session
+	err := row.Scan(&s.ID, &s.UserID, &s.RefreshTokenHash, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *PostgresRepository) CreateSession(ctx context.Context, session *domain.Session) error {
+	query := `
+        INSERT INTO sessions (id, user_id, refresh_token_hash, user_agent, ip_address, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING created_at, last_seen_at`
+	return r.db.QueryRow(ctx, query, session.ID, session.UserID, session.RefreshTokenHash, session.UserAgent, session.IPAddress, session.ExpiresAt).
+		Scan(&session.CreatedAt, &session.LastSeenAt)
+}
+
+func (r *PostgresRepository) GetSessionByID(ctx context.Context, sessionID uuid.UUID) (*domain.Session, error) {
+	session, err := scanSession(r.db.QueryRow(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, sessionID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	return session, err
+}
+
+func (r *PostgresRepository) RotateSession(ctx context.Context, session *domain.Session, oldHash []byte) error {
+	query := `
+        UPDATE sessions
+        SET refresh_token_hash = $3, user_agent = $4, ip_address = $5, expires_at = $6, last_seen_at = NOW()
+        WHERE id = $1 AND refresh_token_hash = $2 AND revoked_at IS NULL AND expires_at > NOW()
+        RETURNING last_seen_at`
+	err := r.db.QueryRow(ctx, query, session.ID, oldHash, session.RefreshTokenHash, session.UserAgent, session.IPAddress, session.ExpiresAt).
+		Scan(&session.LastSeenAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+func (r *PostgresRepository) GetActiveSessions(ctx context.Context, userID uuid.UUID) ([]domain.Session, error) {
+	query := `
+        SELECT ` + sessionColumns + `
+        FROM sessions
+        WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+        ORDER BY last_seen_at DESC`
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []domain.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *PostgresRepository) RevokeSession(ctx context.Context, sessionID, userID uuid.UUID) error {
+	query := `
+        UPDATE sessions SET revoked_at = NOW()
+        WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()`
+	tag, err := r.db.Exec(ctx, query, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetAccessTokenState(ctx context.Context, userID, sessionID uuid.UUID) (int, bool, error) {
+	query := `
+        SELECT u.token_generation, EXISTS (
+            SELECT 1 FROM sessions s
+            WHERE s.id = $2 AND s.user_id = u.id AND s.revoked_at IS NULL AND s.expires_at > NOW()
+        )
+        FROM users u
+        WHERE u.id = $1`
+	var generation int
+	var sessionActive bool
+	if err := r.db.QueryRow(ctx, query, userID, sessionID).Scan(&generation, &sessionActive); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, ErrUserNotFound
+		}
+		return 0, false, err
+	}
+	return generation, sessionActive, nil
+}
+
+func (r *PostgresRepository) AwardAchievements(ctx context.Context, userID uuid.UUID, achievements []domain.AchievementID, earnedAt time.Time) ([]domain.AchievementID, error) {
+	if len(achievements) == 0 {
+		return nil, nil
+	}
+
+	query := `
+        INSERT INTO user_achievements (user_id, achievement, earned_at)
+        SELECT $1, a, $3 FROM unnest($2::text[]) AS a
+        ON CONFLICT (user_id, achievement) DO NOTHING
+        RETURNING achievement`
+	ids := make([]string, len(achievements))
+	for i, a := range achievements {
+		ids[i] = string(a)
+	}
+	rows, err := r.db.Query(ctx, query, userID, ids, earnedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var awarded []domain.AchievementID
+	for rows.Next() {
+		var id domain.AchievementID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		awarded = append(awarded, id)
+	}
+	return awarded, rows.Err()
+}
+
+func (r *PostgresRepository) GetUserAchievements(ctx context.Context, userID uuid.UUID) (map[domain.AchievementID]time.Time, error) {
+	rows, err := r.db.Query(ctx, `SELECT achievement, earned_at FROM user_achievements WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	earned := make(map[domain.AchievementID]time.Time)
+	for rows.Next() {
+		var id domain.AchievementID
+		var earnedAt time.Time
+		if err := rows.Scan(&id, &earnedAt); err != nil {
+			return nil, err
+		}
+		earned[id] = earnedAt
+	}
+	return earned, rows.Err()
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == UNIQUE_VIOLATION_CODE
+}
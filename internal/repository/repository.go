@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/axseem/peakstreak/internal/domain"
 	"github.com/google/uuid"
@@ -13,6 +14,15 @@ var (
 	ErrDuplicateUsername = NewRepositoryError("username already exists")
 	ErrDuplicateEmail    = NewRepositoryError("email already exists")
 	ErrDuplicateHabitLog = NewRepositoryError("habit log for this date already exists")
+	ErrHabitLogNotFound  = NewRepositoryError("habit log not found")
+	ErrTagNotFound       = NewRepositoryError("tag not found")
+	ErrDuplicateTagName  = NewRepositoryError("tag name already exists")
+
+	ErrHabitTemplateNotFound = NewRepositoryError("habit template not found")
+	ErrNothingToUndo         = NewRepositoryError("no log change to undo")
+	ErrHabitLogNotDone       = NewRepositoryError("habit log is not marked as done")
+	ErrLogValueTooLarge      = NewRepositoryError("log value too large")
+	ErrSessionNotFound       = NewRepositoryError("session not found")
 )
 
 type RepositoryError struct {
@@ -27,25 +37,100 @@ func NewRepositoryError(s string) error {
 	return &RepositoryError{s}
 }
 
+// LogFilter narrows a log query to an inclusive date range. Zero bounds are open.
+type LogFilter struct {
+	From time.Time
+	To   time.Time
+}
+
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *domain.User) error
+	GetUsers(ctx context.Context) ([]domain.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*domain.User, error)
 	GetUserByEmailOrUsername(ctx context.Context, identifier string) (*domain.User, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
 	GetUserAvatar(ctx context.Context, userID uuid.UUID) (*string, error)
+	GetUserPasswordHash(ctx context.Context, userID uuid.UUID) (string, error)
+	// UpdateUserPassword sets the user's password hash and, in the same transaction,
+	// bumps their token generation and revokes all their sessions, signing them out
+	// everywhere. It returns the new token generation.
+	UpdateUserPassword(ctx context.Context, userID uuid.UUID, hashedPassword string) (int, error)
+	GetTokenGeneration(ctx context.Context, userID uuid.UUID) (int, error)
 	UpdateUserAvatar(ctx context.Context, userID uuid.UUID, avatarURL *string) error
+	UpdateUserTimezone(ctx context.Context, userID uuid.UUID, timezone string) error
+	// EnsureCalendarToken returns the user's calendar feed token, setting it to token
+	// if the user has none yet.
+	EnsureCalendarToken(ctx context.Context, userID uuid.UUID, token string) (string, error)
+	SetCalendarToken(ctx context.Context, userID uuid.UUID, token string) error
+	GetUserByCalendarToken(ctx context.Context, token string) (*domain.User, error)
 	DeleteUser(ctx context.Context, userID uuid.UUID) error
 	SearchUsersByUsername(ctx context.Context, query string) ([]domain.PublicUser, error)
 }
 
 type HabitRepository interface {
+	// CreateHabit stores a new habit together with its Tags, in one transaction. It
+	// fails with ErrTagNotFound, creating nothing, if any tag does not belong to the
+	// habit's owner.
 	CreateHabit(ctx context.Context, habit *domain.Habit) error
 	GetHabitsByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Habit, error)
 	GetHabitByID(ctx context.Context, habitID uuid.UUID) (*domain.Habit, error)
-	UpdateHabit(ctx context.Context, habit *domain.Habit) error
+	// UpdateHabit stores the habit's changes and, if setTags is set, replaces its tags
+	// with its Tags in the same transaction, failing like CreateHabit.
+	UpdateHabit(ctx context.Context, habit *domain.Habit, setTags bool) error
+	// ConvertHabitType switches a habit to habit.IsBoolean and habit.Target and, in the
+	// same transaction, rewrites the values of its done logs: to 1 for a boolean habit,
+	// or to doneValue for a numeric one. The rewrites are recorded as log revisions
+	// made by the habit's owner.
+	ConvertHabitType(ctx context.Context, habit *domain.Habit, doneValue float64) error
+	// DeleteHabit moves a habit to the trash, from which RestoreHabit can bring it back
+	// until PurgeDeletedHabits removes it for good.
 	DeleteHabit(ctx context.Context, habitID, userID uuid.UUID) error
-	UpsertHabitLog(ctx context.Context, log *domain.HabitLog) error
-	GetLogsForHabits(ctx context.Context, habitIDs []uuid.UUID) ([]domain.HabitLog, error)
+	GetArchivedHabitsByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Habit, error)
+	GetDeletedHabitsByUserID(ctx context.Context, userID uuid.UUID, deletedAfter time.Time) ([]domain.Habit, error)
+	SetHabitArchived(ctx context.Context, habitID, userID uuid.UUID, archived bool) error
+	RestoreHabit(ctx context.Context, habitID, userID uuid.UUID, deletedAfter time.Time) error
+	SetHabitPinned(ctx context.Context, habitID, userID uuid.UUID, pinned bool) error
+	// ReorderHabits atomically moves the listed habits, in order, to the front of the
+	// user's habits. It fails with ErrHabitNotFound, changing nothing, if any of them
+	// does not belong to the user.
+	ReorderHabits(ctx context.Context, userID uuid.UUID, habitIDs []uuid.UUID) error
+	PurgeDeletedHabits(ctx context.Context, deletedBefore time.Time) (int64, error)
+	// The log writes below record each change to a log as a revision made by actorID.
+	UpsertHabitLog(ctx context.Context, log *domain.HabitLog, actorID uuid.UUID) error
+	DeleteHabitLog(ctx context.Context, habitID uuid.UUID, date time.Time, actorID uuid.UUID) error
+	// ClearHabitLog removes the value of a habit's log on date. A log without a note is
+	// deleted, returning nil; one with a note is kept as a done log with a zero value
+	// and returned.
+	ClearHabitLog(ctx context.Context, habitID uuid.UUID, date time.Time, actorID uuid.UUID) (*domain.HabitLog, error)
+	// IncrementHabitLog atomically adds delta, which may be negative, to the value done
+	// on log.LogDate, counting from zero if the day has no log. The result is clamped
+	// at zero and a day brought down to zero is cleared like ClearHabitLog, unless
+	// keepZero is set. It fails with ErrHabitLogNotDone if the day is skipped or
+	// failed, and with ErrLogValueTooLarge if the result would exceed maxValue. log
+	// receives the resulting log.
+	IncrementHabitLog(ctx context.Context, log *domain.HabitLog, delta, maxValue float64, keepZero bool, actorID uuid.UUID) error
+	// UpsertHabitLogs writes a batch of a habit's logs and clears its logs on the
+	// cleared dates like ClearHabitLog, all in one transaction. It reports the dates
+	// that gained a new log and those whose log was cleared.
+	UpsertHabitLogs(ctx context.Context, habitID uuid.UUID, logs []domain.HabitLog, cleared []time.Time, actorID uuid.UUID) (created, removed []time.Time, err error)
+	// InsertHabitLogs adds a batch of a habit's logs on the days that have none yet,
+	// leaving existing logs and their notes as they are. It reports the dates that
+	// gained a log.
+	InsertHabitLogs(ctx context.Context, habitID uuid.UUID, logs []domain.HabitLog, actorID uuid.UUID) (created []time.Time, err error)
+	// GetHabitLogRevisions returns the changes to a habit's log on date, newest first.
+	GetHabitLogRevisions(ctx context.Context, habitID uuid.UUID, date time.Time) ([]domain.HabitLogRevision, error)
+	// UndoHabitLog restores a habit's log on date to how it was before its latest
+	// change not undone yet, recording that as a revision which it returns. Repeated
+	// undos walk further back. It fails with ErrNothingToUndo when no change is left.
+	UndoHabitLog(ctx context.Context, habitID uuid.UUID, date time.Time, actorID uuid.UUID) (*domain.HabitLogRevision, error)
+	GetLogsForHabits(ctx context.Context, habitIDs []uuid.UUID, filter LogFilter) ([]domain.HabitLog, error)
+	// GetHabitLogsPage returns up to limit logs of a habit dated before the cursor day
+	// (or any day if before is zero), newest first.
+	GetHabitLogsPage(ctx context.Context, habitID uuid.UUID, filter LogFilter, before time.Time, limit int) ([]domain.HabitLog, error)
+	GetHabitStats(ctx context.Context, habitID uuid.UUID, from, to time.Time) (*domain.HabitStats, error)
+	// GetHabitAdoptionCounts counts, per habit, the other users with a habit cloned
+	// from it that is not in the trash. Habits nobody adopted are left out.
+	GetHabitAdoptionCounts(ctx context.Context, habitIDs []uuid.UUID) (map[uuid.UUID]int, error)
 }
 
 type FollowerRepository interface {
@@ -60,7 +145,56 @@ type FollowerRepository interface {
 
 type DashboardRepository interface {
 	GetLeaderboard(ctx context.Context, limit int) ([]domain.LeaderboardEntry, error)
-	GetExplorePage(ctx context.Context, limit int) ([]domain.ExploreEntry, error)
+	// GetExplorePage lists recently logged habits, optionally only those in category.
+	GetExplorePage(ctx context.Context, limit int, category domain.HabitCategory) ([]domain.ExploreEntry, error)
+}
+
+type TagRepository interface {
+	CreateTag(ctx context.Context, tag *domain.Tag) error
+	GetTagsByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Tag, error)
+	UpdateTag(ctx context.Context, tag *domain.Tag) error
+	DeleteTag(ctx context.Context, tagID, userID uuid.UUID) error
+	GetTagsForHabits(ctx context.Context, habitIDs []uuid.UUID) (map[uuid.UUID][]domain.Tag, error)
+}
+
+// TemplateRepository stores the habit templates users publish. Curated templates are
+// not stored; they come from the catalogue file. A published template is hidden while
+// its habit is in the trash and goes when the habit is purged.
+type TemplateRepository interface {
+	// PublishHabitTemplate saves a template of a user's habit, replacing the one
+	// published from the same habit before, if any.
+	PublishHabitTemplate(ctx context.Context, template *domain.HabitTemplate, userID, habitID uuid.UUID) error
+	// GetHabitTemplates lists up to limit published templates, newest first,
+	// optionally only those in category.
+	GetHabitTemplates(ctx context.Context, category domain.HabitCategory, limit int) ([]domain.HabitTemplate, error)
+	GetHabitTemplateByID(ctx context.Context, templateID uuid.UUID) (*domain.HabitTemplate, error)
+	DeleteHabitTemplate(ctx context.Context, templateID, userID uuid.UUID) error
+}
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *domain.Session) error
+	GetSessionByID(ctx context.Context, sessionID uuid.UUID) (*domain.Session, error)
+	// RotateSession stores the session's new refresh token hash, client and expiry,
+	// provided it is active and its token hash is still oldHash. Otherwise it fails
+	// with ErrSessionNotFound.
+	RotateSession(ctx context.Context, session *domain.Session, oldHash []byte) error
+	// GetActiveSessions returns the user's sessions that are neither revoked nor
+	// expired, most recently used first.
+	GetActiveSessions(ctx context.Context, userID uuid.UUID) ([]domain.Session, error)
+	// RevokeSession ends one of the user's active sessions.
+	RevokeSession(ctx context.Context, sessionID, userID uuid.UUID) error
+	// GetAccessTokenState looks up, in one query, the user's token generation and
+	// whether sessionID is one of their active sessions. It fails with ErrUserNotFound
+	// if the user does not exist.
+	GetAccessTokenState(ctx context.Context, userID, sessionID uuid.UUID) (generation int, sessionActive bool, err error)
+}
+
+type AchievementRepository interface {
+	// AwardAchievements records that the user earned the achievements at earnedAt,
+	// keeping the time of those earned before. It returns the newly earned ones.
+	AwardAchievements(ctx context.Context, userID uuid.UUID, achievements []domain.AchievementID, earnedAt time.Time) ([]domain.AchievementID, error)
+	// GetUserAchievements returns when the user earned each of their achievements.
+	GetUserAchievements(ctx context.Context, userID uuid.UUID) (map[domain.AchievementID]time.Time, error)
 }
 
 type IRepository interface {
@@ -68,4 +202,8 @@ type IRepository interface {
 	HabitRepository
 	FollowerRepository
 	DashboardRepository
+	TagRepository
+	TemplateRepository
+	AchievementRepository
+	SessionRepository
 }
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/repository"
+	"github.com/google/uuid"
+)
+
+// achievementFacts are what achievements are judged on. Facts gathered from part of
+// a user's data are enough: achievements are only ever awarded, never taken back.
+type achievementFacts struct {
+	// completed counts the logs, up to today, that completed a habit to build. Skipped
+	// and failed days do not count, nor do the relapses logged for negative habits.
+	completed int
+	// streak is the longest run, in days, of a daily habit or a quit habit.
+	streak int
+	// total is the highest amount done on a single numeric habit.
+	total     float64
+	followers int
+}
+
+type achievementRule struct {
+	domain.Achievement
+	earned func(achievementFacts) bool
+}
+
+func streakOf(days int) func(achievementFacts) bool {
+	return func(f achievementFacts) bool { return f.streak >= days }
+}
+
+// achievementRules lists every achievement, in the order they are shown.
+var achievementRules = []achievementRule{
+	{
+		Achievement: domain.Achievement{ID: domain.AchievementFirstLog, Name: "First step", Description: "Log a habit for the first time"},
+		earned:      func(f achievementFacts) bool { return f.completed > 0 },
+	},
+	{
+		Achievement: domain.Achievement{ID: domain.AchievementStreak7, Name: "One week", Description: "Keep a 7-day streak"},
+		earned:      streakOf(7),
+	},
+	{
+		Achievement: domain.Achievement{ID: domain.AchievementStreak30, Name: "One month", Description: "Keep a 30-day streak"},
+		earned:      streakOf(30),
+	},
+	{
+		Achievement: domain.Achievement{ID: domain.AchievementStreak100, Name: "Centurion", Description: "Keep a 100-day streak"},
+		earned:      streakOf(100),
+	},
+	{
+		Achievement: domain.Achievement{ID: domain.AchievementStreak365, Name: "One year", Description: "Keep a 365-day streak"},
+		earned:      streakOf(365),
+	},
+	{
+		Achievement: domain.Achievement{ID: domain.AchievementTotal1000, Name: "Thousand", Description: "Log 1,000 units on a single habit"},
+		earned:      func(f achievementFacts) bool { return f.total >= 1000 },
+	},
+	{
+		Achievement: domain.Achievement{ID: domain.AchievementFollowers10, Name: "Role model", Description: "Be followed by 10 people"},
+		earned:      func(f achievementFacts) bool { return f.followers >= 10 },
+	},
+}
+
+// addHabit folds the history of one habit into the facts. Streaks of habits counted
+// in weeks or months are not counted in days, so only daily habits count towards
+// the streak achievements.
+func (f *achievementFacts) addHabit(habit domain.Habit, logs []domain.HabitLog, today time.Time, loc *time.Location) {
+	if habit.Polarity == domain.PolarityNegative || habit.Schedule.Frequency == domain.FrequencyDaily {
+		f.streak = max(f.streak, ComputeStreak(habit, logs, today, loc).Longest)
+	}
+
+	if habit.Polarity == domain.PolarityNegative {
+		return
+	}
+	var total float64
+	for _, log := range logs {
+		if _, done := logCompletion(habit, log); done && !dateOf(log.LogDate).After(today) {
+			f.completed++
+		}
+		if log.Status == domain.LogDone {
+			total += log.Value
+		}
+	}
+	if !habit.IsBoolean {
+		f.total = max(f.total, total)
+	}
+}
+
+func (f achievementFacts) earned() []domain.AchievementID {
+	var ids []domain.AchievementID
+	for _, rule := range achievementRules {
+		if rule.earned(f) {
+			ids = append(ids, rule.ID)
+		}
+	}
+	return ids
+}
+
+// awardAchievements records the achievements the facts earn the user. Ones already
+// earned keep their original time, so it is safe to call again.
+func (s *Service) awardAchievements(ctx context.Context, userID uuid.UUID, facts achievementFacts) ([]domain.AchievementID, error) {
+	earned := facts.earned()
+	if len(earned) == 0 {
+		return nil, nil
+	}
+	return s.repo.AwardAchievements(ctx, userID, earned, s.now())
+}
+
+// checkHabitAchievements awards the achievements the given habits earn their owner.
+// It runs after the habits' logs were written, which succeeded regardless, so failures
+// are only logged.
+func (s *Service) checkHabitAchievements(ctx context.Context, owner *domain.User, habits []domain.Habit) {
+	if len(habits) == 0 {
+		return
+	}
+	facts, err := s.habitFacts(ctx, owner, habits)
+	if err == nil {
+		_, err = s.awardAchievements(ctx, owner.ID, facts)
+	}
+	if err != nil {
+		slog.Warn("failed to check habit achievements", "userID", owner.ID, "error", err)
+	}
+}
+
+// habitFacts gathers the facts of the owner's habits from their full history.
+func (s *Service) habitFacts(ctx context.Context, owner *domain.User, habits []domain.Habit) (achievementFacts, error) {
+	habitIDs := make([]uuid.UUID, len(habits))
+	for i, habit := range habits {
+		habitIDs[i] = habit.ID
+	}
+	history, err := s.logsByHabit(ctx, habitIDs, repository.LogFilter{})
+	if err != nil {
+		return achievementFacts{}, err
+	}
+
+	var facts achievementFacts
+	today, loc := s.todayIn(owner.Timezone), ownerLocation(owner.Timezone)
+	for _, habit := range habits {
+		facts.addHabit(habit, history[habit.ID], today, loc)
+	}
+	return facts, nil
+}
+
+// checkFollowerAchievements awards the achievements for the user's followers. Like
+// checkHabitAchievements, failures are only logged.
+func (s *Service) checkFollowerAchievements(ctx context.Context, userID uuid.UUID) {
+	followers, err := s.repo.GetFollowerCount(ctx, userID)
+	if err == nil {
+		_, err = s.awardAchievements(ctx, userID, achievementFacts{followers: followers})
+	}
+	if err != nil {
+		slog.Warn("failed to check follower achievements", "userID", userID, "error", err)
+	}
+}
+
+// evaluateAchievements judges all of a user's data, including archived habits, and
+// awards whatever achievements it earns.
+func (s *Service) evaluateAchievements(ctx context.Context, user *domain.User) ([]domain.AchievementID, error) {
+	active, err := s.repo.GetHabitsByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := s.repo.GetArchivedHabitsByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	facts, err := s.habitFacts(ctx, user, append(active, archived...))
+	if err != nil {
+		return nil, err
+	}
+	if facts.followers, err = s.repo.GetFollowerCount(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	return s.awardAchievements(ctx, user.ID, facts)
+}
+
+// BackfillAchievements evaluates every user's achievements, awarding those earned
+// before achievements existed or missed since. It returns how many were awarded and
+// can be re-run at any time.
+func (s *Service) BackfillAchievements(ctx context.Context) (int, error) {
+	users, err := s.repo.GetUsers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var awarded int
+	for i := range users {
+		ids, err := s.evaluateAchievements(ctx, &users[i])
+		if err != nil {
+			return awarded, fmt.Errorf("failed to evaluate achievements of user %s: %w", users[i].ID, err)
+		}
+		awarded += len(ids)
+	}
+	return awarded, nil
+}
+
+// GetUserAchievements returns the achievements the user earned, in catalogue order.
+func (s *Service) GetUserAchievements(ctx context.Context, userID uuid.UUID) ([]domain.UserAchievement, error) {
+	earned, err := s.repo.GetUserAchievements(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	achievements := make([]domain.UserAchievement, 0, len(earned))
+	for _, rule := range achievementRules {
+		if earnedAt, ok := earned[rule.ID]; ok {
+			achievements = append(achievements, domain.UserAchievement{Achievement: rule.Achievement, EarnedAt: earnedAt})
+		}
+	}
+	return achievements, nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ArchiveHabit hides a habit from its owner's profile. Its logs, streak and stats are
+// kept, but it can no longer be logged until it is unarchived.
+func (s *Service) ArchiveHabit(ctx context.Context, habitID, userID uuid.UUID) error {
+	return s.setHabitArchived(ctx, habitID, userID, true)
+}
+
+func (s *Service) UnarchiveHabit(ctx context.Context, habitID, userID uuid.UUID) error {
+	return s.setHabitArchived(ctx, habitID, userID, false)
+}
+
+// visibleHabit loads a habit for a viewer. Archived habits are hidden from profiles,
+// so only their owner can still see them.
+func (s *Service) visibleHabit(ctx context.Context, habitID, viewerID uuid.UUID) (*domain.Habit, error) {
+	habit, err := s.repo.GetHabitByID(ctx, habitID)
+	if err != nil {
+		return nil, err
+	}
+	if habit.ArchivedAt != nil && habit.UserID != viewerID {
+		return nil, repository.ErrHabitNotFound
+	}
+	return habit, nil
+}
+
+func (s *Service) setHabitArchived(ctx context.Context, habitID, userID uuid.UUID, archived bool) error {
+	err := s.repo.SetHabitArchived(ctx, habitID, userID, archived)
+	if err != nil {
+		if errors.Is(err, repository.ErrHabitNotFound) {
+			return ErrUserAccessDenied
+		}
+		return err
+	}
+	return nil
+}
+
+// GetArchivedHabits returns the user's archived habits with their full history.
+func (s *Service) GetArchivedHabits(ctx context.Context, userID uuid.UUID) ([]domain.HabitWithLogs, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	habits, err := s.repo.GetArchivedHabitsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachTags(ctx, habits); err != nil {
+		return nil, err
+	}
+
+	today := s.todayIn(user.Timezone)
+	return s.attachLogs(ctx, habits, time.Time{}, today, today, ownerLocation(user.Timezone))
+}
+
+// GetDeletedHabits returns the habits in the user's trash that can still be restored.
+func (s *Service) GetDeletedHabits(ctx context.Context, userID uuid.UUID, retention time.Duration) ([]domain.Habit, error) {
+	return s.repo.GetDeletedHabitsByUserID(ctx, userID, s.now().Add(-retention))
+}
+
+// RestoreHabit takes a habit out of the trash, provided it was deleted within the
+// retention period.
+func (s *Service) RestoreHabit(ctx context.Context, habitID, userID uuid.UUID, retention time.Duration) error {
+	return s.repo.RestoreHabit(ctx, habitID, userID, s.now().Add(-retention))
+}
+
+// PurgeDeletedHabits permanently removes habits, and with them their logs, that have
+// been in the trash for longer than the retention period.
+func (s *Service) PurgeDeletedHabits(ctx context.Context, retention time.Duration) (int64, error) {
+	return s.repo.PurgeDeletedHabits(ctx, s.now().Add(-retention))
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/google/uuid"
+)
+
+// MaxBatchLogs caps the number of entries in one batch of logs.
+const MaxBatchLogs = 5000
+
+var (
+	ErrBatchTooLarge = errors.New("too many logs in batch")
+	ErrInvalidBatch  = errors.New("batch contains invalid logs")
+)
+
+// BatchLogEntry is one day of a batch of logs. Date is formatted as YYYY-MM-DD.
+type BatchLogEntry struct {
+	Date   string
+	Value  float64
+	Status domain.LogStatus
+}
+
+// BackfillHabitLogs writes many days of a habit's history at once. Entries follow the
+// rules of LogHabit, and a done entry with a zero value clears its day unless the
+// habit has an "at most" target. The batch is
+// all or nothing: if any entry is invalid, nothing is written and ErrInvalidBatch is
+// returned along with the results pointing out the offending entries.
+func (s *Service) BackfillHabitLogs(ctx context.Context, habitID uuid.UUID, entries []BatchLogEntry, userID uuid.UUID) ([]domain.LogBatchResult, error) {
+	if len(entries) > MaxBatchLogs {
+		return nil, ErrBatchTooLarge
+	}
+
+	habit, err := s.writableHabit(ctx, habitID, userID)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	today := s.todayIn(owner.Timezone)
+
+	results := make([]domain.LogBatchResult, len(entries))
+	seen := make(map[string]bool, len(entries))
+	var logs []domain.HabitLog
+	var cleared []time.Time
+	valid := true
+	for i, entry := range entries {
+		results[i] = domain.LogBatchResult{Index: i, Date: entry.Date, Outcome: domain.BatchUnchanged}
+		reject := func(reason string) {
+			results[i].Outcome = domain.BatchInvalid
+			results[i].Error = reason
+			valid = false
+		}
+
+		date, err := time.Parse(time.DateOnly, entry.Date)
+		if err != nil {
+			reject("invalid date format, please use YYYY-MM-DD")
+			continue
+		}
+		if date.After(today) {
+			reject(ErrFutureLogDate.Error())
+			continue
+		}
+		if seen[entry.Date] {
+			reject("duplicate date")
+			continue
+		}
+		seen[entry.Date] = true
+		if entry.Value < 0 {
+			reject("value must not be negative")
+			continue
+		}
+		if entry.Value > MaxLogValue {
+			reject(fmt.Sprintf("value must not exceed %d", MaxLogValue))
+			continue
+		}
+		status, value, err := normalizeLogStatus(entry.Status, entry.Value)
+		if err != nil {
+			reject(err.Error())
+			continue
+		}
+
+		if status == domain.LogDone && value == 0 && !hasLimit(*habit) {
+			cleared = append(cleared, date)
+			continue
+		}
+		logs = append(logs, domain.HabitLog{
+			ID:      uuid.New(),
+			HabitID: habit.ID,
+			LogDate: date,
+			Value:   value,
+			Status:  status,
+		})
+	}
+	if !valid {
+		return results, ErrInvalidBatch
+	}
+
+	created, removed, err := s.repo.UpsertHabitLogs(ctx, habit.ID, logs, cleared, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.checkHabitAchievements(ctx, owner, []domain.Habit{*habit})
+
+	outcomes := make(map[string]domain.LogBatchOutcome, len(entries))
+	for _, log := range logs {
+		outcomes[log.LogDate.Format(time.DateOnly)] = domain.BatchUpdated
+	}
+	for _, date := range created {
+		outcomes[date.Format(time.DateOnly)] = domain.BatchCreated
+	}
+	for _, date := range removed {
+		outcomes[date.Format(time.DateOnly)] = domain.BatchCleared
+	}
+	for i := range results {
+		if outcome, ok := outcomes[results[i].Date]; ok {
+			results[i].Outcome = outcome
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/ical"
+	"github.com/axseem/peakstreak/internal/repository"
+	"github.com/google/uuid"
+)
+
+const calendarProdID = "-//PeakStreak//Habit Completions//EN"
+
+// newCalendarToken returns a random token for a user's secret calendar feed URL.
+func newCalendarToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetCalendarToken returns the token of the user's calendar feed, creating one on
+// first use.
+func (s *Service) GetCalendarToken(ctx context.Context, userID uuid.UUID) (string, error) {
+	token, err := newCalendarToken()
+	if err != nil {
+		return "", err
+	}
+	return s.repo.EnsureCalendarToken(ctx, userID, token)
+}
+
+// RegenerateCalendarToken replaces the user's calendar feed token, so that feed URLs
+// shared before stop working.
+func (s *Service) RegenerateCalendarToken(ctx context.Context, userID uuid.UUID) (string, error) {
+	token, err := newCalendarToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.SetCalendarToken(ctx, userID, token); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CalendarFeed builds the calendar of the completions of the user the token belongs
+// to, with an all-day event per completed log in the default log window. If habitID
+// is set, the calendar only covers that habit.
+func (s *Service) CalendarFeed(ctx context.Context, token string, habitID *uuid.UUID) (*ical.Calendar, error) {
+	user, err := s.repo.GetUserByCalendarToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	habits, err := s.GetAllHabitsWithLogs(ctx, user, HabitFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &ical.Calendar{ProdID: calendarProdID, Name: "PeakStreak: " + user.Username}
+	if habitID != nil {
+		var found bool
+		for _, habit := range habits {
+			if habit.ID == *habitID {
+				habits, found = []domain.HabitWithLogs{habit}, true
+				break
+			}
+		}
+		if !found {
+			return nil, repository.ErrHabitNotFound
+		}
+		cal.Name = "PeakStreak: " + habits[0].Name
+	}
+
+	for _, habit := range habits {
+		for _, log := range habit.Logs {
+			if !log.Completed {
+				continue
+			}
+			description := "Done"
+			if !habit.IsBoolean {
+				description = "Value: " + formatValue(habit.Habit, log.Value)
+			}
+			cal.Events = append(cal.Events, ical.Event{
+				UID:         log.ID.String() + "@peakstreak",
+				Date:        log.LogDate,
+				Summary:     habit.Name,
+				Description: description,
+				Stamp:       log.UpdatedAt,
+			})
+		}
+	}
+	return cal, nil
+}
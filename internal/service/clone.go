@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/google/uuid"
+)
+
+// CloneHabit copies a habit the user can see, their own or someone else's, into the
+// user's habits. The copy starts without logs or tags and records its source, so the
+// original's owner can see how many people adopted it.
+func (s *Service) CloneHabit(ctx context.Context, habitID, userID uuid.UUID) (*domain.Habit, error) {
+	source, err := s.visibleHabit(ctx, habitID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := CreateHabitParams{
+		Name:          source.Name,
+		ColorHue:      source.ColorHue,
+		IsBoolean:     source.IsBoolean,
+		Polarity:      string(source.Polarity),
+		Schedule:      source.Schedule,
+		Target:        source.Target,
+		SourceHabitID: &source.ID,
+	}
+	if source.Unit != nil {
+		params.Unit = *source.Unit
+	}
+	if source.Category != nil {
+		params.Category = string(*source.Category)
+	}
+	return s.CreateHabit(ctx, params, userID)
+}
+
+// attachAdoptions fills in how many other users adopted each habit.
+func (s *Service) attachAdoptions(ctx context.Context, habits []domain.HabitWithLogs) error {
+	if len(habits) == 0 {
+		return nil
+	}
+
+	habitIDs := make([]uuid.UUID, len(habits))
+	for i, habit := range habits {
+		habitIDs[i] = habit.ID
+	}
+
+	counts, err := s.repo.GetHabitAdoptionCounts(ctx, habitIDs)
+	if err != nil {
+		return err
+	}
+	for i := range habits {
+		habits[i].Adoptions = counts[habits[i].ID]
+	}
+	return nil
+}
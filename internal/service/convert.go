@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrHabitTypeUnchanged = errors.New("habit already has this type")
+	ErrInvalidDoneValue   = errors.New("a value for done days of at least 1 is required")
+)
+
+// ConvertHabitParams describes the type a habit is converted to. Converting to a
+// numeric habit records DoneValue for every done day, measured against Target.
+// Converting to a boolean habit marks every day with a positive value as done.
+type ConvertHabitParams struct {
+	IsBoolean bool
+	DoneValue float64
+	Target    domain.Target
+	Unit      string
+}
+
+// PreviewHabitConversion reports what ConvertHabit would do, without changing anything.
+func (s *Service) PreviewHabitConversion(ctx context.Context, habitID, userID uuid.UUID, params ConvertHabitParams) (*domain.HabitConversionPreview, error) {
+	habit, converted, err := s.prepareConversion(ctx, habitID, userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := s.repo.GetLogsForHabits(ctx, []uuid.UUID{habit.ID}, repository.LogFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &domain.HabitConversionPreview{
+		IsBoolean: converted.IsBoolean,
+		Target:    converted.Target,
+		Logs:      len(logs),
+	}
+	for _, log := range logs {
+		if _, done := logCompletion(*habit, log); done {
+			preview.CompletedBefore++
+		}
+		after := log
+		after.Value = convertedValue(converted, log, params.DoneValue)
+		if after.Value != log.Value {
+			preview.ChangedLogs++
+		}
+		if _, done := logCompletion(converted, after); done {
+			preview.CompletedAfter++
+		}
+	}
+	return preview, nil
+}
+
+// ConvertHabit switches a habit between the boolean and numeric types, converting its
+// history in the same transaction.
+func (s *Service) ConvertHabit(ctx context.Context, habitID, userID uuid.UUID, params ConvertHabitParams) (*domain.Habit, error) {
+	_, converted, err := s.prepareConversion(ctx, habitID, userID, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ConvertHabitType(ctx, &converted, roundValue(params.DoneValue)); err != nil {
+		return nil, err
+	}
+	return &converted, nil
+}
+
+// prepareConversion validates a conversion, returning the habit as it is and as it
+// would be after it.
+func (s *Service) prepareConversion(ctx context.Context, habitID, userID uuid.UUID, params ConvertHabitParams) (*domain.Habit, domain.Habit, error) {
+	habit, err := s.writableHabit(ctx, habitID, userID)
+	if err != nil {
+		return nil, domain.Habit{}, err
+	}
+	if habit.IsBoolean == params.IsBoolean {
+		return nil, domain.Habit{}, ErrHabitTypeUnchanged
+	}
+
+	var target domain.Target
+	if params.IsBoolean {
+		target, err = normalizeTarget(true, domain.Target{})
+	} else {
+		if params.DoneValue <= 0 {
+			return nil, domain.Habit{}, ErrInvalidDoneValue
+		}
+		target, err = normalizeTarget(false, params.Target)
+	}
+	if err != nil {
+		return nil, domain.Habit{}, err
+	}
+	unit, err := normalizeUnit(params.IsBoolean, params.Unit)
+	if err != nil {
+		return nil, domain.Habit{}, err
+	}
+
+	converted := *habit
+	converted.IsBoolean = params.IsBoolean
+	converted.Target = target
+	converted.Unit = unit
+	if err := checkPolarity(converted); err != nil {
+		return nil, domain.Habit{}, err
+	}
+	return habit, converted, nil
+}
+
+// convertedValue is the value a log takes when its habit is converted.
+func convertedValue(converted domain.Habit, log domain.HabitLog, doneValue float64) float64 {
+	if log.Status != "" && log.Status != domain.LogDone || log.Value <= 0 {
+		return log.Value
+	}
+	if converted.IsBoolean {
+		return 1
+	}
+	return roundValue(doneValue)
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/repository"
+	"github.com/google/uuid"
+)
+
+// AccountExport holds everything about an account that fits comfortably in memory.
+// Logs and the avatar are read as the archive is written.
+type AccountExport struct {
+	User      *domain.User
+	Habits    []domain.Habit
+	Followers []domain.PublicUser
+	Following []domain.PublicUser
+	svc       *Service
+}
+
+// ExportAccount gathers a user's data for a full export, including archived habits and
+// those in the trash.
+func (s *Service) ExportAccount(ctx context.Context, userID uuid.UUID) (*AccountExport, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := s.repo.GetHabitsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := s.repo.GetArchivedHabitsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.repo.GetDeletedHabitsByUserID(ctx, userID, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	habits := append(append(active, archived...), deleted...)
+	if err := s.attachTags(ctx, habits); err != nil {
+		return nil, err
+	}
+
+	followers, err := s.repo.GetFollowers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	following, err := s.repo.GetFollowing(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccountExport{
+		User:      user,
+		Habits:    habits,
+		Followers: followers,
+		Following: following,
+		svc:       s,
+	}, nil
+}
+
+var logCSVHeader = []string{"habit_id", "habit_name", "date", "value", "status", "note", "note_private", "created_at", "updated_at"}
+
+// WriteZip streams the export to w as a zip archive. Logs are loaded one habit at a
+// time, so the archive is never held in memory as a whole.
+func (e *AccountExport) WriteZip(ctx context.Context, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", e.User},
+		{"habits.json", e.Habits},
+		{"followers.json", e.Followers},
+		{"following.json", e.Following},
+	}
+	for _, f := range files {
+		if err := writeZipJSON(zw, f.name, f.data); err != nil {
+			return err
+		}
+	}
+
+	if err := e.writeLogs(ctx, zw); err != nil {
+		return err
+	}
+	if err := e.writeAvatar(ctx, zw); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeZipJSON(zw *zip.Writer, name string, data any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// writeLogs writes logs.json and logs.csv. A zip archive can only be written one file
+// at a time, so the logs are read twice rather than buffered.
+func (e *AccountExport) writeLogs(ctx context.Context, zw *zip.Writer) error {
+	f, err := zw.Create("logs.json")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "["); err != nil {
+		return err
+	}
+	first := true
+	err = e.eachHabitLogs(ctx, func(habit domain.Habit, logs []domain.HabitLog) error {
+		for _, log := range logs {
+			if !first {
+				if _, err := io.WriteString(f, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			data, err := json.Marshal(log)
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "]\n"); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("logs.csv")
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	if err := cw.Write(logCSVHeader); err != nil {
+		return err
+	}
+	err = e.eachHabitLogs(ctx, func(habit domain.Habit, logs []domain.HabitLog) error {
+		for _, log := range logs {
+			note := ""
+			if log.Note != nil {
+				note = *log.Note
+			}
+			record := []string{
+				habit.ID.String(),
+				habit.Name,
+				log.LogDate.Format(time.DateOnly),
+				strconv.FormatFloat(log.Value, 'f', -1, 64),
+				string(log.Status),
+				note,
+				strconv.FormatBool(log.NotePrivate),
+				log.CreatedAt.UTC().Format(time.RFC3339),
+				log.UpdatedAt.UTC().Format(time.RFC3339),
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// eachHabitLogs calls fn with every habit's complete log history, in date order.
+func (e *AccountExport) eachHabitLogs(ctx context.Context, fn func(domain.Habit, []domain.HabitLog) error) error {
+	for _, habit := range e.Habits {
+		logs, err := e.svc.repo.GetLogsForHabits(ctx, []uuid.UUID{habit.ID}, repository.LogFilter{})
+		if err != nil {
+			return err
+		}
+		evaluateLogs(habit, logs)
+		if err := fn(habit, logs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *AccountExport) writeAvatar(ctx context.Context, zw *zip.Writer) error {
+	if e.User.AvatarURL == nil || *e.User.AvatarURL == "" {
+		return nil
+	}
+
+	src, err := e.svc.storage.Open(ctx, *e.User.AvatarURL)
+	if err != nil {
+		return fmt.Errorf("could not open avatar: %w", err)
+	}
+	defer src.Close()
+
+	f, err := zw.Create("avatar" + path.Ext(*e.User.AvatarURL))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, src)
+	return err
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/importer"
+	"github.com/google/uuid"
+)
+
+// ImportHabits adds the habits and history in a Loop Habit Tracker export or a generic
+// CSV file to a user's account. Imported habits are matched to the user's habits by
+// name and their logs are added only on days without a log, so the user's own logs
+// are kept and re-running an import changes nothing. A habit matching an archived
+// habit, or one its history does not fit, is skipped, see fitsHabit. History dated
+// after the user's current day is dropped. A dry run only reports what would be
+// imported.
+func (s *Service) ImportHabits(ctx context.Context, userID uuid.UUID, file io.ReaderAt, size int64, dryRun bool) (*domain.ImportSummary, error) {
+	format, imported, err := importer.Parse(file, size)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	today := s.todayIn(owner.Timezone)
+
+	active, err := s.repo.GetHabitsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := s.repo.GetArchivedHabitsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]domain.Habit)
+	for _, habit := range append(active, archived...) {
+		byName[strings.ToLower(habit.Name)] = habit
+	}
+	isArchived := make(map[uuid.UUID]bool, len(archived))
+	for _, habit := range archived {
+		isArchived[habit.ID] = true
+	}
+
+	var written []domain.Habit
+	summary := &domain.ImportSummary{Format: string(format), DryRun: dryRun, Habits: make([]domain.ImportedHabit, 0, len(imported))}
+	for _, ih := range imported {
+		var entries []importer.Entry
+		for _, e := range ih.Entries {
+			if !e.Date.After(today) {
+				entries = append(entries, e)
+			}
+		}
+
+		item := domain.ImportedHabit{Name: ih.Name, IsBoolean: ih.IsBoolean, ColorHue: ih.ColorHue, Logs: len(entries)}
+		if len(entries) > 0 {
+			item.From, item.To = &entries[0].Date, &entries[len(entries)-1].Date
+		}
+
+		key := strings.ToLower(ih.Name)
+		habit, exists := byName[key]
+		if exists {
+			item.IsBoolean, item.ColorHue = habit.IsBoolean, habit.ColorHue
+			if isArchived[habit.ID] || !fitsHabit(format, ih, habit) {
+				item.Skipped = true
+				if habit.ID != uuid.Nil {
+					id := habit.ID
+					item.HabitID = &id
+				}
+				summary.Habits = append(summary.Habits, item)
+				continue
+			}
+		} else {
+			item.Created = true
+			habit = domain.Habit{Name: ih.Name, IsBoolean: ih.IsBoolean, ColorHue: ih.ColorHue}
+		}
+
+		if !dryRun {
+			if !exists {
+				created, err := s.CreateHabit(ctx, CreateHabitParams{
+					Name:      ih.Name,
+					ColorHue:  ih.ColorHue,
+					IsBoolean: ih.IsBoolean,
+					Schedule:  ih.Schedule,
+					Target:    ih.Target,
+					Unit:      ih.Unit,
+				}, userID)
+				if err != nil {
+					return nil, err
+				}
+				habit = *created
+			}
+
+			logs := make([]domain.HabitLog, len(entries))
+			for i, e := range entries {
+				logs[i] = domain.HabitLog{ID: uuid.New(), HabitID: habit.ID, LogDate: e.Date, Value: roundValue(e.Value), Status: e.Status}
+			}
+			if len(logs) > 0 {
+				created, err := s.repo.InsertHabitLogs(ctx, habit.ID, logs, userID)
+				if err != nil {
+					return nil, err
+				}
+				item.NewLogs = len(created)
+				if !slices.ContainsFunc(written, func(h domain.Habit) bool { return h.ID == habit.ID }) {
+					written = append(written, habit)
+				}
+			}
+		}
+
+		// A later habit of the same name merges into this one.
+		byName[key] = habit
+		if habit.ID != uuid.Nil {
+			id := habit.ID
+			item.HabitID = &id
+		}
+		summary.Habits = append(summary.Habits, item)
+	}
+	s.checkHabitAchievements(ctx, owner, written)
+	return summary, nil
+}
+
+// fitsHabit reports whether an imported history can be merged into an existing habit
+// without changing what its logs mean. Amounts do not fit a yes/no habit, and a yes/no
+// habit from Loop does not fit a numeric one. In a CSV file, a history of ones may
+// just as well be amounts, so it fits either.
+func fitsHabit(format importer.Format, ih importer.Habit, habit domain.Habit) bool {
+	if habit.IsBoolean {
+		return ih.IsBoolean
+	}
+	return !ih.IsBoolean || format == importer.FormatCSV
+}
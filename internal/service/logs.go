@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/repository"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// DefaultLogWindowDays is how many days of logs, ending on the owner's current day,
+// habit responses embed unless another window is requested.
+const DefaultLogWindowDays = 53 * 7
+
+const (
+	DefaultLogPageSize = 100
+	MaxLogPageSize     = 500
+)
+
+// LogWindow bounds the logs embedded in habit responses, inclusive. A zero To
+// defaults to the owner's current day and a zero From to DefaultLogWindowDays
+// before To.
+type LogWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+func (w LogWindow) resolve(today time.Time) (from, to time.Time, err error) {
+	to = dateOf(w.To)
+	if w.To.IsZero() {
+		to = today
+	}
+	from = dateOf(w.From)
+	if w.From.IsZero() {
+		from = to.AddDate(0, 0, 1-DefaultLogWindowDays)
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, ErrInvalidDateRange
+	}
+	return from, to, nil
+}
+
+type HabitLogsParams struct {
+	HabitID uuid.UUID
+	// From and To optionally bound the logs, inclusive.
+	From time.Time
+	To   time.Time
+	// Cursor is the NextCursor of the previous page, empty for the first page.
+	Cursor string
+	Limit  int
+}
+
+// GetHabitLogs pages through a habit's logs, newest first. Like profiles, the logs
+// are visible to any viewer, except for archived habits, which only their owner sees.
+func (s *Service) GetHabitLogs(ctx context.Context, params HabitLogsParams, viewerID uuid.UUID) (*domain.HabitLogPage, error) {
+	if !params.From.IsZero() && !params.To.IsZero() && params.From.After(params.To) {
+		return nil, ErrInvalidDateRange
+	}
+
+	var before time.Time
+	if params.Cursor != "" {
+		var err error
+		before, err = time.Parse(time.DateOnly, params.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = DefaultLogPageSize
+	}
+	limit = min(limit, MaxLogPageSize)
+
+	habit, err := s.visibleHabit(ctx, params.HabitID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := repository.LogFilter{From: params.From, To: params.To}
+	logs, err := s.repo.GetHabitLogsPage(ctx, habit.ID, filter, before, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.HabitLogPage{Logs: logs}
+	if len(logs) > limit {
+		page.Logs = logs[:limit]
+		cursor := page.Logs[limit-1].LogDate.Format(time.DateOnly)
+		page.NextCursor = &cursor
+	}
+	evaluateLogs(*habit, page.Logs)
+	if viewerID != habit.UserID {
+		stripPrivateNotes(page.Logs)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/axseem/peakstreak/internal/domain"
+)
+
+// MaxNoteLength is the maximum length of a log note, in characters.
+const MaxNoteLength = 500
+
+var ErrNoteTooLong = fmt.Errorf("note cannot be longer than %d characters", MaxNoteLength)
+
+// normalizeNote trims a note and checks its length. A nil note stays nil, meaning the
+// existing note is kept; an empty one clears it.
+func normalizeNote(note *string) (*string, error) {
+	if note == nil {
+		return nil, nil
+	}
+	trimmed := strings.TrimSpace(*note)
+	if utf8.RuneCountInString(trimmed) > MaxNoteLength {
+		return nil, ErrNoteTooLong
+	}
+	return &trimmed, nil
+}
+
+// stripPrivateNotes removes the notes their owner has kept private, for responses
+// seen by anyone else.
+func stripPrivateNotes(logs []domain.HabitLog) {
+	for i := range logs {
+		if logs[i].NotePrivate {
+			logs[i].Note = nil
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/axseem/peakstreak/internal/repository"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidHabitOrder = errors.New("invalid habit order")
+
+// ReorderHabits arranges the user's habits in the order of habitIDs. Habits left out
+// keep their relative order after the listed ones. Pinned habits are still listed
+// before all others.
+func (s *Service) ReorderHabits(ctx context.Context, userID uuid.UUID, habitIDs []uuid.UUID) error {
+	if len(habitIDs) == 0 {
+		return fmt.Errorf("%w: no habits given", ErrInvalidHabitOrder)
+	}
+	seen := make(map[uuid.UUID]bool, len(habitIDs))
+	for _, id := range habitIDs {
+		if seen[id] {
+			return fmt.Errorf("%w: habit %s is listed more than once", ErrInvalidHabitOrder, id)
+		}
+		seen[id] = true
+	}
+
+	err := s.repo.ReorderHabits(ctx, userID, habitIDs)
+	if err != nil {
+		if errors.Is(err, repository.ErrHabitNotFound) {
+			return ErrUserAccessDenied
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Service) PinHabit(ctx context.Context, habitID, userID uuid.UUID) error {
+	return s.setHabitPinned(ctx, habitID, userID, true)
+}
+
+func (s *Service) UnpinHabit(ctx context.Context, habitID, userID uuid.UUID) error {
+	return s.setHabitPinned(ctx, habitID, userID, false)
+}
+
+func (s *Service) setHabitPinned(ctx context.Context, habitID, userID uuid.UUID, pinned bool) error {
+	err := s.repo.SetHabitPinned(ctx, habitID, userID, pinned)
+	if err != nil {
+		if errors.Is(err, repository.ErrHabitNotFound) {
+			return ErrUserAccessDenied
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/axseem/peakstreak/internal/domain"
+)
+
+var ErrInvalidPolarity = errors.New("invalid habit polarity")
+
+// normalizePolarity validates a polarity. An empty one defaults to a positive habit.
+func normalizePolarity(polarity string) (domain.HabitPolarity, error) {
+	switch p := domain.HabitPolarity(polarity); p {
+	case "":
+		return domain.PolarityPositive, nil
+	case domain.PolarityPositive, domain.PolarityNegative:
+		return p, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidPolarity, polarity)
+	}
+}
+
+// checkPolarity validates a habit against its polarity. Negative habits count clean
+// days, so they are tracked daily and have no target to reach.
+func checkPolarity(habit domain.Habit) error {
+	if habit.Polarity != domain.PolarityNegative {
+		return nil
+	}
+	if habit.Frequency != domain.FrequencyDaily {
+		return fmt.Errorf("%w: negative habits are tracked daily", ErrInvalidPolarity)
+	}
+	if habit.Target.Value != nil {
+		return fmt.Errorf("%w: negative habits cannot have a target", ErrInvalidPolarity)
+	}
+	return nil
+}
+
+// isRelapse reports whether a log of a negative habit records a relapse. Skipped days
+// are excused and failed days were resisted, so only days marked as done count.
+func isRelapse(log domain.HabitLog) bool {
+	return (log.Status == "" || log.Status == domain.LogDone) && log.Value > 0
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/google/uuid"
+)
+
+// GetHabitLogHistory returns every change to the log of a day, newest first. Like the
+// logs themselves, the history is visible to any viewer, except for archived habits,
+// which only their owner sees.
+func (s *Service) GetHabitLogHistory(ctx context.Context, habitID uuid.UUID, date time.Time, viewerID uuid.UUID) ([]domain.HabitLogRevision, error) {
+	habit, err := s.visibleHabit(ctx, habitID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetHabitLogRevisions(ctx, habit.ID, dateOf(date))
+}
+
+// UndoHabitLog restores the log of a day to how it was before its latest change,
+// returning the revision that records the undo. Undoing again reverts the change
+// before that. Notes are not part of the history: a restored log keeps its note, and
+// a log brought back after being deleted has none.
+func (s *Service) UndoHabitLog(ctx context.Context, habitID uuid.UUID, date time.Time, userID uuid.UUID) (*domain.HabitLogRevision, error) {
+	habit, err := s.writableHabit(ctx, habitID, userID)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := s.repo.UndoHabitLog(ctx, habit.ID, dateOf(date), userID)
+	if err != nil {
+		return nil, err
+	}
+	s.checkHabitAchievements(ctx, owner, []domain.Habit{*habit})
+	return revision, nil
+}
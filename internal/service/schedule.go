@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/domain"
+)
+
+var ErrInvalidSchedule = errors.New("invalid habit schedule")
+
+// normalizeSchedule validates a schedule and returns it in canonical form. An empty
+// frequency defaults to a daily habit.
+func normalizeSchedule(schedule domain.Schedule) (domain.Schedule, error) {
+	switch schedule.Frequency {
+	case "", domain.FrequencyDaily:
+		return domain.Schedule{Frequency: domain.FrequencyDaily, Weekdays: []int{}}, nil
+
+	case domain.FrequencyWeekdays:
+		if len(schedule.Weekdays) == 0 {
+			return domain.Schedule{}, fmt.Errorf("%w: at least one weekday is required", ErrInvalidSchedule)
+		}
+		weekdays := slices.Clone(schedule.Weekdays)
+		for _, d := range weekdays {
+			if d < int(time.Sunday) || d > int(time.Saturday) {
+				return domain.Schedule{}, fmt.Errorf("%w: weekday %d is out of range", ErrInvalidSchedule, d)
+			}
+		}
+		slices.Sort(weekdays)
+		return domain.Schedule{Frequency: domain.FrequencyWeekdays, Weekdays: slices.Compact(weekdays)}, nil
+
+	case domain.FrequencyWeekly, domain.FrequencyMonthly:
+		limit := 7
+		if schedule.Frequency == domain.FrequencyMonthly {
+			limit = 31
+		}
+		if schedule.TimesPerPeriod < 1 || schedule.TimesPerPeriod > limit {
+			return domain.Schedule{}, fmt.Errorf("%w: a %s habit must be done between 1 and %d times", ErrInvalidSchedule, schedule.Frequency, limit)
+		}
+		return domain.Schedule{Frequency: schedule.Frequency, Weekdays: []int{}, TimesPerPeriod: schedule.TimesPerPeriod}, nil
+
+	default:
+		return domain.Schedule{}, fmt.Errorf("%w: unknown frequency %q", ErrInvalidSchedule, schedule.Frequency)
+	}
+}
+
+// isDue reports whether a day-based schedule expects a completion on day.
+func isDue(schedule domain.Schedule, day time.Time) bool {
+	return isDueOn(schedule, day.Weekday())
+}
+
+// isDueOn reports whether a day-based schedule expects a completion on the weekday.
+func isDueOn(schedule domain.Schedule, weekday time.Weekday) bool {
+	if schedule.Frequency != domain.FrequencyWeekdays || len(schedule.Weekdays) == 0 {
+		return true
+	}
+	return slices.Contains(schedule.Weekdays, int(weekday))
+}
+
+// periodStart returns the first day of the schedule period containing day. Daily and
+// weekday schedules have one-day periods, weekly periods start on Monday.
+func periodStart(schedule domain.Schedule, day time.Time) time.Time {
+	switch schedule.Frequency {
+	case domain.FrequencyWeekly:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case domain.FrequencyMonthly:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// nextPeriodStart returns the first day of the period following the one starting at start.
+func nextPeriodStart(schedule domain.Schedule, start time.Time) time.Time {
+	switch schedule.Frequency {
+	case domain.FrequencyWeekly:
+		return start.AddDate(0, 0, 7)
+	case domain.FrequencyMonthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// requiredPerPeriod returns how many completions a schedule period needs.
+func requiredPerPeriod(schedule domain.Schedule) int {
+	switch schedule.Frequency {
+	case domain.FrequencyWeekly, domain.FrequencyMonthly:
+		return max(schedule.TimesPerPeriod, 1)
+	default:
+		return 1
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/axseem/peakstreak/internal/auth"
 	"github.com/axseem/peakstreak/internal/domain"
 	"github.com/axseem/peakstreak/internal/repository"
 	"github.com/axseem/peakstreak/internal/storage"
@@ -20,8 +19,14 @@ import (
 
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrIncorrectPassword  = errors.New("current password is incorrect")
 	ErrUserAccessDenied   = errors.New("user does not have permission to access this resource")
 	ErrCannotFollowSelf   = errors.New("cannot follow yourself")
+	ErrInvalidTimezone    = errors.New("invalid timezone")
+	ErrFutureLogDate      = errors.New("cannot log a habit for a future date")
+	ErrHabitArchived      = errors.New("habit is archived")
+	ErrInvalidLogStatus   = errors.New("invalid log status")
+	ErrInvalidIncrement   = errors.New("invalid increment")
 )
 
 const (
@@ -31,8 +36,13 @@ const (
 
 const (
 	MAX_AVATAR_SIZE = 2 * MB
+	MAX_IMPORT_SIZE = 10 * MB
 )
 
+// MaxLogValue is the largest value a log can hold, the same bound the API validates
+// single log values against. Larger values overflow the value column.
+const MaxLogValue = 999999999999
+
 var allowedMimeTypes = map[string]bool{
 	"image/jpeg": true,
 	"image/png":  true,
@@ -41,22 +51,60 @@ var allowedMimeTypes = map[string]bool{
 type Service struct {
 	repo    repository.IRepository
 	storage storage.FileStorage
+	now     func() time.Time
+	// templates is the catalogue of curated habit templates.
+	templates []domain.HabitTemplate
 }
 
 func New(repo repository.IRepository, storage storage.FileStorage) *Service {
 	return &Service{
 		repo:    repo,
 		storage: storage,
+		now:     time.Now,
 	}
 }
 
+// loadTimezone resolves an IANA timezone name, treating an empty name as UTC.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || loc == time.Local {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimezone, name)
+	}
+	return loc, nil
+}
+
+// ownerLocation returns the location of a user's timezone, falling back to UTC if
+// the timezone is unknown.
+func ownerLocation(timezone string) *time.Location {
+	loc, err := loadTimezone(timezone)
+	if err != nil {
+		slog.Warn("unknown timezone, falling back to UTC", "timezone", timezone)
+		return time.UTC
+	}
+	return loc
+}
+
+// todayIn returns the current calendar day in the given timezone, see ownerLocation.
+func (s *Service) todayIn(timezone string) time.Time {
+	return dateOf(s.now().In(ownerLocation(timezone)))
+}
+
 type CreateUserParams struct {
 	Username string
 	Email    string
 	Password string
+	Timezone string
 }
 
 func (s *Service) CreateUser(ctx context.Context, params CreateUserParams) (*domain.User, error) {
+	loc, err := loadTimezone(params.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
@@ -67,6 +115,7 @@ func (s *Service) CreateUser(ctx context.Context, params CreateUserParams) (*dom
 		Username:       params.Username,
 		Email:          params.Email,
 		HashedPassword: string(hashedPassword),
+		Timezone:       loc.String(),
 	}
 
 	if err := s.repo.CreateUser(ctx, user); err != nil {
@@ -80,29 +129,61 @@ func (s *Service) CreateUser(ctx context.Context, params CreateUserParams) (*dom
 type LoginUserParams struct {
 	Identifier string
 	Password   string
+	Client     SessionClient
 }
 
-func (s *Service) LoginUser(ctx context.Context, params LoginUserParams, jwtSecret string, jwtExpiresIn time.Duration) (*domain.User, string, error) {
+// LoginUser checks a user's credentials and starts a session for the device.
+func (s *Service) LoginUser(ctx context.Context, params LoginUserParams, tokens TokenConfig) (*domain.User, *AuthTokens, error) {
 	user, err := s.repo.GetUserByEmailOrUsername(ctx, params.Identifier)
 	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
-			return nil, "", ErrInvalidCredentials
+			return nil, nil, ErrInvalidCredentials
 		}
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(params.Password))
 	if err != nil {
-		return nil, "", ErrInvalidCredentials
+		return nil, nil, ErrInvalidCredentials
 	}
 
-	token, err := auth.GenerateToken(user.ID, jwtSecret, jwtExpiresIn)
+	issued, err := s.startSession(ctx, user.ID, user.TokenGeneration, params.Client, tokens)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+		return nil, nil, err
 	}
 
 	user.HashedPassword = ""
-	return user, token, nil
+	return user, issued, nil
+}
+
+type ChangePasswordParams struct {
+	CurrentPassword string
+	NewPassword     string
+	Client          SessionClient
+}
+
+// ChangePassword replaces the user's password after checking the current one. Every
+// token issued before stops working, signing the user out everywhere; the device
+// making the change is signed back in with the returned tokens.
+func (s *Service) ChangePassword(ctx context.Context, userID uuid.UUID, params ChangePasswordParams, tokens TokenConfig) (*AuthTokens, error) {
+	currentHash, err := s.repo.GetUserPasswordHash(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(currentHash), []byte(params.CurrentPassword)); err != nil {
+		return nil, ErrIncorrectPassword
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	generation, err := s.repo.UpdateUserPassword(ctx, userID, string(hashedPassword))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, userID, generation, params.Client, tokens)
 }
 
 func (s *Service) DeleteUser(ctx context.Context, userID uuid.UUID) error {
@@ -174,6 +255,14 @@ func (s *Service) UpdateUserAvatar(ctx context.Context, userID uuid.UUID, file m
 	return publicURL, nil
 }
 
+func (s *Service) UpdateUserTimezone(ctx context.Context, userID uuid.UUID, timezone string) error {
+	loc, err := loadTimezone(timezone)
+	if err != nil {
+		return err
+	}
+	return s.repo.UpdateUserTimezone(ctx, userID, loc.String())
+}
+
 func (s *Service) SearchUsers(ctx context.Context, query string) ([]domain.PublicUser, error) {
 	if strings.TrimSpace(query) == "" {
 		return []domain.PublicUser{}, nil
@@ -191,22 +280,26 @@ func (s *Service) GetUserByUsername(ctx context.Context, username string) (*doma
 }
 
 type ProfileData struct {
-	User           *domain.User           `json:"user"`
-	Habits         []domain.HabitWithLogs `json:"habits"`
-	IsOwner        bool                   `json:"isOwner"`
-	FollowersCount int                    `json:"followersCount"`
-	FollowingCount int                    `json:"followingCount"`
-	IsFollowing    bool                   `json:"isFollowing"`
+	User           *domain.User             `json:"user"`
+	Habits         []domain.HabitWithLogs   `json:"habits"`
+	IsOwner        bool                     `json:"isOwner"`
+	FollowersCount int                      `json:"followersCount"`
+	FollowingCount int                      `json:"followingCount"`
+	IsFollowing    bool                     `json:"isFollowing"`
+	Achievements   []domain.UserAchievement `json:"achievements"`
 }
 
-func (s *Service) GetProfileData(ctx context.Context, username string, authenticatedUserID uuid.UUID) (*ProfileData, error) {
+func (s *Service) GetProfileData(ctx context.Context, username string, authenticatedUserID uuid.UUID, filter HabitFilter) (*ProfileData, error) {
 	user, err := s.repo.GetUserByUsername(ctx, username)
 	if err != nil {
 		return nil, err
 	}
 	user.HashedPassword = ""
 
-	habits, err := s.GetAllHabitsWithLogs(ctx, user.ID)
+	habits, err := s.GetAllHabitsWithLogs(ctx, user, filter)
+	if errors.Is(err, ErrInvalidDateRange) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get habits: %w", err)
 	}
@@ -222,6 +315,21 @@ func (s *Service) GetProfileData(ctx context.Context, username string, authentic
 	}
 
 	isOwner := user.ID == authenticatedUserID
+	if isOwner {
+		if err := s.attachAdoptions(ctx, habits); err != nil {
+			return nil, fmt.Errorf("failed to get adoption counts: %w", err)
+		}
+	} else {
+		for i := range habits {
+			stripPrivateNotes(habits[i].Logs)
+		}
+	}
+
+	achievements, err := s.GetUserAchievements(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get achievements: %w", err)
+	}
+
 	var isFollowing bool
 	if !isOwner && authenticatedUserID != uuid.Nil {
 		isFollowing, err = s.repo.IsFollowing(ctx, authenticatedUserID, user.ID)
@@ -237,6 +345,7 @@ func (s *Service) GetProfileData(ctx context.Context, username string, authentic
 		FollowersCount: followersCount,
 		FollowingCount: followingCount,
 		IsFollowing:    isFollowing,
+		Achievements:   achievements,
 	}, nil
 }
 
@@ -244,15 +353,60 @@ type CreateHabitParams struct {
 	Name      string
 	ColorHue  int
 	IsBoolean bool
+	Polarity  string
+	Schedule  domain.Schedule
+	Target    domain.Target
+	Unit      string
+	Category  string
+	TagIDs    []uuid.UUID
+	// SourceHabitID records the habit the new one is cloned from.
+	SourceHabitID *uuid.UUID
 }
 
 func (s *Service) CreateHabit(ctx context.Context, params CreateHabitParams, userID uuid.UUID) (*domain.Habit, error) {
+	polarity, err := normalizePolarity(params.Polarity)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := normalizeSchedule(params.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	target, err := normalizeTarget(params.IsBoolean, params.Target)
+	if err != nil {
+		return nil, err
+	}
+	unit, err := normalizeUnit(params.IsBoolean, params.Unit)
+	if err != nil {
+		return nil, err
+	}
+	category, err := normalizeCategory(params.Category)
+	if err != nil {
+		return nil, err
+	}
+	var tags []domain.Tag
+	if len(params.TagIDs) > 0 {
+		if tags, err = s.resolveTags(ctx, userID, params.TagIDs); err != nil {
+			return nil, err
+		}
+	}
+
 	habit := &domain.Habit{
-		ID:        uuid.New(),
-		UserID:    userID,
-		Name:      params.Name,
-		ColorHue:  params.ColorHue,
-		IsBoolean: params.IsBoolean,
+		ID:            uuid.New(),
+		UserID:        userID,
+		Name:          params.Name,
+		ColorHue:      params.ColorHue,
+		IsBoolean:     params.IsBoolean,
+		Polarity:      polarity,
+		Schedule:      schedule,
+		Target:        target,
+		Unit:          unit,
+		Category:      category,
+		SourceHabitID: params.SourceHabitID,
+		Tags:          tags,
+	}
+	if err := checkPolarity(*habit); err != nil {
+		return nil, err
 	}
 
 	if err := s.repo.CreateHabit(ctx, habit); err != nil {
@@ -265,6 +419,14 @@ func (s *Service) CreateHabit(ctx context.Context, params CreateHabitParams, use
 type UpdateHabitParams struct {
 	Name     string
 	ColorHue int
+	// Polarity, Schedule, Target, Unit, Category and TagIDs replace the habit's
+	// current ones when set. An empty Unit or Category clears it.
+	Polarity *string
+	Schedule *domain.Schedule
+	Target   *domain.Target
+	Unit     *string
+	Category *string
+	TagIDs   *[]uuid.UUID
 }
 
 func (s *Service) UpdateHabit(ctx context.Context, params UpdateHabitParams, habitID, userID uuid.UUID) (*domain.Habit, error) {
@@ -278,8 +440,51 @@ func (s *Service) UpdateHabit(ctx context.Context, params UpdateHabitParams, hab
 
 	habit.Name = params.Name
 	habit.ColorHue = params.ColorHue
+	if params.Polarity != nil {
+		polarity, err := normalizePolarity(*params.Polarity)
+		if err != nil {
+			return nil, err
+		}
+		habit.Polarity = polarity
+	}
+	if params.Schedule != nil {
+		schedule, err := normalizeSchedule(*params.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		habit.Schedule = schedule
+	}
+	if params.Target != nil {
+		target, err := normalizeTarget(habit.IsBoolean, *params.Target)
+		if err != nil {
+			return nil, err
+		}
+		habit.Target = target
+	}
+	if params.Unit != nil {
+		unit, err := normalizeUnit(habit.IsBoolean, *params.Unit)
+		if err != nil {
+			return nil, err
+		}
+		habit.Unit = unit
+	}
+	if params.Category != nil {
+		category, err := normalizeCategory(*params.Category)
+		if err != nil {
+			return nil, err
+		}
+		habit.Category = category
+	}
+	if err := checkPolarity(*habit); err != nil {
+		return nil, err
+	}
+	if params.TagIDs != nil {
+		if habit.Tags, err = s.resolveTags(ctx, userID, *params.TagIDs); err != nil {
+			return nil, err
+		}
+	}
 
-	if err := s.repo.UpdateHabit(ctx, habit); err != nil {
+	if err := s.repo.UpdateHabit(ctx, habit, params.TagIDs != nil); err != nil {
 		return nil, err
 	}
 
@@ -297,12 +502,40 @@ func (s *Service) DeleteHabit(ctx context.Context, habitID, userID uuid.UUID) er
 	return nil
 }
 
-func (s *Service) GetAllHabitsWithLogs(ctx context.Context, userID uuid.UUID) ([]domain.HabitWithLogs, error) {
-	habits, err := s.repo.GetHabitsByUserID(ctx, userID)
+// HabitFilter selects the habits listed on a profile and the logs embedded in them.
+type HabitFilter struct {
+	Window LogWindow
+	// Tag, if set, limits the habits to those carrying a tag of that name.
+	Tag string
+}
+
+// GetAllHabitsWithLogs returns the user's habits matching filter, with the logs inside
+// its window, judged against the user's local day. Streaks cover the full history
+// whatever the window.
+func (s *Service) GetAllHabitsWithLogs(ctx context.Context, user *domain.User, filter HabitFilter) ([]domain.HabitWithLogs, error) {
+	today := s.todayIn(user.Timezone)
+	from, to, err := filter.Window.resolve(today)
 	if err != nil {
 		return nil, err
 	}
 
+	habits, err := s.repo.GetHabitsByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachTags(ctx, habits); err != nil {
+		return nil, err
+	}
+	if filter.Tag != "" {
+		habits = withTag(habits, filter.Tag)
+	}
+
+	return s.attachLogs(ctx, habits, from, to, today, ownerLocation(user.Timezone))
+}
+
+// attachLogs embeds the logs of habits between from and to and computes their streaks
+// from their full history up to today, in the owner's location loc.
+func (s *Service) attachLogs(ctx context.Context, habits []domain.Habit, from, to, today time.Time, loc *time.Location) ([]domain.HabitWithLogs, error) {
 	if len(habits) == 0 {
 		return []domain.HabitWithLogs{}, nil
 	}
@@ -312,60 +545,225 @@ func (s *Service) GetAllHabitsWithLogs(ctx context.Context, userID uuid.UUID) ([
 		habitIDs[i] = habit.ID
 	}
 
-	logs, err := s.repo.GetLogsForHabits(ctx, habitIDs)
+	embedded, err := s.logsByHabit(ctx, habitIDs, repository.LogFilter{From: from, To: to})
 	if err != nil {
 		return nil, err
 	}
-
-	logsByHabitID := make(map[uuid.UUID][]domain.HabitLog)
-	for _, log := range logs {
-		logsByHabitID[log.HabitID] = append(logsByHabitID[log.HabitID], log)
+	history, err := s.logsByHabit(ctx, habitIDs, repository.LogFilter{To: today})
+	if err != nil {
+		return nil, err
 	}
 
 	habitsWithLogs := make([]domain.HabitWithLogs, len(habits))
 	for i, habit := range habits {
-		logsForHabit, ok := logsByHabitID[habit.ID]
-		if !ok {
-			logsForHabit = make([]domain.HabitLog, 0)
-		}
-		habitsWithLogs[i] = domain.HabitWithLogs{
-			Habit: habit,
-			Logs:  logsForHabit,
+		logs := embedded[habit.ID]
+		if logs == nil {
+			logs = []domain.HabitLog{}
 		}
+		habitsWithLogs[i] = domain.HabitWithLogs{Habit: habit, Logs: logs}
+		finalizeHabit(&habitsWithLogs[i], history[habit.ID], today, loc)
 	}
 
 	return habitsWithLogs, nil
 }
 
+// logsByHabit loads the logs of the given habits matching filter, grouped by habit.
+func (s *Service) logsByHabit(ctx context.Context, habitIDs []uuid.UUID, filter repository.LogFilter) (map[uuid.UUID][]domain.HabitLog, error) {
+	logsByHabitID := make(map[uuid.UUID][]domain.HabitLog)
+	if len(habitIDs) == 0 {
+		return logsByHabitID, nil
+	}
+
+	logs, err := s.repo.GetLogsForHabits(ctx, habitIDs, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, log := range logs {
+		logsByHabitID[log.HabitID] = append(logsByHabitID[log.HabitID], log)
+	}
+	return logsByHabitID, nil
+}
+
+// finalizeHabit fills in the fields derived from a habit's logs, computing the streak
+// from its full history rather than from the windowed logs of the response.
+func finalizeHabit(habit *domain.HabitWithLogs, history []domain.HabitLog, today time.Time, loc *time.Location) {
+	evaluateLogs(habit.Habit, habit.Logs)
+	habit.Streak = ComputeStreak(habit.Habit, history, today, loc)
+}
+
 type LogHabitParams struct {
 	HabitID uuid.UUID
 	Date    time.Time
-	Value   int
+	Value   float64
+	// Status defaults to done. A done day with a zero value has nothing to record
+	// but its note, so logging one removes the day's log unless it keeps a note or
+	// the habit has an "at most" target, see hasLimit.
+	Status domain.LogStatus
+	// Note replaces the log's note when set; an empty note removes it. NotePrivate
+	// applies to the new note and defaults to private.
+	Note        *string
+	NotePrivate *bool
+}
+
+// normalizeLogStatus defaults an empty status to done. Skipped and failed days carry
+// no value.
+func normalizeLogStatus(status domain.LogStatus, value float64) (domain.LogStatus, float64, error) {
+	switch status {
+	case "", domain.LogDone:
+		return domain.LogDone, roundValue(value), nil
+	case domain.LogSkipped, domain.LogFailed:
+		return status, 0, nil
+	default:
+		return "", 0, fmt.Errorf("%w: %q", ErrInvalidLogStatus, status)
+	}
 }
 
 func (s *Service) LogHabit(ctx context.Context, params LogHabitParams, userID uuid.UUID) (*domain.HabitLog, error) {
-	habit, err := s.repo.GetHabitByID(ctx, params.HabitID)
+	status, value, err := normalizeLogStatus(params.Status, params.Value)
 	if err != nil {
 		return nil, err
 	}
-	if habit.UserID != userID {
-		return nil, ErrUserAccessDenied
+
+	habit, err := s.writableHabit(ctx, params.HabitID, userID)
+	if err != nil {
+		return nil, err
+	}
+	note, err := normalizeNote(params.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if dateOf(params.Date).After(s.todayIn(owner.Timezone)) {
+		return nil, ErrFutureLogDate
+	}
+
+	if status == domain.LogDone && value <= 0 && !hasLimit(*habit) {
+		cleared := &domain.HabitLog{HabitID: habit.ID, LogDate: params.Date, Status: domain.LogDone}
+		switch {
+		case note == nil:
+			kept, err := s.repo.ClearHabitLog(ctx, habit.ID, params.Date, userID)
+			if err != nil {
+				return nil, err
+			}
+			if kept != nil {
+				kept.Completion, kept.Completed = logCompletion(*habit, *kept)
+				return kept, nil
+			}
+			return cleared, nil
+		case *note == "":
+			err := s.repo.DeleteHabitLog(ctx, habit.ID, params.Date, userID)
+			if err != nil && !errors.Is(err, repository.ErrHabitLogNotFound) {
+				return nil, err
+			}
+			return cleared, nil
+		}
+		// A new note is kept on a log with a zero value.
 	}
 
 	log := &domain.HabitLog{
 		ID:      uuid.New(),
 		HabitID: params.HabitID,
 		LogDate: params.Date,
-		Value:   params.Value,
+		Value:   value,
+		Status:  status,
+		Note:    note,
+		// Notes are private unless the owner says otherwise.
+		NotePrivate: params.NotePrivate == nil || *params.NotePrivate,
+	}
+
+	if err := s.repo.UpsertHabitLog(ctx, log, userID); err != nil {
+		return nil, err
+	}
+	s.checkHabitAchievements(ctx, owner, []domain.Habit{*habit})
+
+	log.Completion, log.Completed = logCompletion(*habit, *log)
+	return log, nil
+}
+
+type IncrementHabitLogParams struct {
+	HabitID uuid.UUID
+	Date    time.Time
+	// Delta is added to the day's value and may be negative.
+	Delta float64
+}
+
+// IncrementHabitLog adds to the value logged on a day of a numeric habit without
+// reading it first, so counters updated from several devices do not lose counts.
+// The value never drops below zero and never exceeds MaxLogValue; a day brought down
+// to zero is cleared unless it keeps a note or the habit has an "at most" target.
+// Skipped and failed days cannot be incremented.
+func (s *Service) IncrementHabitLog(ctx context.Context, params IncrementHabitLogParams, userID uuid.UUID) (*domain.HabitLog, error) {
+	delta := roundValue(params.Delta)
+	if delta == 0 {
+		return nil, fmt.Errorf("%w: delta must not be zero", ErrInvalidIncrement)
+	}
+
+	habit, err := s.writableHabit(ctx, params.HabitID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if habit.IsBoolean {
+		return nil, fmt.Errorf("%w: only numeric habits can be incremented", ErrInvalidIncrement)
+	}
+
+	owner, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if dateOf(params.Date).After(s.todayIn(owner.Timezone)) {
+		return nil, ErrFutureLogDate
 	}
 
-	if err := s.repo.UpsertHabitLog(ctx, log); err != nil {
+	log := &domain.HabitLog{
+		ID:          uuid.New(),
+		HabitID:     habit.ID,
+		LogDate:     params.Date,
+		NotePrivate: true,
+	}
+	err = s.repo.IncrementHabitLog(ctx, log, delta, MaxLogValue, hasLimit(*habit), userID)
+	switch {
+	case errors.Is(err, repository.ErrHabitLogNotDone):
+		return nil, fmt.Errorf("%w: only days marked as done can be incremented", ErrInvalidIncrement)
+	case errors.Is(err, repository.ErrLogValueTooLarge):
+		return nil, fmt.Errorf("%w: value must not exceed %d", ErrInvalidIncrement, MaxLogValue)
+	case err != nil:
 		return nil, err
 	}
+	s.checkHabitAchievements(ctx, owner, []domain.Habit{*habit})
 
+	log.Completion, log.Completed = logCompletion(*habit, *log)
 	return log, nil
 }
 
+// DeleteHabitLog removes the log of a day, returning the day to having no entry.
+func (s *Service) DeleteHabitLog(ctx context.Context, habitID uuid.UUID, date time.Time, userID uuid.UUID) error {
+	habit, err := s.writableHabit(ctx, habitID, userID)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteHabitLog(ctx, habit.ID, date, userID)
+}
+
+// writableHabit loads a habit whose logs the user is about to change.
+func (s *Service) writableHabit(ctx context.Context, habitID, userID uuid.UUID) (*domain.Habit, error) {
+	habit, err := s.repo.GetHabitByID(ctx, habitID)
+	if err != nil {
+		return nil, err
+	}
+	if habit.UserID != userID {
+		return nil, ErrUserAccessDenied
+	}
+	if habit.ArchivedAt != nil {
+		return nil, ErrHabitArchived
+	}
+	return habit, nil
+}
+
 func (s *Service) FollowUserByUsername(ctx context.Context, followerID uuid.UUID, usernameToFollow string) error {
 	userToFollow, err := s.repo.GetUserByUsername(ctx, usernameToFollow)
 	if err != nil {
@@ -374,7 +772,11 @@ func (s *Service) FollowUserByUsername(ctx context.Context, followerID uuid.UUID
 	if followerID == userToFollow.ID {
 		return ErrCannotFollowSelf
 	}
-	return s.repo.FollowUser(ctx, followerID, userToFollow.ID)
+	if err := s.repo.FollowUser(ctx, followerID, userToFollow.ID); err != nil {
+		return err
+	}
+	s.checkFollowerAchievements(ctx, userToFollow.ID)
+	return nil
 }
 
 func (s *Service) UnfollowUserByUsername(ctx context.Context, followerID uuid.UUID, usernameToUnfollow string) error {
@@ -402,9 +804,61 @@ func (s *Service) GetFollowing(ctx context.Context, username string) ([]domain.P
 }
 
 func (s *Service) GetLeaderboard(ctx context.Context) ([]domain.LeaderboardEntry, error) {
-	return s.repo.GetLeaderboard(ctx, 50)
+	entries, err := s.repo.GetLeaderboard(ctx, 50)
+	if err != nil {
+		return nil, err
+	}
+
+	var habitIDs []uuid.UUID
+	for _, entry := range entries {
+		for _, habit := range entry.Habits {
+			habitIDs = append(habitIDs, habit.ID)
+		}
+	}
+	// The repository embeds only the recent logs; streaks need the full history.
+	history, err := s.logsByHabit(ctx, habitIDs, repository.LogFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		today, loc := s.todayIn(entries[i].Timezone), ownerLocation(entries[i].Timezone)
+		for j := range entries[i].Habits {
+			habit := &entries[i].Habits[j]
+			finalizeHabit(habit, history[habit.ID], today, loc)
+		}
+	}
+	return entries, nil
 }
 
-func (s *Service) GetExplorePage(ctx context.Context) ([]domain.ExploreEntry, error) {
-	return s.repo.GetExplorePage(ctx, 20)
+// GetExplorePage lists recently logged habits, optionally only those in category.
+func (s *Service) GetExplorePage(ctx context.Context, category string) ([]domain.ExploreEntry, error) {
+	c, err := normalizeCategory(category)
+	if err != nil {
+		return nil, err
+	}
+	var filter domain.HabitCategory
+	if c != nil {
+		filter = *c
+	}
+
+	entries, err := s.repo.GetExplorePage(ctx, 20, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	habitIDs := make([]uuid.UUID, len(entries))
+	for i, entry := range entries {
+		habitIDs[i] = entry.Habit.ID
+	}
+	history, err := s.logsByHabit(ctx, habitIDs, repository.LogFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		habit := &entries[i].Habit
+		finalizeHabit(habit, history[habit.ID], s.todayIn(entries[i].Timezone), ownerLocation(entries[i].Timezone))
+	}
+	return entries, nil
 }
@@ -1,19 +1,27 @@
 package service
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"errors"
 	"io"
+	"maps"
 	"mime/multipart"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/axseem/peakstreak/internal/auth"
 	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/importer"
 	"github.com/axseem/peakstreak/internal/repository"
+	"github.com/axseem/peakstreak/internal/templates"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -67,6 +75,11 @@ func (m *MockRepository) UpdateUserAvatar(ctx context.Context, userID uuid.UUID,
 	return args.Error(0)
 }
 
+func (m *MockRepository) UpdateUserTimezone(ctx context.Context, userID uuid.UUID, timezone string) error {
+	args := m.Called(ctx, userID, timezone)
+	return args.Error(0)
+}
+
 func (m *MockRepository) DeleteUser(ctx context.Context, userID uuid.UUID) error {
 	args := m.Called(ctx, userID)
 	return args.Error(0)
@@ -78,8 +91,8 @@ func (m *MockRepository) CreateHabit(ctx context.Context, habit *domain.Habit) e
 	return args.Error(0)
 }
 
-func (m *MockRepository) UpdateHabit(ctx context.Context, habit *domain.Habit) error {
-	args := m.Called(ctx, habit)
+func (m *MockRepository) UpdateHabit(ctx context.Context, habit *domain.Habit, setTags bool) error {
+	args := m.Called(ctx, habit, setTags)
 	return args.Error(0)
 }
 
@@ -88,6 +101,47 @@ func (m *MockRepository) DeleteHabit(ctx context.Context, habitID, userID uuid.U
 	return args.Error(0)
 }
 
+func (m *MockRepository) GetArchivedHabitsByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Habit, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Habit), args.Error(1)
+}
+
+func (m *MockRepository) GetDeletedHabitsByUserID(ctx context.Context, userID uuid.UUID, deletedAfter time.Time) ([]domain.Habit, error) {
+	args := m.Called(ctx, userID, deletedAfter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Habit), args.Error(1)
+}
+
+func (m *MockRepository) SetHabitArchived(ctx context.Context, habitID, userID uuid.UUID, archived bool) error {
+	args := m.Called(ctx, habitID, userID, archived)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SetHabitPinned(ctx context.Context, habitID, userID uuid.UUID, pinned bool) error {
+	args := m.Called(ctx, habitID, userID, pinned)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ReorderHabits(ctx context.Context, userID uuid.UUID, habitIDs []uuid.UUID) error {
+	args := m.Called(ctx, userID, habitIDs)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RestoreHabit(ctx context.Context, habitID, userID uuid.UUID, deletedAfter time.Time) error {
+	args := m.Called(ctx, habitID, userID, deletedAfter)
+	return args.Error(0)
+}
+
+func (m *MockRepository) PurgeDeletedHabits(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockRepository) GetHabitsByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Habit, error) {
 	args := m.Called(ctx, userID)
 	if args.Get(0) == nil {
@@ -104,22 +158,200 @@ func (m *MockRepository) GetHabitByID(ctx context.Context, habitID uuid.UUID) (*
 	return args.Get(0).(*domain.Habit), args.Error(1)
 }
 
-func (m *MockRepository) UpsertHabitLog(ctx context.Context, log *domain.HabitLog) error {
+func (m *MockRepository) UpsertHabitLog(ctx context.Context, log *domain.HabitLog, actorID uuid.UUID) error {
 	log.ID = uuid.New()
 	log.CreatedAt = time.Now()
 	log.UpdatedAt = time.Now()
-	args := m.Called(ctx, log)
+	args := m.Called(ctx, log, actorID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteHabitLog(ctx context.Context, habitID uuid.UUID, date time.Time, actorID uuid.UUID) error {
+	args := m.Called(ctx, habitID, date, actorID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ClearHabitLog(ctx context.Context, habitID uuid.UUID, date time.Time, actorID uuid.UUID) (*domain.HabitLog, error) {
+	args := m.Called(ctx, habitID, date, actorID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.HabitLog), args.Error(1)
+}
+
+func (m *MockRepository) UpsertHabitLogs(ctx context.Context, habitID uuid.UUID, logs []domain.HabitLog, cleared []time.Time, actorID uuid.UUID) ([]time.Time, []time.Time, error) {
+	args := m.Called(ctx, habitID, logs, cleared, actorID)
+	created, _ := args.Get(0).([]time.Time)
+	removed, _ := args.Get(1).([]time.Time)
+	return created, removed, args.Error(2)
+}
+
+func (m *MockRepository) InsertHabitLogs(ctx context.Context, habitID uuid.UUID, logs []domain.HabitLog, actorID uuid.UUID) ([]time.Time, error) {
+	args := m.Called(ctx, habitID, logs, actorID)
+	created, _ := args.Get(0).([]time.Time)
+	return created, args.Error(1)
+}
+
+func (m *MockRepository) EnsureCalendarToken(ctx context.Context, userID uuid.UUID, token string) (string, error) {
+	args := m.Called(ctx, userID, token)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepository) SetCalendarToken(ctx context.Context, userID uuid.UUID, token string) error {
+	args := m.Called(ctx, userID, token)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetUserByCalendarToken(ctx context.Context, token string) (*domain.User, error) {
+	args := m.Called(ctx, token)
+	user, _ := args.Get(0).(*domain.User)
+	return user, args.Error(1)
+}
+
+func (m *MockRepository) ConvertHabitType(ctx context.Context, habit *domain.Habit, doneValue float64) error {
+	args := m.Called(ctx, habit, doneValue)
 	return args.Error(0)
 }
 
-func (m *MockRepository) GetLogsForHabits(ctx context.Context, habitIDs []uuid.UUID) ([]domain.HabitLog, error) {
+func (m *MockRepository) GetHabitAdoptionCounts(ctx context.Context, habitIDs []uuid.UUID) (map[uuid.UUID]int, error) {
 	args := m.Called(ctx, habitIDs)
+	counts, _ := args.Get(0).(map[uuid.UUID]int)
+	return counts, args.Error(1)
+}
+
+func (m *MockRepository) PublishHabitTemplate(ctx context.Context, template *domain.HabitTemplate, userID, habitID uuid.UUID) error {
+	args := m.Called(ctx, template, userID, habitID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetHabitTemplates(ctx context.Context, category domain.HabitCategory, limit int) ([]domain.HabitTemplate, error) {
+	args := m.Called(ctx, category, limit)
+	templates, _ := args.Get(0).([]domain.HabitTemplate)
+	return templates, args.Error(1)
+}
+
+func (m *MockRepository) GetHabitTemplateByID(ctx context.Context, templateID uuid.UUID) (*domain.HabitTemplate, error) {
+	args := m.Called(ctx, templateID)
+	template, _ := args.Get(0).(*domain.HabitTemplate)
+	return template, args.Error(1)
+}
+
+func (m *MockRepository) DeleteHabitTemplate(ctx context.Context, templateID, userID uuid.UUID) error {
+	args := m.Called(ctx, templateID, userID)
+	return args.Error(0)
+}
+
+// IncrementHabitLog sets the log's value to the second return value, if any.
+func (m *MockRepository) IncrementHabitLog(ctx context.Context, log *domain.HabitLog, delta, maxValue float64, keepZero bool, actorID uuid.UUID) error {
+	args := m.Called(ctx, log, delta, maxValue, keepZero, actorID)
+	if value, ok := args.Get(1).(float64); ok {
+		log.Value = value
+		log.Status = domain.LogDone
+	}
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetHabitLogRevisions(ctx context.Context, habitID uuid.UUID, date time.Time) ([]domain.HabitLogRevision, error) {
+	args := m.Called(ctx, habitID, date)
+	revisions, _ := args.Get(0).([]domain.HabitLogRevision)
+	return revisions, args.Error(1)
+}
+
+func (m *MockRepository) UndoHabitLog(ctx context.Context, habitID uuid.UUID, date time.Time, actorID uuid.UUID) (*domain.HabitLogRevision, error) {
+	args := m.Called(ctx, habitID, date, actorID)
+	revision, _ := args.Get(0).(*domain.HabitLogRevision)
+	return revision, args.Error(1)
+}
+
+func (m *MockRepository) GetUserPasswordHash(ctx context.Context, userID uuid.UUID) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepository) UpdateUserPassword(ctx context.Context, userID uuid.UUID, hashedPassword string) (int, error) {
+	args := m.Called(ctx, userID, hashedPassword)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) GetAccessTokenState(ctx context.Context, userID, sessionID uuid.UUID) (int, bool, error) {
+	args := m.Called(ctx, userID, sessionID)
+	return args.Int(0), args.Bool(1), args.Error(2)
+}
+
+func (m *MockRepository) GetTokenGeneration(ctx context.Context, userID uuid.UUID) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) CreateSession(ctx context.Context, session *domain.Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetSessionByID(ctx context.Context, sessionID uuid.UUID) (*domain.Session, error) {
+	args := m.Called(ctx, sessionID)
+	session, _ := args.Get(0).(*domain.Session)
+	return session, args.Error(1)
+}
+
+func (m *MockRepository) RotateSession(ctx context.Context, session *domain.Session, oldHash []byte) error {
+	args := m.Called(ctx, session, oldHash)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetActiveSessions(ctx context.Context, userID uuid.UUID) ([]domain.Session, error) {
+	args := m.Called(ctx, userID)
+	sessions, _ := args.Get(0).([]domain.Session)
+	return sessions, args.Error(1)
+}
+
+func (m *MockRepository) RevokeSession(ctx context.Context, sessionID, userID uuid.UUID) error {
+	args := m.Called(ctx, sessionID, userID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetUsers(ctx context.Context) ([]domain.User, error) {
+	args := m.Called(ctx)
+	users, _ := args.Get(0).([]domain.User)
+	return users, args.Error(1)
+}
+
+func (m *MockRepository) AwardAchievements(ctx context.Context, userID uuid.UUID, achievements []domain.AchievementID, earnedAt time.Time) ([]domain.AchievementID, error) {
+	args := m.Called(ctx, userID, achievements, earnedAt)
+	awarded, _ := args.Get(0).([]domain.AchievementID)
+	return awarded, args.Error(1)
+}
+
+func (m *MockRepository) GetUserAchievements(ctx context.Context, userID uuid.UUID) (map[domain.AchievementID]time.Time, error) {
+	args := m.Called(ctx, userID)
+	earned, _ := args.Get(0).(map[domain.AchievementID]time.Time)
+	return earned, args.Error(1)
+}
+
+func (m *MockRepository) GetLogsForHabits(ctx context.Context, habitIDs []uuid.UUID, filter repository.LogFilter) ([]domain.HabitLog, error) {
+	args := m.Called(ctx, habitIDs, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.HabitLog), args.Error(1)
+}
+
+func (m *MockRepository) GetHabitLogsPage(ctx context.Context, habitID uuid.UUID, filter repository.LogFilter, before time.Time, limit int) ([]domain.HabitLog, error) {
+	args := m.Called(ctx, habitID, filter, before, limit)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]domain.HabitLog), args.Error(1)
 }
 
+func (m *MockRepository) GetHabitStats(ctx context.Context, habitID uuid.UUID, from, to time.Time) (*domain.HabitStats, error) {
+	args := m.Called(ctx, habitID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.HabitStats), args.Error(1)
+}
+
 func (m *MockRepository) SearchUsersByUsername(ctx context.Context, query string) ([]domain.PublicUser, error) {
 	args := m.Called(ctx, query)
 	if args.Get(0) == nil {
@@ -177,14 +409,45 @@ func (m *MockRepository) GetLeaderboard(ctx context.Context, limit int) ([]domai
 	return args.Get(0).([]domain.LeaderboardEntry), args.Error(1)
 }
 
-func (m *MockRepository) GetExplorePage(ctx context.Context, limit int) ([]domain.ExploreEntry, error) {
-	args := m.Called(ctx, limit)
+func (m *MockRepository) GetExplorePage(ctx context.Context, limit int, category domain.HabitCategory) ([]domain.ExploreEntry, error) {
+	args := m.Called(ctx, limit, category)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]domain.ExploreEntry), args.Error(1)
 }
 
+func (m *MockRepository) CreateTag(ctx context.Context, tag *domain.Tag) error {
+	args := m.Called(ctx, tag)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetTagsByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Tag, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Tag), args.Error(1)
+}
+
+func (m *MockRepository) UpdateTag(ctx context.Context, tag *domain.Tag) error {
+	args := m.Called(ctx, tag)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteTag(ctx context.Context, tagID, userID uuid.UUID) error {
+	args := m.Called(ctx, tagID, userID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetTagsForHabits(ctx context.Context, habitIDs []uuid.UUID) (map[uuid.UUID][]domain.Tag, error) {
+	args := m.Called(ctx, habitIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID][]domain.Tag), args.Error(1)
+}
+
 type MockStorage struct {
 	mock.Mock
 }
@@ -199,6 +462,19 @@ func (m *MockStorage) Delete(ctx context.Context, url string) error {
 	return args.Error(0)
 }
 
+func (m *MockStorage) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	args := m.Called(ctx, url)
+	file, _ := args.Get(0).(io.ReadCloser)
+	return file, args.Error(1)
+}
+
+// allowAchievements lets a test that writes logs ignore the achievements checked
+// afterwards. Expectations set before it take precedence.
+func allowAchievements(m *MockRepository) {
+	m.On("GetLogsForHabits", mock.Anything, mock.Anything, repository.LogFilter{}).Return([]domain.HabitLog{}, nil).Maybe()
+	m.On("AwardAchievements", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+}
+
 type mockMultipartFile struct {
 	*strings.Reader
 }
@@ -240,18 +516,28 @@ func TestLoginUser_Success(t *testing.T) {
 	password := "password123"
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	testUser := &domain.User{
-		ID:             uuid.New(),
-		Username:       "testuser",
-		HashedPassword: string(hashedPassword),
+		ID:              uuid.New(),
+		Username:        "testuser",
+		HashedPassword:  string(hashedPassword),
+		TokenGeneration: 2,
 	}
 
 	mockRepo.On("GetUserByEmailOrUsername", ctx, "testuser").Return(testUser, nil)
+	mockRepo.On("CreateSession", ctx, mock.MatchedBy(func(session *domain.Session) bool {
+		return session.UserID == testUser.ID && session.UserAgent == "Firefox" && len(session.RefreshTokenHash) > 0
+	})).Return(nil)
 
-	loggedInUser, token, err := s.LoginUser(ctx, LoginUserParams{Identifier: "testuser", Password: password}, "secret", time.Hour)
+	params := LoginUserParams{Identifier: "testuser", Password: password, Client: SessionClient{UserAgent: "Firefox", IP: "10.0.0.1"}}
+	loggedInUser, tokens, err := s.LoginUser(ctx, params, testTokenConfig)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, loggedInUser)
-	assert.NotEmpty(t, token)
+	require.NotNil(t, tokens)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	claims, err := auth.ValidateToken(tokens.AccessToken, testTokenConfig.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, 2, claims.Generation)
 	assert.Equal(t, testUser.ID, loggedInUser.ID)
 	assert.Empty(t, loggedInUser.HashedPassword)
 	mockRepo.AssertExpectations(t)
@@ -268,10 +554,231 @@ func TestLoginUser_InvalidPassword(t *testing.T) {
 
 	mockRepo.On("GetUserByEmailOrUsername", ctx, "testuser").Return(testUser, nil)
 
-	_, _, err := s.LoginUser(ctx, LoginUserParams{Identifier: "testuser", Password: "wrongpassword"}, "secret", time.Hour)
+	_, _, err := s.LoginUser(ctx, LoginUserParams{Identifier: "testuser", Password: "wrongpassword"}, testTokenConfig)
 
 	assert.Error(t, err)
 	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+	mockRepo.AssertNotCalled(t, "CreateSession", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+var testTokenConfig = TokenConfig{Secret: "secret", AccessExpiresIn: time.Hour, RefreshExpiresIn: 24 * time.Hour}
+
+// newTestSession returns an active session and the refresh token currently valid for it.
+func newTestSession(t *testing.T, now time.Time) (*domain.Session, string) {
+	t.Helper()
+	session := &domain.Session{ID: uuid.New(), UserID: uuid.New(), ExpiresAt: now.Add(time.Hour)}
+	token, hash, err := auth.NewRefreshToken(session.ID)
+	require.NoError(t, err)
+	session.RefreshTokenHash = hash
+	return session, token
+}
+
+func TestRefreshSession_RotatesToken(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	session, token := newTestSession(t, now)
+	oldHash := session.RefreshTokenHash
+
+	mockRepo.On("GetSessionByID", ctx, session.ID).Return(session, nil)
+	mockRepo.On("GetTokenGeneration", ctx, session.UserID).Return(1, nil)
+	mockRepo.On("RotateSession", ctx, mock.MatchedBy(func(rotated *domain.Session) bool {
+		return !bytes.Equal(rotated.RefreshTokenHash, oldHash) &&
+			rotated.ExpiresAt.Equal(now.Add(testTokenConfig.RefreshExpiresIn)) &&
+			rotated.IPAddress == "10.0.0.2"
+	}), oldHash).Return(nil)
+
+	tokens, err := s.RefreshSession(ctx, token, SessionClient{IP: "10.0.0.2"}, testTokenConfig)
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEqual(t, token, tokens.RefreshToken)
+	claims, err := auth.ValidateToken(tokens.AccessToken, testTokenConfig.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, session.UserID, claims.UserID)
+	assert.Equal(t, session.ID, claims.SessionID)
+	assert.Equal(t, 1, claims.Generation)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRefreshSession_ReusedTokenRevokesSession(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	session, oldToken := newTestSession(t, now)
+	// The token was rotated since, so the stored hash no longer matches it.
+	_, session.RefreshTokenHash, _ = auth.NewRefreshToken(session.ID)
+
+	mockRepo.On("GetSessionByID", ctx, session.ID).Return(session, nil)
+	mockRepo.On("RevokeSession", ctx, session.ID, session.UserID).Return(nil)
+
+	_, err := s.RefreshSession(ctx, oldToken, SessionClient{}, testTokenConfig)
+
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+	mockRepo.AssertNotCalled(t, "RotateSession", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRefreshSession_ConcurrentRotationCountsAsReuse(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	session, token := newTestSession(t, now)
+
+	mockRepo.On("GetSessionByID", ctx, session.ID).Return(session, nil)
+	mockRepo.On("GetTokenGeneration", ctx, session.UserID).Return(0, nil)
+	mockRepo.On("RotateSession", ctx, session, mock.Anything).Return(repository.ErrSessionNotFound)
+	mockRepo.On("RevokeSession", ctx, session.ID, session.UserID).Return(nil)
+
+	_, err := s.RefreshSession(ctx, token, SessionClient{}, testTokenConfig)
+
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRefreshSession_InactiveSession(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	revokedAt := now.Add(-time.Minute)
+
+	tests := []struct {
+		name   string
+		mutate func(*domain.Session)
+	}{
+		{name: "expired", mutate: func(session *domain.Session) { session.ExpiresAt = now }},
+		{name: "revoked", mutate: func(session *domain.Session) { session.RevokedAt = &revokedAt }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			s := New(mockRepo, new(MockStorage))
+			ctx := context.Background()
+			s.now = func() time.Time { return now }
+
+			session, token := newTestSession(t, now)
+			tt.mutate(session)
+			mockRepo.On("GetSessionByID", ctx, session.ID).Return(session, nil)
+
+			_, err := s.RefreshSession(ctx, token, SessionClient{}, testTokenConfig)
+
+			assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+			mockRepo.AssertNotCalled(t, "RotateSession", mock.Anything, mock.Anything, mock.Anything)
+			mockRepo.AssertNotCalled(t, "RevokeSession", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRefreshSession_MalformedToken(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+
+	_, err := s.RefreshSession(context.Background(), "not-a-token", SessionClient{}, testTokenConfig)
+
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	mockRepo.AssertNotCalled(t, "GetSessionByID", mock.Anything, mock.Anything)
+}
+
+func TestLogout_RevokesSession(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	session, token := newTestSession(t, now)
+	mockRepo.On("GetSessionByID", ctx, session.ID).Return(session, nil)
+	mockRepo.On("RevokeSession", ctx, session.ID, session.UserID).Return(nil)
+
+	err := s.Logout(ctx, token)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChangePassword_Success(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	userID := uuid.New()
+	currentHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	mockRepo.On("GetUserPasswordHash", ctx, userID).Return(string(currentHash), nil)
+	mockRepo.On("UpdateUserPassword", ctx, userID, mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("newpassword456")) == nil
+	})).Return(3, nil)
+	mockRepo.On("CreateSession", ctx, mock.MatchedBy(func(session *domain.Session) bool {
+		return session.UserID == userID
+	})).Return(nil)
+
+	params := ChangePasswordParams{CurrentPassword: "password123", NewPassword: "newpassword456"}
+	tokens, err := s.ChangePassword(ctx, userID, params, testTokenConfig)
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	claims, err := auth.ValidateToken(tokens.AccessToken, testTokenConfig.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, 3, claims.Generation, "the new token must be issued in the bumped generation")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChangePassword_IncorrectCurrentPassword(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	userID := uuid.New()
+	currentHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	mockRepo.On("GetUserPasswordHash", ctx, userID).Return(string(currentHash), nil)
+
+	params := ChangePasswordParams{CurrentPassword: "wrongpassword", NewPassword: "newpassword456"}
+	_, err := s.ChangePassword(ctx, userID, params, testTokenConfig)
+
+	assert.ErrorIs(t, err, ErrIncorrectPassword)
+	mockRepo.AssertNotCalled(t, "UpdateUserPassword", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CreateSession", mock.Anything, mock.Anything)
+}
+
+func TestCheckAccessToken(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	userID, deletedID := uuid.New(), uuid.New()
+	sessionID, revokedID := uuid.New(), uuid.New()
+	mockRepo.On("GetAccessTokenState", ctx, userID, sessionID).Return(1, true, nil)
+	mockRepo.On("GetAccessTokenState", ctx, userID, revokedID).Return(1, false, nil)
+	mockRepo.On("GetAccessTokenState", ctx, deletedID, sessionID).Return(0, false, repository.ErrUserNotFound)
+
+	assert.NoError(t, s.CheckAccessToken(ctx, userID, sessionID, 1))
+	assert.ErrorIs(t, s.CheckAccessToken(ctx, userID, sessionID, 0), ErrTokenRevoked, "a token of an earlier generation")
+	assert.ErrorIs(t, s.CheckAccessToken(ctx, userID, revokedID, 1), ErrTokenRevoked, "a token of a revoked session")
+	assert.ErrorIs(t, s.CheckAccessToken(ctx, deletedID, sessionID, 0), ErrTokenRevoked)
+}
+
+func TestGetSessions_MarksCurrent(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	userID := uuid.New()
+	current, other := uuid.New(), uuid.New()
+	mockRepo.On("GetActiveSessions", ctx, userID).Return([]domain.Session{{ID: other}, {ID: current}}, nil)
+
+	sessions, err := s.GetSessions(ctx, userID, current)
+
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.False(t, sessions[0].Current)
+	assert.True(t, sessions[1].Current)
 	mockRepo.AssertExpectations(t)
 }
 
@@ -322,19 +829,22 @@ func TestLogHabit_Integer(t *testing.T) {
 	}
 
 	mockRepo.On("GetHabitByID", ctx, habitID).Return(testHabit, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
 	mockRepo.On("UpsertHabitLog", ctx, mock.MatchedBy(func(l *domain.HabitLog) bool {
 		return l.HabitID == habitID && l.Value == 5
-	})).Return(nil)
+	}), userID).Return(nil)
+	allowAchievements(mockRepo)
 
 	log, err := s.LogHabit(ctx, params, userID)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, log)
-	assert.Equal(t, 5, log.Value)
+	assert.Equal(t, 5.0, log.Value)
+	assert.True(t, log.Completed)
 	mockRepo.AssertExpectations(t)
 }
 
-func TestLogHabit_Boolean(t *testing.T) {
+func TestLogHabit_PartialTarget(t *testing.T) {
 	mockRepo := new(MockRepository)
 	mockStorage := new(MockStorage)
 	s := New(mockRepo, mockStorage)
@@ -342,57 +852,59 @@ func TestLogHabit_Boolean(t *testing.T) {
 
 	userID := uuid.New()
 	habitID := uuid.New()
-	logDate := time.Now().Truncate(24 * time.Hour)
-
-	testHabit := &domain.Habit{ID: habitID, UserID: userID, IsBoolean: true}
+	target := 100.0
+	testHabit := &domain.Habit{ID: habitID, UserID: userID, Target: domain.Target{Value: &target, Mode: domain.TargetAtLeast}}
 	params := LogHabitParams{
 		HabitID: habitID,
-		Date:    logDate,
-		Value:   1,
+		Date:    time.Now().Truncate(24 * time.Hour),
+		Value:   40,
 	}
 
 	mockRepo.On("GetHabitByID", ctx, habitID).Return(testHabit, nil)
-	mockRepo.On("UpsertHabitLog", ctx, mock.MatchedBy(func(l *domain.HabitLog) bool {
-		return l.HabitID == habitID && l.Value == 1
-	})).Return(nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("UpsertHabitLog", ctx, mock.AnythingOfType("*domain.HabitLog"), userID).Return(nil)
+	allowAchievements(mockRepo)
 
 	log, err := s.LogHabit(ctx, params, userID)
 
 	assert.NoError(t, err)
-	assert.NotNil(t, log)
-	assert.Equal(t, 1, log.Value)
+	assert.False(t, log.Completed)
+	assert.InDelta(t, 0.4, log.Completion, 1e-9)
 	mockRepo.AssertExpectations(t)
 }
 
-func TestLogHabit_AccessDenied(t *testing.T) {
+func TestLogHabit_DecimalValue(t *testing.T) {
 	mockRepo := new(MockRepository)
 	mockStorage := new(MockStorage)
 	s := New(mockRepo, mockStorage)
 	ctx := context.Background()
 
-	ownerID := uuid.New()
-	attackerID := uuid.New()
+	userID := uuid.New()
 	habitID := uuid.New()
-	logDate := time.Now().Truncate(24 * time.Hour)
-
-	testHabit := &domain.Habit{ID: habitID, UserID: ownerID, IsBoolean: true}
+	target, unit := 5.0, "km"
+	testHabit := &domain.Habit{ID: habitID, UserID: userID, Unit: &unit, Target: domain.Target{Value: &target, Mode: domain.TargetAtLeast}}
 	params := LogHabitParams{
 		HabitID: habitID,
-		Date:    logDate,
-		Value:   1,
+		Date:    time.Now().Truncate(24 * time.Hour),
+		Value:   2.50049,
 	}
 
 	mockRepo.On("GetHabitByID", ctx, habitID).Return(testHabit, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("UpsertHabitLog", ctx, mock.MatchedBy(func(l *domain.HabitLog) bool {
+		return l.Value == 2.5
+	}), userID).Return(nil)
+	allowAchievements(mockRepo)
 
-	_, err := s.LogHabit(ctx, params, attackerID)
+	log, err := s.LogHabit(ctx, params, userID)
 
-	assert.Error(t, err)
-	assert.True(t, errors.Is(err, ErrUserAccessDenied))
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, log.Value)
+	assert.InDelta(t, 0.5, log.Completion, 1e-9)
 	mockRepo.AssertExpectations(t)
-	mockRepo.AssertNotCalled(t, "UpsertHabitLog", ctx, mock.Anything)
 }
 
-func TestUpdateHabit_Success(t *testing.T) {
+func TestLogHabit_Boolean(t *testing.T) {
 	mockRepo := new(MockRepository)
 	mockStorage := new(MockStorage)
 	s := New(mockRepo, mockStorage)
@@ -400,13 +912,73 @@ func TestUpdateHabit_Success(t *testing.T) {
 
 	userID := uuid.New()
 	habitID := uuid.New()
-	testHabit := &domain.Habit{ID: habitID, UserID: userID, Name: "Old Name", ColorHue: 100, IsBoolean: true}
-	params := UpdateHabitParams{Name: "New Name", ColorHue: 200}
+	logDate := time.Now().Truncate(24 * time.Hour)
+
+	testHabit := &domain.Habit{ID: habitID, UserID: userID, IsBoolean: true}
+	params := LogHabitParams{
+		HabitID: habitID,
+		Date:    logDate,
+		Value:   1,
+	}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(testHabit, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("UpsertHabitLog", ctx, mock.MatchedBy(func(l *domain.HabitLog) bool {
+		return l.HabitID == habitID && l.Value == 1
+	}), userID).Return(nil)
+	allowAchievements(mockRepo)
+
+	log, err := s.LogHabit(ctx, params, userID)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, log)
+	assert.Equal(t, 1.0, log.Value)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogHabit_AccessDenied(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	ownerID := uuid.New()
+	attackerID := uuid.New()
+	habitID := uuid.New()
+	logDate := time.Now().Truncate(24 * time.Hour)
+
+	testHabit := &domain.Habit{ID: habitID, UserID: ownerID, IsBoolean: true}
+	params := LogHabitParams{
+		HabitID: habitID,
+		Date:    logDate,
+		Value:   1,
+	}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(testHabit, nil)
+
+	_, err := s.LogHabit(ctx, params, attackerID)
+
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrUserAccessDenied))
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpsertHabitLog", ctx, mock.Anything, mock.Anything)
+}
+
+func TestUpdateHabit_Success(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	testHabit := &domain.Habit{ID: habitID, UserID: userID, Name: "Old Name", ColorHue: 100, IsBoolean: true}
+	params := UpdateHabitParams{Name: "New Name", ColorHue: 200}
 
 	mockRepo.On("GetHabitByID", ctx, habitID).Return(testHabit, nil)
 	mockRepo.On("UpdateHabit", ctx, mock.MatchedBy(func(h *domain.Habit) bool {
 		return h.ID == habitID && h.Name == "New Name" && h.ColorHue == 200
-	})).Return(nil)
+	}), false).Return(nil)
 
 	updatedHabit, err := s.UpdateHabit(ctx, params, habitID, userID)
 
@@ -436,7 +1008,32 @@ func TestUpdateHabit_AccessDenied(t *testing.T) {
 	assert.Error(t, err)
 	assert.True(t, errors.Is(err, ErrUserAccessDenied))
 	mockRepo.AssertExpectations(t)
-	mockRepo.AssertNotCalled(t, "UpdateHabit", ctx, mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateHabit", ctx, mock.Anything, mock.Anything)
+}
+
+func TestUpdateHabit_ReplacesTagsWithHabit(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	tag := domain.Tag{ID: uuid.New(), UserID: userID, Name: "Morning"}
+	testHabit := &domain.Habit{ID: habitID, UserID: userID, Name: "Run", IsBoolean: true}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(testHabit, nil)
+	mockRepo.On("GetTagsByUserID", ctx, userID).Return([]domain.Tag{tag}, nil)
+	// The tags are written in the same repository call, and so the same transaction.
+	mockRepo.On("UpdateHabit", ctx, mock.MatchedBy(func(h *domain.Habit) bool {
+		return slices.Equal(h.Tags, []domain.Tag{tag})
+	}), true).Return(nil)
+
+	tagIDs := []uuid.UUID{tag.ID}
+	habit, err := s.UpdateHabit(ctx, UpdateHabitParams{Name: "Run", TagIDs: &tagIDs}, habitID, userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Tag{tag}, habit.Tags)
+	mockRepo.AssertExpectations(t)
 }
 
 func TestGetProfileData_Visitor(t *testing.T) {
@@ -455,8 +1052,9 @@ func TestGetProfileData_Visitor(t *testing.T) {
 	mockRepo.On("GetFollowerCount", ctx, profileUserID).Return(10, nil)
 	mockRepo.On("GetFollowingCount", ctx, profileUserID).Return(5, nil)
 	mockRepo.On("IsFollowing", ctx, visitorID, profileUserID).Return(true, nil)
+	mockRepo.On("GetUserAchievements", ctx, profileUserID).Return(map[domain.AchievementID]time.Time{}, nil)
 
-	profileData, err := s.GetProfileData(ctx, "testuser", visitorID)
+	profileData, err := s.GetProfileData(ctx, "testuser", visitorID, HabitFilter{})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, profileData)
@@ -466,6 +1064,37 @@ func TestGetProfileData_Visitor(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestGetProfileData_OwnerSeesAdoptions(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	today := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return today }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	runID, readID := uuid.New(), uuid.New()
+	habits := []domain.Habit{{ID: runID, UserID: userID, Name: "Run"}, {ID: readID, UserID: userID, Name: "Read"}}
+
+	mockRepo.On("GetUserByUsername", ctx, "testuser").Return(&domain.User{ID: userID, Username: "testuser", Timezone: "UTC"}, nil)
+	mockRepo.On("GetHabitsByUserID", ctx, userID).Return(habits, nil)
+	mockRepo.On("GetTagsForHabits", ctx, []uuid.UUID{runID, readID}).Return(map[uuid.UUID][]domain.Tag{}, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{runID, readID}, windowFilter(today)).Return([]domain.HabitLog{}, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{runID, readID}, historyFilter(today)).Return([]domain.HabitLog{}, nil)
+	mockRepo.On("GetFollowerCount", ctx, userID).Return(0, nil)
+	mockRepo.On("GetFollowingCount", ctx, userID).Return(0, nil)
+	mockRepo.On("GetHabitAdoptionCounts", ctx, []uuid.UUID{runID, readID}).Return(map[uuid.UUID]int{runID: 3}, nil)
+	mockRepo.On("GetUserAchievements", ctx, userID).Return(map[domain.AchievementID]time.Time{}, nil)
+
+	profileData, err := s.GetProfileData(ctx, "testuser", userID, HabitFilter{})
+
+	assert.NoError(t, err)
+	assert.True(t, profileData.IsOwner)
+	assert.Equal(t, 3, profileData.Habits[0].Adoptions)
+	assert.Equal(t, 0, profileData.Habits[1].Adoptions)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestFollowUserByUsername_Success(t *testing.T) {
 	mockRepo := new(MockRepository)
 	mockStorage := new(MockStorage)
@@ -477,6 +1106,7 @@ func TestFollowUserByUsername_Success(t *testing.T) {
 
 	mockRepo.On("GetUserByUsername", ctx, "followedUser").Return(userToFollow, nil)
 	mockRepo.On("FollowUser", ctx, followerID, userToFollow.ID).Return(nil)
+	mockRepo.On("GetFollowerCount", ctx, userToFollow.ID).Return(3, nil)
 
 	err := s.FollowUserByUsername(ctx, followerID, "followedUser")
 
@@ -573,6 +1203,37 @@ func TestGetLeaderboard_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestGetLeaderboard_StreaksFromFullHistory(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	habitID := uuid.New()
+	recent := []domain.HabitLog{
+		{HabitID: habitID, LogDate: day("2024-03-09"), Value: 1, Status: domain.LogDone},
+		{HabitID: habitID, LogDate: day("2024-03-10"), Value: 1, Status: domain.LogDone},
+	}
+	habit := domain.HabitWithLogs{Habit: domain.Habit{ID: habitID, IsBoolean: true}, Logs: recent}
+	history := append([]domain.HabitLog{
+		{HabitID: habitID, LogDate: day("2022-03-07"), Value: 1, Status: domain.LogDone},
+		{HabitID: habitID, LogDate: day("2022-03-08"), Value: 1, Status: domain.LogDone},
+		{HabitID: habitID, LogDate: day("2022-03-09"), Value: 1, Status: domain.LogDone},
+	}, recent...)
+	mockRepo.On("GetLeaderboard", ctx, 50).Return([]domain.LeaderboardEntry{
+		{User: domain.PublicUser{Username: "user1"}, Timezone: "UTC", Habits: []domain.HabitWithLogs{habit}},
+	}, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID}, repository.LogFilter{}).Return(history, nil)
+
+	leaderboard, err := s.GetLeaderboard(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, recent, leaderboard[0].Habits[0].Logs)
+	assert.Equal(t, 2, leaderboard[0].Habits[0].Streak.Current)
+	assert.Equal(t, 3, leaderboard[0].Habits[0].Streak.Longest, "runs before the embedded logs count too")
+	mockRepo.AssertExpectations(t)
+}
+
 func TestDeleteHabit_Success(t *testing.T) {
 	mockRepo := new(MockRepository)
 	mockStorage := new(MockStorage)
@@ -705,3 +1366,1784 @@ func TestDeleteUser_UserNotFound(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 	mockStorage.AssertNotCalled(t, "Delete", ctx, mock.Anything)
 }
+
+func TestGetAllHabitsWithLogs_ComputesStreak(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	habits := []domain.Habit{{ID: habitID, UserID: userID, Name: "Read", IsBoolean: true}}
+	logs := []domain.HabitLog{
+		{HabitID: habitID, LogDate: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC), Value: 1},
+		{HabitID: habitID, LogDate: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), Value: 1},
+	}
+
+	mockRepo.On("GetHabitsByUserID", ctx, userID).Return(habits, nil)
+	mockRepo.On("GetTagsForHabits", ctx, []uuid.UUID{habitID}).Return(map[uuid.UUID][]domain.Tag{}, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID}, windowFilter(day("2024-03-10"))).Return(logs, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID}, historyFilter(day("2024-03-10"))).Return(logs, nil)
+
+	result, err := s.GetAllHabitsWithLogs(ctx, &domain.User{ID: userID, Timezone: "UTC"}, HabitFilter{})
+
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Len(t, result[0].Logs, 2)
+	assert.Equal(t, 2, result[0].Streak.Current)
+	assert.Equal(t, 2, result[0].Streak.Longest)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAllHabitsWithLogs_PastWindowKeepsCurrentStreak(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	habits := []domain.Habit{{ID: habitID, UserID: userID, Name: "Read", IsBoolean: true}}
+	past := []domain.HabitLog{
+		{HabitID: habitID, LogDate: day("2022-06-01"), Value: 1},
+		{HabitID: habitID, LogDate: day("2022-06-02"), Value: 1},
+		{HabitID: habitID, LogDate: day("2022-06-03"), Value: 1},
+	}
+	recent := []domain.HabitLog{{HabitID: habitID, LogDate: day("2024-03-10"), Value: 1}}
+	window := LogWindow{From: day("2022-06-01"), To: day("2022-06-30")}
+
+	mockRepo.On("GetHabitsByUserID", ctx, userID).Return(habits, nil)
+	mockRepo.On("GetTagsForHabits", ctx, []uuid.UUID{habitID}).Return(map[uuid.UUID][]domain.Tag{}, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID}, repository.LogFilter{From: window.From, To: window.To}).Return(past, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID}, historyFilter(day("2024-03-10"))).Return(append(past, recent...), nil)
+
+	result, err := s.GetAllHabitsWithLogs(ctx, &domain.User{ID: userID, Timezone: "UTC"}, HabitFilter{Window: window})
+
+	assert.NoError(t, err)
+	assert.Len(t, result[0].Logs, 3)
+	assert.Equal(t, 1, result[0].Streak.Current, "streaks are measured over the full history, not the log window")
+	assert.Equal(t, 3, result[0].Streak.Longest)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAllHabitsWithLogs_StreakOlderThanWindow(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	habits := []domain.Habit{{ID: habitID, UserID: userID, Name: "Read", IsBoolean: true}}
+	var history []domain.HabitLog
+	for d := day("2022-03-11"); !d.After(day("2024-03-10")); d = d.AddDate(0, 0, 1) {
+		history = append(history, domain.HabitLog{HabitID: habitID, LogDate: d, Value: 1})
+	}
+	today := day("2024-03-10")
+
+	mockRepo.On("GetHabitsByUserID", ctx, userID).Return(habits, nil)
+	mockRepo.On("GetTagsForHabits", ctx, []uuid.UUID{habitID}).Return(map[uuid.UUID][]domain.Tag{}, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID}, windowFilter(today)).Return(history[len(history)-DefaultLogWindowDays:], nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID}, historyFilter(today)).Return(history, nil)
+
+	result, err := s.GetAllHabitsWithLogs(ctx, &domain.User{ID: userID, Timezone: "UTC"}, HabitFilter{})
+
+	assert.NoError(t, err)
+	assert.Len(t, result[0].Logs, DefaultLogWindowDays)
+	assert.Equal(t, len(history), result[0].Streak.Current, "a run is not capped at the log window")
+	assert.Equal(t, len(history), result[0].Streak.Longest)
+	mockRepo.AssertExpectations(t)
+}
+
+// windowFilter is the log filter of the default log window ending today.
+func windowFilter(today time.Time) repository.LogFilter {
+	return repository.LogFilter{From: today.AddDate(0, 0, 1-DefaultLogWindowDays), To: today}
+}
+
+// historyFilter is the log filter of the full history streaks are computed from.
+func historyFilter(today time.Time) repository.LogFilter {
+	return repository.LogFilter{To: today}
+}
+
+func TestGetAllHabitsWithLogs_InvalidWindow(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	filter := HabitFilter{Window: LogWindow{
+		From: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}}
+	_, err := s.GetAllHabitsWithLogs(ctx, &domain.User{ID: uuid.New(), Timezone: "UTC"}, filter)
+
+	assert.ErrorIs(t, err, ErrInvalidDateRange)
+	mockRepo.AssertNotCalled(t, "GetHabitsByUserID", ctx, mock.Anything)
+}
+
+func TestGetHabitLogs_Pagination(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	habitID := uuid.New()
+	habit := &domain.Habit{ID: habitID, IsBoolean: true}
+	cursor := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	logs := []domain.HabitLog{
+		{HabitID: habitID, LogDate: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC), Value: 1},
+		{HabitID: habitID, LogDate: time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC), Value: 1},
+		{HabitID: habitID, LogDate: time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC), Value: 1},
+	}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(habit, nil)
+	mockRepo.On("GetHabitLogsPage", ctx, habitID, repository.LogFilter{}, cursor, 3).Return(logs, nil)
+
+	page, err := s.GetHabitLogs(ctx, HabitLogsParams{HabitID: habitID, Cursor: "2024-03-10", Limit: 2}, uuid.Nil)
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Logs, 2)
+	assert.True(t, page.Logs[0].Completed)
+	if assert.NotNil(t, page.NextCursor) {
+		assert.Equal(t, "2024-03-08", *page.NextCursor)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetHabitLogs_LastPage(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	habitID := uuid.New()
+	logs := []domain.HabitLog{{HabitID: habitID, LogDate: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC), Value: 1}}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, IsBoolean: true}, nil)
+	mockRepo.On("GetHabitLogsPage", ctx, habitID, repository.LogFilter{}, time.Time{}, DefaultLogPageSize+1).Return(logs, nil)
+
+	page, err := s.GetHabitLogs(ctx, HabitLogsParams{HabitID: habitID}, uuid.Nil)
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Logs, 1)
+	assert.Nil(t, page.NextCursor)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetHabitLogs_InvalidCursor(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+
+	_, err := s.GetHabitLogs(context.Background(), HabitLogsParams{HabitID: uuid.New(), Cursor: "yesterday"}, uuid.Nil)
+
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestCreateHabit_WeekdaySchedule(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	params := CreateHabitParams{
+		Name:      "No meetings",
+		IsBoolean: true,
+		Schedule:  domain.Schedule{Frequency: domain.FrequencyWeekdays, Weekdays: []int{5, 1, 3, 1}},
+	}
+
+	mockRepo.On("CreateHabit", ctx, mock.MatchedBy(func(h *domain.Habit) bool {
+		return h.Frequency == domain.FrequencyWeekdays && assert.ObjectsAreEqual([]int{1, 3, 5}, h.Weekdays)
+	})).Return(nil)
+
+	habit, err := s.CreateHabit(ctx, params, userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 3, 5}, habit.Weekdays)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateHabit_InvalidSchedule(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	params := CreateHabitParams{
+		Name:     "Gym",
+		Schedule: domain.Schedule{Frequency: domain.FrequencyWeekly, TimesPerPeriod: 0},
+	}
+
+	_, err := s.CreateHabit(ctx, params, uuid.New())
+
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidSchedule))
+	mockRepo.AssertNotCalled(t, "CreateHabit", ctx, mock.Anything)
+}
+
+func TestCreateHabit_NegativePolarity(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	mockRepo.On("CreateHabit", ctx, mock.MatchedBy(func(h *domain.Habit) bool {
+		return h.Polarity == domain.PolarityNegative
+	})).Return(nil)
+
+	habit, err := s.CreateHabit(ctx, CreateHabitParams{Name: "Smoking", IsBoolean: true, Polarity: "negative"}, uuid.New())
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.PolarityNegative, habit.Polarity)
+
+	target := 5.0
+	for name, params := range map[string]CreateHabitParams{
+		"with a target":   {Name: "Cigarettes", Polarity: "negative", Target: domain.Target{Value: &target}},
+		"weekly schedule": {Name: "Takeaway", Polarity: "negative", Schedule: domain.Schedule{Frequency: domain.FrequencyWeekly, TimesPerPeriod: 1}},
+		"unknown":         {Name: "Gym", Polarity: "neutral"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.CreateHabit(ctx, params, uuid.New())
+			assert.ErrorIs(t, err, ErrInvalidPolarity)
+		})
+	}
+	mockRepo.AssertNumberOfCalls(t, "CreateHabit", 1)
+}
+
+func TestLogHabit_FutureDateRejected(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	// 23:00 UTC on March 10th is already March 11th in Tokyo, but not in New York.
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	testHabit := &domain.Habit{ID: habitID, UserID: userID, IsBoolean: true}
+	params := LogHabitParams{
+		HabitID: habitID,
+		Date:    time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC),
+		Value:   1,
+	}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(testHabit, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "America/New_York"}, nil).Once()
+	allowAchievements(mockRepo)
+
+	_, err := s.LogHabit(ctx, params, userID)
+
+	assert.ErrorIs(t, err, ErrFutureLogDate)
+	mockRepo.AssertNotCalled(t, "UpsertHabitLog", ctx, mock.Anything, mock.Anything)
+
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "Asia/Tokyo"}, nil).Once()
+	mockRepo.On("UpsertHabitLog", ctx, mock.AnythingOfType("*domain.HabitLog"), userID).Return(nil)
+
+	_, err = s.LogHabit(ctx, params, userID)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateUser_InvalidTimezone(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	params := CreateUserParams{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+		Timezone: "Mars/Olympus_Mons",
+	}
+
+	_, err := s.CreateUser(ctx, params)
+
+	assert.ErrorIs(t, err, ErrInvalidTimezone)
+	mockRepo.AssertNotCalled(t, "CreateUser", ctx, mock.Anything)
+}
+
+func TestGetHabitStats_WeekdaySchedule(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	s.now = func() time.Time { return time.Date(2024, 3, 17, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	ownerID := uuid.New()
+	habitID := uuid.New()
+	// Created on Monday 2024-03-04; due on Mondays, Wednesdays and Fridays.
+	habit := &domain.Habit{
+		ID:        habitID,
+		UserID:    ownerID,
+		IsBoolean: true,
+		Schedule:  domain.Schedule{Frequency: domain.FrequencyWeekdays, Weekdays: []int{1, 3, 5}},
+		CreatedAt: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC),
+	}
+	from := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)
+
+	repoStats := &domain.HabitStats{
+		HabitID:       habitID,
+		LoggedDays:    5,
+		CompletedDays: 5,
+		Values:        &domain.ValueStats{Total: 5, Mean: 1, Median: 1, Max: 1},
+		ByWeekday: []domain.WeekdayStats{
+			{Weekday: 0}, {Weekday: 1, CompletedDays: 2}, {Weekday: 2}, {Weekday: 3, CompletedDays: 1},
+			{Weekday: 4}, {Weekday: 5, CompletedDays: 1}, {Weekday: 6, CompletedDays: 1},
+		},
+		ByWeek: []domain.PeriodStats{
+			{Start: from, CompletedDays: 3},
+			{Start: from.AddDate(0, 0, 7), CompletedDays: 2},
+		},
+	}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(habit, nil)
+	mockRepo.On("GetUserByID", ctx, ownerID).Return(&domain.User{ID: ownerID, Timezone: "UTC"}, nil)
+	mockRepo.On("GetHabitStats", ctx, habitID, from, to).Return(repoStats, nil)
+
+	stats, err := s.GetHabitStats(ctx, HabitStatsParams{HabitID: habitID}, uuid.Nil)
+
+	assert.NoError(t, err)
+	assert.Nil(t, stats.Values, "boolean habits have no value stats")
+	// Six due days since creation, four done on a due day; Saturday does not count.
+	assert.InDelta(t, 4.0/6.0, stats.CompletionRate, 1e-9)
+	assert.Equal(t, from, stats.BestWeek.Start)
+	assert.Equal(t, from.AddDate(0, 0, 7), stats.WorstWeek.Start)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetHabitStats_NegativeHabit(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	s.now = func() time.Time { return time.Date(2024, 3, 17, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	ownerID := uuid.New()
+	habitID := uuid.New()
+	habit := &domain.Habit{
+		ID:        habitID,
+		UserID:    ownerID,
+		IsBoolean: true,
+		Polarity:  domain.PolarityNegative,
+		Schedule:  domain.Schedule{Frequency: domain.FrequencyDaily},
+		CreatedAt: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC),
+	}
+	from := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)
+
+	// Relapses on Wednesday 6th and Tuesday 12th, Saturday 9th skipped.
+	repoStats := &domain.HabitStats{
+		HabitID:       habitID,
+		LoggedDays:    2,
+		CompletedDays: 2,
+		SkippedDays:   1,
+		ByWeekday: []domain.WeekdayStats{
+			{Weekday: 0}, {Weekday: 1}, {Weekday: 2, CompletedDays: 1}, {Weekday: 3, CompletedDays: 1},
+			{Weekday: 4}, {Weekday: 5}, {Weekday: 6, SkippedDays: 1},
+		},
+		ByWeek: []domain.PeriodStats{
+			{Start: from, CompletedDays: 1, SkippedDays: 1},
+			{Start: from.AddDate(0, 0, 7), CompletedDays: 1},
+		},
+		ByMonth: []domain.PeriodStats{
+			{Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), CompletedDays: 2, SkippedDays: 1},
+		},
+	}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(habit, nil)
+	mockRepo.On("GetUserByID", ctx, ownerID).Return(&domain.User{ID: ownerID, Timezone: "UTC"}, nil)
+	mockRepo.On("GetHabitStats", ctx, habitID, from, to).Return(repoStats, nil)
+
+	stats, err := s.GetHabitStats(ctx, HabitStatsParams{HabitID: habitID}, uuid.Nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.RelapseDays)
+	assert.Equal(t, 11, stats.CompletedDays)
+	assert.Equal(t, 1, stats.ByWeekday[2].CompletedDays)
+	assert.Equal(t, 2, stats.ByWeekday[1].CompletedDays)
+	assert.Equal(t, 5, stats.ByWeek[0].CompletedDays)
+	assert.Equal(t, 11, stats.ByMonth[0].CompletedDays)
+	// Fourteen days since creation, one skipped, eleven clean.
+	assert.InDelta(t, 11.0/13.0, stats.CompletionRate, 1e-9)
+	assert.Equal(t, from.AddDate(0, 0, 7), stats.BestWeek.Start)
+	assert.Equal(t, from, stats.WorstWeek.Start)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetHabitStats_InvalidRange(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	ownerID := uuid.New()
+	habitID := uuid.New()
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: ownerID}, nil)
+	mockRepo.On("GetUserByID", ctx, ownerID).Return(&domain.User{ID: ownerID}, nil)
+
+	params := HabitStatsParams{
+		HabitID: habitID,
+		From:    time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	_, err := s.GetHabitStats(ctx, params, uuid.Nil)
+
+	assert.ErrorIs(t, err, ErrInvalidDateRange)
+	mockRepo.AssertNotCalled(t, "GetHabitStats", ctx, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestArchiveHabit_AccessDenied(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	attackerID := uuid.New()
+	habitID := uuid.New()
+
+	mockRepo.On("SetHabitArchived", ctx, habitID, attackerID, true).Return(repository.ErrHabitNotFound)
+
+	err := s.ArchiveHabit(ctx, habitID, attackerID)
+
+	assert.ErrorIs(t, err, ErrUserAccessDenied)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogHabit_ArchivedRejected(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	archivedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: userID, ArchivedAt: &archivedAt}, nil)
+
+	_, err := s.LogHabit(ctx, LogHabitParams{HabitID: habitID, Date: archivedAt, Value: 1}, userID)
+
+	assert.ErrorIs(t, err, ErrHabitArchived)
+	mockRepo.AssertNotCalled(t, "UpsertHabitLog", ctx, mock.Anything, mock.Anything)
+}
+
+func TestGetHabitStats_ArchivedHiddenFromOthers(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	habitID := uuid.New()
+	archivedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: uuid.New(), ArchivedAt: &archivedAt}, nil)
+
+	_, err := s.GetHabitStats(ctx, HabitStatsParams{HabitID: habitID}, uuid.New())
+
+	assert.ErrorIs(t, err, repository.ErrHabitNotFound)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreHabit_WithinRetention(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	retention := 30 * 24 * time.Hour
+
+	mockRepo.On("RestoreHabit", ctx, habitID, userID, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)).Return(nil)
+
+	err := s.RestoreHabit(ctx, habitID, userID, retention)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgeDeletedHabits(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	mockRepo.On("PurgeDeletedHabits", ctx, now.Add(-time.Hour)).Return(int64(2), nil)
+
+	purged, err := s.PurgeDeletedHabits(ctx, time.Hour)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReorderHabits_Success(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	order := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	mockRepo.On("ReorderHabits", ctx, userID, order).Return(nil)
+
+	err := s.ReorderHabits(ctx, userID, order)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReorderHabits_UnownedHabit(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	order := []uuid.UUID{uuid.New(), uuid.New()}
+
+	mockRepo.On("ReorderHabits", ctx, userID, order).Return(repository.ErrHabitNotFound)
+
+	err := s.ReorderHabits(ctx, userID, order)
+
+	assert.ErrorIs(t, err, ErrUserAccessDenied)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReorderHabits_Duplicates(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	habitID := uuid.New()
+
+	err := s.ReorderHabits(ctx, uuid.New(), []uuid.UUID{habitID, uuid.New(), habitID})
+
+	assert.ErrorIs(t, err, ErrInvalidHabitOrder)
+	mockRepo.AssertNotCalled(t, "ReorderHabits", ctx, mock.Anything, mock.Anything)
+}
+
+func TestGetAllHabitsWithLogs_TagFilter(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	runID, readID := uuid.New(), uuid.New()
+	habits := []domain.Habit{
+		{ID: runID, UserID: userID, Name: "Run", IsBoolean: true},
+		{ID: readID, UserID: userID, Name: "Read", IsBoolean: true},
+	}
+	fitness := domain.Tag{ID: uuid.New(), UserID: userID, Name: "Fitness"}
+	today := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return today }
+
+	mockRepo.On("GetHabitsByUserID", ctx, userID).Return(habits, nil)
+	mockRepo.On("GetTagsForHabits", ctx, []uuid.UUID{runID, readID}).Return(map[uuid.UUID][]domain.Tag{runID: {fitness}}, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{runID}, windowFilter(today)).Return([]domain.HabitLog{}, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{runID}, historyFilter(today)).Return([]domain.HabitLog{}, nil)
+
+	result, err := s.GetAllHabitsWithLogs(ctx, &domain.User{ID: userID, Timezone: "UTC"}, HabitFilter{Tag: "fitness"})
+
+	assert.NoError(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, runID, result[0].ID)
+		assert.Equal(t, []domain.Tag{fitness}, result[0].Tags)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateHabit_WithTagsAndCategory(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	tag := domain.Tag{ID: uuid.New(), UserID: userID, Name: "Morning"}
+
+	mockRepo.On("GetTagsByUserID", ctx, userID).Return([]domain.Tag{tag}, nil)
+	// The tags are written in the same repository call, and so the same transaction.
+	mockRepo.On("CreateHabit", ctx, mock.MatchedBy(func(h *domain.Habit) bool {
+		return h.Category != nil && *h.Category == domain.CategoryFitness && slices.Equal(h.Tags, []domain.Tag{tag})
+	})).Return(nil)
+
+	params := CreateHabitParams{Name: "Run", IsBoolean: true, Category: "fitness", TagIDs: []uuid.UUID{tag.ID, tag.ID}}
+	habit, err := s.CreateHabit(ctx, params, userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Tag{tag}, habit.Tags)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateHabit_ForeignTag(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+
+	mockRepo.On("GetTagsByUserID", ctx, userID).Return([]domain.Tag{}, nil)
+
+	params := CreateHabitParams{Name: "Run", IsBoolean: true, TagIDs: []uuid.UUID{uuid.New()}}
+	_, err := s.CreateHabit(ctx, params, userID)
+
+	assert.ErrorIs(t, err, repository.ErrTagNotFound)
+	mockRepo.AssertNotCalled(t, "CreateHabit", ctx, mock.Anything)
+}
+
+func TestGetExplorePage_InvalidCategory(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+
+	_, err := s.GetExplorePage(context.Background(), "gardening")
+
+	assert.ErrorIs(t, err, ErrInvalidCategory)
+}
+
+func TestLogHabit_NoteIsPrivateByDefault(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	note := "  ran in the rain "
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: userID, IsBoolean: true}, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("UpsertHabitLog", ctx, mock.MatchedBy(func(l *domain.HabitLog) bool {
+		return l.Note != nil && *l.Note == "ran in the rain" && l.NotePrivate
+	}), userID).Return(nil)
+	allowAchievements(mockRepo)
+
+	params := LogHabitParams{HabitID: habitID, Date: day("2024-03-10"), Value: 1, Note: &note}
+	_, err := s.LogHabit(ctx, params, userID)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogHabit_ZeroValueDeletesLog(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: userID, IsBoolean: true}, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("ClearHabitLog", ctx, habitID, day("2024-03-10"), userID).Return(nil, nil)
+
+	log, err := s.LogHabit(ctx, LogHabitParams{HabitID: habitID, Date: day("2024-03-10")}, userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, log.Value)
+	mockRepo.AssertNotCalled(t, "UpsertHabitLog", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogHabit_ZeroValueKeepsNote(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	habitID := uuid.New()
+	date := day("2024-03-10")
+
+	setup := func() (*Service, *MockRepository) {
+		mockRepo := new(MockRepository)
+		s := New(mockRepo, new(MockStorage))
+		s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+		mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: userID, IsBoolean: true}, nil)
+		mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+		return s, mockRepo
+	}
+
+	t.Run("new note", func(t *testing.T) {
+		s, mockRepo := setup()
+		note := "rest day"
+		mockRepo.On("UpsertHabitLog", ctx, mock.MatchedBy(func(l *domain.HabitLog) bool {
+			return l.Status == domain.LogDone && l.Value == 0 && l.Note != nil && *l.Note == "rest day"
+		}), userID).Return(nil)
+		allowAchievements(mockRepo)
+
+		log, err := s.LogHabit(ctx, LogHabitParams{HabitID: habitID, Date: date, Note: &note}, userID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "rest day", *log.Note)
+		assert.False(t, log.Completed)
+		mockRepo.AssertNotCalled(t, "ClearHabitLog", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "DeleteHabitLog", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("existing note", func(t *testing.T) {
+		s, mockRepo := setup()
+		note := "rest day"
+		kept := &domain.HabitLog{HabitID: habitID, LogDate: date, Status: domain.LogDone, Note: &note}
+		mockRepo.On("ClearHabitLog", ctx, habitID, date, userID).Return(kept, nil)
+
+		log, err := s.LogHabit(ctx, LogHabitParams{HabitID: habitID, Date: date}, userID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "rest day", *log.Note)
+		mockRepo.AssertNotCalled(t, "DeleteHabitLog", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("note removed", func(t *testing.T) {
+		s, mockRepo := setup()
+		empty := ""
+		mockRepo.On("DeleteHabitLog", ctx, habitID, date, userID).Return(nil)
+
+		log, err := s.LogHabit(ctx, LogHabitParams{HabitID: habitID, Date: date, Note: &empty}, userID)
+
+		assert.NoError(t, err)
+		assert.Nil(t, log.Note)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestLogHabit_ZeroWithinLimitIsKept(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	limit := 5.0
+	habit := &domain.Habit{ID: habitID, UserID: userID, Target: domain.Target{Value: &limit, Mode: domain.TargetAtMost}}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(habit, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("UpsertHabitLog", ctx, mock.MatchedBy(func(l *domain.HabitLog) bool {
+		return l.Status == domain.LogDone && l.Value == 0
+	}), userID).Return(nil)
+	allowAchievements(mockRepo)
+
+	log, err := s.LogHabit(ctx, LogHabitParams{HabitID: habitID, Date: day("2024-03-10")}, userID)
+
+	assert.NoError(t, err)
+	assert.True(t, log.Completed, "no cigarettes today stays within the limit")
+	mockRepo.AssertNotCalled(t, "ClearHabitLog", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogHabit_SkippedDropsValue(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: userID}, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("UpsertHabitLog", ctx, mock.MatchedBy(func(l *domain.HabitLog) bool {
+		return l.Status == domain.LogSkipped && l.Value == 0
+	}), userID).Return(nil)
+	allowAchievements(mockRepo)
+
+	params := LogHabitParams{HabitID: habitID, Date: day("2024-03-10"), Value: 4, Status: domain.LogSkipped}
+	log, err := s.LogHabit(ctx, params, userID)
+
+	assert.NoError(t, err)
+	assert.False(t, log.Completed)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogHabit_InvalidStatus(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+
+	params := LogHabitParams{HabitID: uuid.New(), Date: day("2024-03-10"), Status: "maybe"}
+	_, err := s.LogHabit(context.Background(), params, uuid.New())
+
+	assert.ErrorIs(t, err, ErrInvalidLogStatus)
+	mockRepo.AssertNotCalled(t, "GetHabitByID", mock.Anything, mock.Anything)
+}
+
+func TestDeleteHabitLog_AccessDenied(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	habitID := uuid.New()
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: uuid.New()}, nil)
+
+	err := s.DeleteHabitLog(ctx, habitID, day("2024-03-10"), uuid.New())
+
+	assert.ErrorIs(t, err, ErrUserAccessDenied)
+	mockRepo.AssertNotCalled(t, "DeleteHabitLog", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLogHabit_NoteTooLong(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	note := strings.Repeat("é", MaxNoteLength+1)
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: userID, IsBoolean: true}, nil)
+
+	params := LogHabitParams{HabitID: habitID, Date: day("2024-03-10"), Value: 1, Note: &note}
+	_, err := s.LogHabit(ctx, params, userID)
+
+	assert.ErrorIs(t, err, ErrNoteTooLong)
+	mockRepo.AssertNotCalled(t, "UpsertHabitLog", ctx, mock.Anything, mock.Anything)
+}
+
+func TestGetHabitLogs_StripsPrivateNotesForVisitors(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	ownerID := uuid.New()
+	habitID := uuid.New()
+	private, public := "felt tired", "new personal best"
+	logs := func() []domain.HabitLog {
+		return []domain.HabitLog{
+			{HabitID: habitID, LogDate: day("2024-03-09"), Value: 1, Note: &private, NotePrivate: true},
+			{HabitID: habitID, LogDate: day("2024-03-08"), Value: 1, Note: &public},
+		}
+	}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: ownerID, IsBoolean: true}, nil)
+	mockRepo.On("GetHabitLogsPage", ctx, habitID, repository.LogFilter{}, time.Time{}, DefaultLogPageSize+1).Return(logs(), nil).Once()
+	mockRepo.On("GetHabitLogsPage", ctx, habitID, repository.LogFilter{}, time.Time{}, DefaultLogPageSize+1).Return(logs(), nil).Once()
+
+	visitorPage, err := s.GetHabitLogs(ctx, HabitLogsParams{HabitID: habitID}, uuid.New())
+	assert.NoError(t, err)
+	assert.Nil(t, visitorPage.Logs[0].Note)
+	assert.Equal(t, &public, visitorPage.Logs[1].Note)
+
+	ownerPage, err := s.GetHabitLogs(ctx, HabitLogsParams{HabitID: habitID}, ownerID)
+	assert.NoError(t, err)
+	assert.Equal(t, &private, ownerPage.Logs[0].Note)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBackfillHabitLogs_Success(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: userID}, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("UpsertHabitLogs", ctx, habitID, mock.MatchedBy(func(logs []domain.HabitLog) bool {
+		return len(logs) == 2 && logs[0].Value == 3 && logs[1].Status == domain.LogSkipped && logs[1].Value == 0
+	}), []time.Time{day("2024-03-03")}, userID).Return([]time.Time{day("2024-03-01")}, []time.Time(nil), nil)
+	allowAchievements(mockRepo)
+
+	entries := []BatchLogEntry{
+		{Date: "2024-03-01", Value: 3},
+		{Date: "2024-03-02", Value: 2, Status: domain.LogSkipped},
+		{Date: "2024-03-03", Value: 0},
+	}
+	results, err := s.BackfillHabitLogs(ctx, habitID, entries, userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.LogBatchResult{
+		{Index: 0, Date: "2024-03-01", Outcome: domain.BatchCreated},
+		{Index: 1, Date: "2024-03-02", Outcome: domain.BatchUpdated},
+		{Index: 2, Date: "2024-03-03", Outcome: domain.BatchUnchanged},
+	}, results)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBackfillHabitLogs_InvalidEntriesWriteNothing(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: userID}, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+
+	entries := []BatchLogEntry{
+		{Date: "2024-03-01", Value: 1},
+		{Date: "03/02/2024", Value: 1},
+		{Date: "2024-03-01", Value: 2},
+		{Date: "2024-03-11", Value: 1},
+		{Date: "2024-03-04", Value: -1},
+		{Date: "2024-03-05", Value: 1e20},
+	}
+	results, err := s.BackfillHabitLogs(ctx, habitID, entries, userID)
+
+	assert.ErrorIs(t, err, ErrInvalidBatch)
+	outcomes := make([]domain.LogBatchOutcome, len(results))
+	for i, r := range results {
+		outcomes[i] = r.Outcome
+	}
+	assert.Equal(t, []domain.LogBatchOutcome{
+		domain.BatchUnchanged, domain.BatchInvalid, domain.BatchInvalid, domain.BatchInvalid, domain.BatchInvalid, domain.BatchInvalid,
+	}, outcomes)
+	assert.Equal(t, "value must not exceed 999999999999", results[5].Error)
+	mockRepo.AssertNotCalled(t, "UpsertHabitLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBackfillHabitLogs_TooLarge(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+
+	_, err := s.BackfillHabitLogs(context.Background(), uuid.New(), make([]BatchLogEntry, MaxBatchLogs+1), uuid.New())
+
+	assert.ErrorIs(t, err, ErrBatchTooLarge)
+	mockRepo.AssertNotCalled(t, "GetHabitByID", mock.Anything, mock.Anything)
+}
+
+func TestExportAccount_WritesArchive(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	avatarURL := "/uploads/avatar.png"
+	note := "felt great"
+	user := &domain.User{ID: userID, Username: "jane", AvatarURL: &avatarURL}
+	habit := domain.Habit{ID: habitID, UserID: userID, Name: "Run, daily", IsBoolean: true}
+	logs := []domain.HabitLog{{HabitID: habitID, LogDate: day("2024-03-01"), Value: 1, Status: domain.LogDone, Note: &note}}
+
+	mockRepo.On("GetUserByID", ctx, userID).Return(user, nil)
+	mockRepo.On("GetHabitsByUserID", ctx, userID).Return([]domain.Habit{habit}, nil)
+	mockRepo.On("GetArchivedHabitsByUserID", ctx, userID).Return([]domain.Habit{}, nil)
+	mockRepo.On("GetDeletedHabitsByUserID", ctx, userID, time.Time{}).Return([]domain.Habit{}, nil)
+	mockRepo.On("GetTagsForHabits", ctx, []uuid.UUID{habitID}).Return(map[uuid.UUID][]domain.Tag{}, nil)
+	mockRepo.On("GetFollowers", ctx, userID).Return([]domain.PublicUser{}, nil)
+	mockRepo.On("GetFollowing", ctx, userID).Return([]domain.PublicUser{}, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID}, repository.LogFilter{}).Return(logs, nil)
+	mockStorage.On("Open", ctx, avatarURL).Return(io.NopCloser(strings.NewReader("png bytes")), nil)
+
+	export, err := s.ExportAccount(ctx, userID)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, export.WriteZip(ctx, &buf))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	contents := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(data)
+	}
+
+	assert.ElementsMatch(t, []string{
+		"profile.json", "habits.json", "followers.json", "following.json", "logs.json", "logs.csv", "avatar.png",
+	}, slices.Collect(maps.Keys(contents)))
+	assert.Contains(t, contents["logs.csv"], habitID.String()+`,"Run, daily",2024-03-01,1,done,felt great,false,`)
+	assert.Contains(t, contents["logs.json"], `"note":"felt great"`)
+	assert.Equal(t, "png bytes", contents["avatar.png"])
+	mockRepo.AssertExpectations(t)
+}
+
+func TestImportHabits_MatchesExistingHabitsByName(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	readID := uuid.New()
+	file := strings.NewReader("date,habit,value\n2024-03-01,read,30\n2024-03-02,Read,20\n2024-03-11,Read,5\n")
+
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("GetHabitsByUserID", ctx, userID).Return([]domain.Habit{{ID: readID, UserID: userID, Name: "Read", ColorHue: 40}}, nil)
+	mockRepo.On("GetArchivedHabitsByUserID", ctx, userID).Return([]domain.Habit{}, nil)
+	// The log the user already has on March 1, note included, is left alone.
+	mockRepo.On("InsertHabitLogs", ctx, readID, mock.MatchedBy(func(logs []domain.HabitLog) bool {
+		return len(logs) == 2 && logs[0].Value == 30 && logs[1].Value == 20
+	}), userID).Return([]time.Time{day("2024-03-02")}, nil)
+	allowAchievements(mockRepo)
+
+	summary, err := s.ImportHabits(ctx, userID, file, file.Size(), false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "csv", summary.Format)
+	assert.Len(t, summary.Habits, 1)
+	assert.False(t, summary.Habits[0].Created)
+	assert.Equal(t, &readID, summary.Habits[0].HabitID)
+	assert.Equal(t, 2, summary.Habits[0].Logs)
+	assert.Equal(t, 1, summary.Habits[0].NewLogs)
+	mockRepo.AssertNotCalled(t, "CreateHabit", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpsertHabitLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestImportHabits_SkipsArchivedHabits(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID, readID := uuid.New(), uuid.New()
+	file := strings.NewReader("date,habit,value\n2024-03-01,Read,30\n")
+
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("GetHabitsByUserID", ctx, userID).Return([]domain.Habit{}, nil)
+	mockRepo.On("GetArchivedHabitsByUserID", ctx, userID).Return([]domain.Habit{{ID: readID, UserID: userID, Name: "Read"}}, nil)
+	allowAchievements(mockRepo)
+
+	summary, err := s.ImportHabits(ctx, userID, file, file.Size(), false)
+
+	require.NoError(t, err)
+	require.Len(t, summary.Habits, 1)
+	assert.True(t, summary.Habits[0].Skipped)
+	assert.False(t, summary.Habits[0].Created)
+	assert.Equal(t, &readID, summary.Habits[0].HabitID)
+	mockRepo.AssertNotCalled(t, "InsertHabitLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CreateHabit", mock.Anything, mock.Anything)
+}
+
+func TestImportHabits_SkipsHabitsOfTheOtherType(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	flossID, readID := uuid.New(), uuid.New()
+	file := strings.NewReader("date,habit,value\n2024-03-01,Floss,5\n2024-03-01,Read,1\n")
+
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("GetHabitsByUserID", ctx, userID).Return([]domain.Habit{
+		{ID: flossID, UserID: userID, Name: "Floss", IsBoolean: true},
+		{ID: readID, UserID: userID, Name: "Read"},
+	}, nil)
+	mockRepo.On("GetArchivedHabitsByUserID", ctx, userID).Return([]domain.Habit{}, nil)
+	// A CSV history of ones fits a numeric habit.
+	mockRepo.On("InsertHabitLogs", ctx, readID, mock.Anything, userID).Return([]time.Time{day("2024-03-01")}, nil)
+	allowAchievements(mockRepo)
+
+	summary, err := s.ImportHabits(ctx, userID, file, file.Size(), false)
+
+	require.NoError(t, err)
+	require.Len(t, summary.Habits, 2)
+	assert.True(t, summary.Habits[0].Skipped, "amounts do not fit a yes/no habit")
+	assert.Equal(t, &flossID, summary.Habits[0].HabitID)
+	assert.False(t, summary.Habits[1].Skipped)
+	mockRepo.AssertNotCalled(t, "InsertHabitLogs", mock.Anything, flossID, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestFitsHabit(t *testing.T) {
+	yesNo := importer.Habit{IsBoolean: true}
+	amounts := importer.Habit{}
+
+	assert.True(t, fitsHabit(importer.FormatLoop, yesNo, domain.Habit{IsBoolean: true}))
+	assert.False(t, fitsHabit(importer.FormatLoop, amounts, domain.Habit{IsBoolean: true}))
+	assert.False(t, fitsHabit(importer.FormatLoop, yesNo, domain.Habit{}))
+	assert.True(t, fitsHabit(importer.FormatCSV, yesNo, domain.Habit{}))
+	assert.True(t, fitsHabit(importer.FormatCSV, amounts, domain.Habit{}))
+}
+
+func TestImportHabits_DryRunWritesNothing(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID := uuid.New()
+	file := strings.NewReader("date,habit,value\n2024-03-01,Floss,1\n")
+
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("GetHabitsByUserID", ctx, userID).Return([]domain.Habit{}, nil)
+	mockRepo.On("GetArchivedHabitsByUserID", ctx, userID).Return([]domain.Habit{}, nil)
+
+	summary, err := s.ImportHabits(ctx, userID, file, file.Size(), true)
+
+	assert.NoError(t, err)
+	assert.True(t, summary.DryRun)
+	assert.True(t, summary.Habits[0].Created)
+	assert.Nil(t, summary.Habits[0].HabitID)
+	mockRepo.AssertNotCalled(t, "CreateHabit", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "InsertHabitLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCalendarFeed_CompletedLogsOnly(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	user := &domain.User{ID: uuid.New(), Username: "jane", Timezone: "UTC"}
+	runID, readID := uuid.New(), uuid.New()
+	target := 30.0
+	habits := []domain.Habit{
+		{ID: runID, UserID: user.ID, Name: "Run", IsBoolean: true},
+		{ID: readID, UserID: user.ID, Name: "Read", Target: domain.Target{Value: &target, Mode: domain.TargetAtLeast}},
+	}
+	logs := []domain.HabitLog{
+		{ID: uuid.New(), HabitID: runID, LogDate: day("2024-03-08"), Value: 1},
+		{ID: uuid.New(), HabitID: runID, LogDate: day("2024-03-09"), Status: domain.LogSkipped},
+		{ID: uuid.New(), HabitID: readID, LogDate: day("2024-03-08"), Value: 10},
+		{ID: uuid.New(), HabitID: readID, LogDate: day("2024-03-09"), Value: 45},
+	}
+
+	mockRepo.On("GetUserByCalendarToken", ctx, "secret").Return(user, nil)
+	mockRepo.On("GetHabitsByUserID", ctx, user.ID).Return(habits, nil)
+	mockRepo.On("GetTagsForHabits", ctx, []uuid.UUID{runID, readID}).Return(map[uuid.UUID][]domain.Tag{}, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{runID, readID}, windowFilter(day("2024-03-10"))).Return(logs, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{runID, readID}, historyFilter(day("2024-03-10"))).Return(logs, nil)
+
+	cal, err := s.CalendarFeed(ctx, "secret", nil)
+
+	assert.NoError(t, err)
+	assert.Len(t, cal.Events, 2)
+	assert.Equal(t, "Run", cal.Events[0].Summary)
+	assert.Equal(t, "Done", cal.Events[0].Description)
+	assert.Equal(t, "Read", cal.Events[1].Summary)
+	assert.Equal(t, "Value: 45", cal.Events[1].Description)
+
+	_, err = s.CalendarFeed(ctx, "secret", &user.ID)
+	assert.ErrorIs(t, err, repository.ErrHabitNotFound)
+}
+
+func TestCalendarFeed_UnknownToken(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	mockRepo.On("GetUserByCalendarToken", ctx, "stale").Return(nil, repository.ErrUserNotFound)
+
+	_, err := s.CalendarFeed(ctx, "stale", nil)
+
+	assert.ErrorIs(t, err, repository.ErrUserNotFound)
+	mockRepo.AssertNotCalled(t, "GetHabitsByUserID", mock.Anything, mock.Anything)
+}
+
+func TestPreviewHabitConversion_BooleanToNumeric(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	habit := &domain.Habit{ID: habitID, UserID: userID, IsBoolean: true, Target: domain.Target{Mode: domain.TargetAtLeast}}
+	logs := []domain.HabitLog{
+		{HabitID: habitID, LogDate: day("2024-03-01"), Value: 1, Status: domain.LogDone},
+		{HabitID: habitID, LogDate: day("2024-03-02"), Value: 1, Status: domain.LogDone},
+		{HabitID: habitID, LogDate: day("2024-03-03"), Status: domain.LogSkipped},
+	}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(habit, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID}, repository.LogFilter{}).Return(logs, nil)
+
+	target := 30.0
+	params := ConvertHabitParams{DoneValue: 20, Target: domain.Target{Value: &target, Mode: domain.TargetAtLeast}}
+	preview, err := s.PreviewHabitConversion(ctx, habitID, userID, params)
+
+	assert.NoError(t, err)
+	assert.False(t, preview.IsBoolean)
+	assert.Equal(t, 3, preview.Logs)
+	assert.Equal(t, 2, preview.ChangedLogs)
+	assert.Equal(t, 2, preview.CompletedBefore)
+	assert.Equal(t, 0, preview.CompletedAfter, "20 falls short of the new target of 30")
+	mockRepo.AssertNotCalled(t, "ConvertHabitType", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestConvertHabit_NumericToBoolean(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockStorage := new(MockStorage)
+	s := New(mockRepo, mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	target := 30.0
+	habit := &domain.Habit{ID: habitID, UserID: userID, Target: domain.Target{Value: &target, Mode: domain.TargetAtLeast}}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(habit, nil)
+	mockRepo.On("ConvertHabitType", ctx, mock.MatchedBy(func(h *domain.Habit) bool {
+		return h.ID == habitID && h.IsBoolean && h.Target.Value == nil
+	}), 0.0).Return(nil)
+
+	converted, err := s.ConvertHabit(ctx, habitID, userID, ConvertHabitParams{IsBoolean: true})
+
+	assert.NoError(t, err)
+	assert.True(t, converted.IsBoolean)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConvertHabit_Rejected(t *testing.T) {
+	userID := uuid.New()
+	habitID := uuid.New()
+
+	tests := []struct {
+		name   string
+		habit  *domain.Habit
+		params ConvertHabitParams
+		err    error
+	}{
+		{"same type", &domain.Habit{ID: habitID, UserID: userID, IsBoolean: true}, ConvertHabitParams{IsBoolean: true}, ErrHabitTypeUnchanged},
+		{"missing done value", &domain.Habit{ID: habitID, UserID: userID, IsBoolean: true}, ConvertHabitParams{}, ErrInvalidDoneValue},
+		{"other user's habit", &domain.Habit{ID: habitID, UserID: uuid.New(), IsBoolean: true}, ConvertHabitParams{DoneValue: 1}, ErrUserAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			s := New(mockRepo, new(MockStorage))
+			ctx := context.Background()
+			mockRepo.On("GetHabitByID", ctx, habitID).Return(tt.habit, nil)
+
+			_, err := s.ConvertHabit(ctx, habitID, userID, tt.params)
+
+			assert.ErrorIs(t, err, tt.err)
+			mockRepo.AssertNotCalled(t, "ConvertHabitType", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestSetTemplateCatalogue(t *testing.T) {
+	s := New(new(MockRepository), new(MockStorage))
+
+	catalogue, err := templates.Load("")
+	require.NoError(t, err)
+	assert.NoError(t, s.SetTemplateCatalogue(catalogue), "the built-in catalogue is valid")
+	assert.NotEmpty(t, s.templates)
+
+	target, unit := 3.0, "km"
+	for name, catalogue := range map[string][]domain.HabitTemplate{
+		"duplicate ID":      {{ID: "read", Name: "Read"}, {ID: "read", Name: "Read more"}},
+		"UUID as ID":        {{ID: uuid.NewString(), Name: "Read"}},
+		"missing name":      {{ID: "read"}},
+		"boolean with unit": {{ID: "read", Name: "Read", IsBoolean: true, Unit: &unit}},
+		"negative target":   {{ID: "quit", Name: "Quit", Polarity: domain.PolarityNegative, Target: domain.Target{Value: &target}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, s.SetTemplateCatalogue(catalogue))
+		})
+	}
+}
+
+func TestGetHabitTemplates_Category(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	fitness, learning := domain.CategoryFitness, domain.CategoryLearning
+	require.NoError(t, s.SetTemplateCatalogue([]domain.HabitTemplate{
+		{ID: "run", Name: "Run", Category: &fitness},
+		{ID: "read", Name: "Read", Category: &learning},
+		{ID: "journal", Name: "Journal"},
+	}))
+	published := []domain.HabitTemplate{{ID: uuid.NewString(), Name: "Swim", Category: &fitness}}
+	mockRepo.On("GetHabitTemplates", ctx, domain.CategoryFitness, maxPublishedTemplates).Return(published, nil)
+
+	list, err := s.GetHabitTemplates(ctx, "fitness")
+
+	assert.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "run", list[0].ID)
+	assert.Equal(t, "Swim", list[1].Name)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateHabitFromTemplate(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+	userID := uuid.New()
+
+	unit, category, target := "pages", domain.CategoryLearning, 20.0
+	require.NoError(t, s.SetTemplateCatalogue([]domain.HabitTemplate{{
+		ID:       "read",
+		Name:     "Read",
+		ColorHue: 30,
+		Schedule: domain.Schedule{Frequency: domain.FrequencyDaily},
+		Target:   domain.Target{Value: &target, Mode: domain.TargetAtLeast},
+		Unit:     &unit,
+		Category: &category,
+	}}))
+
+	mockRepo.On("CreateHabit", ctx, mock.MatchedBy(func(h *domain.Habit) bool {
+		return h.UserID == userID && h.Name == "Read" && h.ColorHue == 30 && !h.IsBoolean &&
+			*h.Target.Value == 20 && *h.Unit == "pages" && *h.Category == category
+	})).Return(nil)
+
+	habit, err := s.CreateHabitFromTemplate(ctx, "read", userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.PolarityPositive, habit.Polarity)
+	mockRepo.AssertExpectations(t)
+
+	_, err = s.CreateHabitFromTemplate(ctx, "unknown", userID)
+	assert.ErrorIs(t, err, repository.ErrHabitTemplateNotFound)
+	mockRepo.AssertNumberOfCalls(t, "CreateHabit", 1)
+}
+
+func TestPublishHabitTemplate(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	userID := uuid.New()
+	habitID := uuid.New()
+	habit := &domain.Habit{
+		ID:        habitID,
+		UserID:    userID,
+		Name:      "Stretch",
+		ColorHue:  90,
+		IsBoolean: true,
+		Polarity:  domain.PolarityPositive,
+		Schedule:  domain.Schedule{Frequency: domain.FrequencyDaily},
+		Target:    domain.Target{Mode: domain.TargetAtLeast},
+	}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(habit, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Username: "jane"}, nil)
+	mockRepo.On("PublishHabitTemplate", ctx, mock.MatchedBy(func(t *domain.HabitTemplate) bool {
+		return t.Name == "Stretch" && t.Description == "Five minutes after waking up." && t.IsBoolean
+	}), userID, habitID).Return(nil)
+
+	template, err := s.PublishHabitTemplate(ctx, habitID, userID, " Five minutes after waking up. ")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "jane", template.Author.Username)
+
+	_, err = s.PublishHabitTemplate(ctx, habitID, uuid.New(), "")
+	assert.ErrorIs(t, err, ErrUserAccessDenied)
+	mockRepo.AssertNumberOfCalls(t, "PublishHabitTemplate", 1)
+}
+
+func TestCloneHabit(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	ownerID := uuid.New()
+	userID := uuid.New()
+	habitID := uuid.New()
+	target, unit, category := 5.0, "km", domain.CategoryFitness
+	source := &domain.Habit{
+		ID:       habitID,
+		UserID:   ownerID,
+		Name:     "Run",
+		ColorHue: 15,
+		Polarity: domain.PolarityPositive,
+		Schedule: domain.Schedule{Frequency: domain.FrequencyWeekly, TimesPerPeriod: 3},
+		Target:   domain.Target{Value: &target, Mode: domain.TargetAtLeast},
+		Unit:     &unit,
+		Category: &category,
+		Tags:     []domain.Tag{{ID: uuid.New(), Name: "outdoors"}},
+	}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(source, nil)
+	mockRepo.On("CreateHabit", ctx, mock.MatchedBy(func(h *domain.Habit) bool {
+		return h.UserID == userID && h.ID != habitID && h.Name == "Run" && h.ColorHue == 15 &&
+			h.Schedule.TimesPerPeriod == 3 && *h.Target.Value == 5 && *h.Unit == "km" &&
+			*h.SourceHabitID == habitID
+	})).Return(nil)
+
+	clone, err := s.CloneHabit(ctx, habitID, userID)
+
+	assert.NoError(t, err)
+	assert.Empty(t, clone.Tags)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpsertHabitLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCloneHabit_ArchivedHiddenFromOthers(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	habitID := uuid.New()
+	archivedAt := time.Now()
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: uuid.New(), ArchivedAt: &archivedAt}, nil)
+
+	_, err := s.CloneHabit(ctx, habitID, uuid.New())
+
+	assert.ErrorIs(t, err, repository.ErrHabitNotFound)
+	mockRepo.AssertNotCalled(t, "CreateHabit", mock.Anything, mock.Anything)
+}
+
+func TestLogHabit_AwardsAchievements(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	now := time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	userID, habitID := uuid.New(), uuid.New()
+	habit := &domain.Habit{ID: habitID, UserID: userID, IsBoolean: true, Schedule: domain.Schedule{Frequency: domain.FrequencyDaily}}
+	var history []domain.HabitLog
+	for d := day("2024-03-04"); !d.After(day("2024-03-10")); d = d.AddDate(0, 0, 1) {
+		history = append(history, domain.HabitLog{HabitID: habitID, LogDate: d, Value: 1, Status: domain.LogDone})
+	}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(habit, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("UpsertHabitLog", ctx, mock.AnythingOfType("*domain.HabitLog"), userID).Return(nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID}, repository.LogFilter{}).Return(history, nil)
+	mockRepo.On("AwardAchievements", ctx, userID, []domain.AchievementID{domain.AchievementFirstLog, domain.AchievementStreak7}, now).
+		Return([]domain.AchievementID{domain.AchievementStreak7}, nil)
+
+	_, err := s.LogHabit(ctx, LogHabitParams{HabitID: habitID, Date: day("2024-03-10"), Value: 1}, userID)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogHabit_AchievementFailureDoesNotFailLog(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID, habitID := uuid.New(), uuid.New()
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: userID, IsBoolean: true}, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("UpsertHabitLog", ctx, mock.AnythingOfType("*domain.HabitLog"), userID).Return(nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID}, repository.LogFilter{}).Return(nil, errors.New("db down"))
+
+	log, err := s.LogHabit(ctx, LogHabitParams{HabitID: habitID, Date: day("2024-03-10"), Value: 1}, userID)
+
+	assert.NoError(t, err)
+	assert.True(t, log.Completed)
+	mockRepo.AssertNotCalled(t, "AwardAchievements", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAchievementFacts(t *testing.T) {
+	today := day("2024-03-10")
+	daily := domain.Schedule{Frequency: domain.FrequencyDaily}
+	var runs []domain.HabitLog
+	for d := day("2024-01-01"); !d.After(today); d = d.AddDate(0, 0, 1) {
+		runs = append(runs, domain.HabitLog{LogDate: d, Value: 15, Status: domain.LogDone})
+	}
+
+	t.Run("numeric daily habit", func(t *testing.T) {
+		var facts achievementFacts
+		facts.addHabit(domain.Habit{Schedule: daily}, runs, today, time.UTC)
+		assert.Equal(t, []domain.AchievementID{
+			domain.AchievementFirstLog, domain.AchievementStreak7, domain.AchievementStreak30, domain.AchievementTotal1000,
+		}, facts.earned())
+	})
+
+	t.Run("weekly streaks are not counted in days", func(t *testing.T) {
+		var facts achievementFacts
+		facts.addHabit(domain.Habit{IsBoolean: true, Schedule: domain.Schedule{Frequency: domain.FrequencyWeekly, TimesPerPeriod: 1}}, runs, today, time.UTC)
+		assert.Equal(t, []domain.AchievementID{domain.AchievementFirstLog}, facts.earned())
+	})
+
+	t.Run("totals are per habit", func(t *testing.T) {
+		var facts achievementFacts
+		half := runs[:40]
+		facts.addHabit(domain.Habit{Schedule: domain.Schedule{Frequency: domain.FrequencyWeekly, TimesPerPeriod: 1}}, half, today, time.UTC)
+		facts.addHabit(domain.Habit{Schedule: domain.Schedule{Frequency: domain.FrequencyWeekly, TimesPerPeriod: 1}}, half, today, time.UTC)
+		assert.NotContains(t, facts.earned(), domain.AchievementTotal1000)
+	})
+
+	t.Run("quit habit counts clean days", func(t *testing.T) {
+		var facts achievementFacts
+		quit := domain.Habit{IsBoolean: true, Polarity: domain.PolarityNegative, Schedule: daily, CreatedAt: day("2023-01-01")}
+		facts.addHabit(quit, []domain.HabitLog{{LogDate: day("2023-02-01"), Value: 1, Status: domain.LogDone}}, today, time.UTC)
+		assert.Contains(t, facts.earned(), domain.AchievementStreak365)
+	})
+
+	t.Run("only completions earn the first log", func(t *testing.T) {
+		var facts achievementFacts
+		target := 30.0
+		reading := domain.Habit{Schedule: daily, Target: domain.Target{Value: &target, Mode: domain.TargetAtLeast}}
+		facts.addHabit(reading, []domain.HabitLog{
+			{LogDate: day("2024-03-01"), Status: domain.LogSkipped},
+			{LogDate: day("2024-03-02"), Status: domain.LogFailed},
+			{LogDate: day("2024-03-03"), Value: 10, Status: domain.LogDone},
+		}, today, time.UTC)
+		quit := domain.Habit{IsBoolean: true, Polarity: domain.PolarityNegative, Schedule: daily, CreatedAt: day("2024-03-08")}
+		facts.addHabit(quit, []domain.HabitLog{{LogDate: day("2024-03-09"), Value: 1, Status: domain.LogDone}}, today, time.UTC)
+		assert.Empty(t, facts.earned())
+	})
+
+	t.Run("followers", func(t *testing.T) {
+		assert.Empty(t, achievementFacts{followers: 9}.earned())
+		assert.Equal(t, []domain.AchievementID{domain.AchievementFollowers10}, achievementFacts{followers: 10}.earned())
+	})
+}
+
+func TestFollowUserByUsername_AwardsFollowerAchievement(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	now := time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	followerID := uuid.New()
+	followed := &domain.User{ID: uuid.New(), Username: "popular"}
+	mockRepo.On("GetUserByUsername", ctx, "popular").Return(followed, nil)
+	mockRepo.On("FollowUser", ctx, followerID, followed.ID).Return(nil)
+	mockRepo.On("GetFollowerCount", ctx, followed.ID).Return(10, nil)
+	mockRepo.On("AwardAchievements", ctx, followed.ID, []domain.AchievementID{domain.AchievementFollowers10}, now).Return(nil, nil)
+
+	err := s.FollowUserByUsername(ctx, followerID, "popular")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBackfillAchievements(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	now := time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	activeID, quietID := uuid.New(), uuid.New()
+	habitID, archivedID := uuid.New(), uuid.New()
+	archivedAt := day("2024-02-01")
+
+	mockRepo.On("GetUsers", ctx).Return([]domain.User{{ID: activeID, Timezone: "UTC"}, {ID: quietID, Timezone: "UTC"}}, nil)
+	mockRepo.On("GetHabitsByUserID", ctx, activeID).Return([]domain.Habit{{ID: habitID, UserID: activeID, IsBoolean: true}}, nil)
+	mockRepo.On("GetArchivedHabitsByUserID", ctx, activeID).Return([]domain.Habit{{ID: archivedID, UserID: activeID, IsBoolean: true, ArchivedAt: &archivedAt}}, nil)
+	mockRepo.On("GetLogsForHabits", ctx, []uuid.UUID{habitID, archivedID}, repository.LogFilter{}).
+		Return([]domain.HabitLog{{HabitID: archivedID, LogDate: day("2024-01-10"), Value: 1, Status: domain.LogDone}}, nil)
+	mockRepo.On("GetFollowerCount", ctx, activeID).Return(12, nil)
+	// Achievements earned before are not returned again.
+	mockRepo.On("AwardAchievements", ctx, activeID, []domain.AchievementID{domain.AchievementFirstLog, domain.AchievementFollowers10}, now).
+		Return([]domain.AchievementID{domain.AchievementFollowers10}, nil)
+
+	mockRepo.On("GetHabitsByUserID", ctx, quietID).Return([]domain.Habit{}, nil)
+	mockRepo.On("GetArchivedHabitsByUserID", ctx, quietID).Return([]domain.Habit{}, nil)
+	mockRepo.On("GetFollowerCount", ctx, quietID).Return(0, nil)
+
+	awarded, err := s.BackfillAchievements(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, awarded)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNumberOfCalls(t, "AwardAchievements", 1)
+}
+
+func TestGetUserAchievements_CatalogueOrder(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	userID := uuid.New()
+	mockRepo.On("GetUserAchievements", ctx, userID).Return(map[domain.AchievementID]time.Time{
+		domain.AchievementFollowers10: day("2024-01-02"),
+		domain.AchievementFirstLog:    day("2024-03-01"),
+		"retired":                     day("2023-01-01"),
+	}, nil)
+
+	achievements, err := s.GetUserAchievements(ctx, userID)
+
+	require.NoError(t, err)
+	require.Len(t, achievements, 2)
+	assert.Equal(t, domain.AchievementFirstLog, achievements[0].ID)
+	assert.Equal(t, "First step", achievements[0].Name)
+	assert.Equal(t, day("2024-03-01"), achievements[0].EarnedAt)
+	assert.Equal(t, domain.AchievementFollowers10, achievements[1].ID)
+}
+
+func TestIncrementHabitLog(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	userID, habitID := uuid.New(), uuid.New()
+	target := 8.0
+	habit := &domain.Habit{ID: habitID, UserID: userID, Target: domain.Target{Value: &target, Mode: domain.TargetAtLeast}}
+
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(habit, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("IncrementHabitLog", ctx, mock.MatchedBy(func(l *domain.HabitLog) bool {
+		return l.HabitID == habitID && l.LogDate.Equal(day("2024-03-10"))
+	}), 1.25, float64(MaxLogValue), false, userID).Return(nil, 8.25)
+	allowAchievements(mockRepo)
+
+	log, err := s.IncrementHabitLog(ctx, IncrementHabitLogParams{HabitID: habitID, Date: day("2024-03-10"), Delta: 1.2504}, userID)
+
+	require.NoError(t, err)
+	assert.Equal(t, 8.25, log.Value)
+	assert.True(t, log.Completed)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestIncrementHabitLog_Rejected(t *testing.T) {
+	userID, habitID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name  string
+		habit *domain.Habit
+		date  time.Time
+		delta float64
+		want  error
+	}{
+		{"zero delta", &domain.Habit{ID: habitID, UserID: userID}, day("2024-03-10"), 0.0001, ErrInvalidIncrement},
+		{"boolean habit", &domain.Habit{ID: habitID, UserID: userID, IsBoolean: true}, day("2024-03-10"), 1, ErrInvalidIncrement},
+		{"future date", &domain.Habit{ID: habitID, UserID: userID}, day("2024-03-11"), 1, ErrFutureLogDate},
+		{"other user's habit", &domain.Habit{ID: habitID, UserID: uuid.New()}, day("2024-03-10"), 1, ErrUserAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			s := New(mockRepo, new(MockStorage))
+			s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+			ctx := context.Background()
+			mockRepo.On("GetHabitByID", ctx, habitID).Return(tt.habit, nil).Maybe()
+			mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil).Maybe()
+
+			_, err := s.IncrementHabitLog(ctx, IncrementHabitLogParams{HabitID: habitID, Date: tt.date, Delta: tt.delta}, userID)
+
+			assert.ErrorIs(t, err, tt.want)
+			mockRepo.AssertNotCalled(t, "IncrementHabitLog", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestIncrementHabitLog_RefusedByRepository(t *testing.T) {
+	userID, habitID := uuid.New(), uuid.New()
+
+	for _, repoErr := range []error{repository.ErrHabitLogNotDone, repository.ErrLogValueTooLarge} {
+		t.Run(repoErr.Error(), func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			s := New(mockRepo, new(MockStorage))
+			s.now = func() time.Time { return time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC) }
+			ctx := context.Background()
+			mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: userID}, nil)
+			mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+			mockRepo.On("IncrementHabitLog", ctx, mock.Anything, 5.0, float64(MaxLogValue), false, userID).Return(repoErr, nil)
+
+			_, err := s.IncrementHabitLog(ctx, IncrementHabitLogParams{HabitID: habitID, Date: day("2024-03-10"), Delta: 5}, userID)
+
+			assert.ErrorIs(t, err, ErrInvalidIncrement)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetHabitLogHistory(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	ownerID, habitID := uuid.New(), uuid.New()
+	done := domain.LogDone
+	five := 5.0
+	revisions := []domain.HabitLogRevision{{ID: 2, HabitID: habitID, OldValue: &five, OldStatus: &done, ActorID: &ownerID}}
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: ownerID}, nil)
+	mockRepo.On("GetHabitLogRevisions", ctx, habitID, day("2024-03-10")).Return(revisions, nil)
+
+	history, err := s.GetHabitLogHistory(ctx, habitID, time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC), uuid.Nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, revisions, history)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetHabitLogHistory_ArchivedHiddenFromOthers(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	habitID := uuid.New()
+	archivedAt := time.Now()
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: uuid.New(), ArchivedAt: &archivedAt}, nil)
+
+	_, err := s.GetHabitLogHistory(ctx, habitID, day("2024-03-10"), uuid.New())
+
+	assert.ErrorIs(t, err, repository.ErrHabitNotFound)
+	mockRepo.AssertNotCalled(t, "GetHabitLogRevisions", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUndoHabitLog(t *testing.T) {
+	mockRepo := new(MockRepository)
+	s := New(mockRepo, new(MockStorage))
+	ctx := context.Background()
+
+	userID, habitID := uuid.New(), uuid.New()
+	undone := int64(7)
+	revision := &domain.HabitLogRevision{ID: 8, HabitID: habitID, Undoes: &undone, ActorID: &userID}
+	mockRepo.On("GetHabitByID", ctx, habitID).Return(&domain.Habit{ID: habitID, UserID: userID}, nil)
+	mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil)
+	mockRepo.On("UndoHabitLog", ctx, habitID, day("2024-03-10"), userID).Return(revision, nil)
+	allowAchievements(mockRepo)
+
+	got, err := s.UndoHabitLog(ctx, habitID, day("2024-03-10"), userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, revision, got)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUndoHabitLog_Rejected(t *testing.T) {
+	userID, habitID := uuid.New(), uuid.New()
+	archivedAt := time.Now()
+
+	tests := []struct {
+		name  string
+		habit *domain.Habit
+		undo  error
+		want  error
+	}{
+		{"nothing to undo", &domain.Habit{ID: habitID, UserID: userID}, repository.ErrNothingToUndo, repository.ErrNothingToUndo},
+		{"other user's habit", &domain.Habit{ID: habitID, UserID: uuid.New()}, nil, ErrUserAccessDenied},
+		{"archived habit", &domain.Habit{ID: habitID, UserID: userID, ArchivedAt: &archivedAt}, nil, ErrHabitArchived},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			s := New(mockRepo, new(MockStorage))
+			ctx := context.Background()
+			mockRepo.On("GetHabitByID", ctx, habitID).Return(tt.habit, nil)
+			mockRepo.On("GetUserByID", ctx, userID).Return(&domain.User{ID: userID, Timezone: "UTC"}, nil).Maybe()
+			mockRepo.On("UndoHabitLog", ctx, habitID, day("2024-03-10"), userID).Return(nil, tt.undo).Maybe()
+
+			_, err := s.UndoHabitLog(ctx, habitID, day("2024-03-10"), userID)
+
+			assert.ErrorIs(t, err, tt.want)
+			mockRepo.AssertNotCalled(t, "AwardAchievements", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/auth"
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+	ErrTokenRevoked        = errors.New("token was revoked")
+)
+
+// maxUserAgentLength bounds the user agent stored to describe a session's device.
+const maxUserAgentLength = 255
+
+// TokenConfig says how the tokens handed to clients are signed and how long they
+// last. A session expires when its refresh token goes unused for RefreshExpiresIn.
+type TokenConfig struct {
+	Secret           string
+	AccessExpiresIn  time.Duration
+	RefreshExpiresIn time.Duration
+}
+
+// SessionClient describes the device a session is used from.
+type SessionClient struct {
+	UserAgent string
+	IP        string
+}
+
+// AuthTokens are handed to a client when it signs in or refreshes its session. The
+// short-lived access token authenticates requests; the refresh token is exchanged for
+// new tokens once it expires.
+type AuthTokens struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// issueTokens stores a new refresh token hash on session and signs the tokens for it,
+// in the user's token generation.
+func issueTokens(session *domain.Session, generation int, tokens TokenConfig) (*AuthTokens, error) {
+	refreshToken, hash, err := auth.NewRefreshToken(session.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	session.RefreshTokenHash = hash
+
+	accessToken, err := auth.GenerateToken(session.UserID, session.ID, generation, tokens.Secret, tokens.AccessExpiresIn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	return &AuthTokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+func (c SessionClient) apply(session *domain.Session) {
+	userAgent := c.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+	session.UserAgent = userAgent
+	session.IPAddress = c.IP
+}
+
+// startSession signs a user in on a new device.
+func (s *Service) startSession(ctx context.Context, userID uuid.UUID, generation int, client SessionClient, tokens TokenConfig) (*AuthTokens, error) {
+	session := &domain.Session{
+		ID:        uuid.New(),
+		UserID:    userID,
+		ExpiresAt: s.now().Add(tokens.RefreshExpiresIn),
+	}
+	client.apply(session)
+
+	issued, err := issueTokens(session, generation, tokens)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// activeSession looks up the session a refresh token belongs to. A token that is not
+// the session's current one was already rotated out, so someone else holds a copy:
+// the session is revoked, signing out both the thief and the owner.
+func (s *Service) activeSession(ctx context.Context, refreshToken string) (*domain.Session, []byte, error) {
+	sessionID, hash, err := auth.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	session, err := s.repo.GetSessionByID(ctx, sessionID)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.RevokedAt != nil || !s.now().Before(session.ExpiresAt) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	if subtle.ConstantTimeCompare(session.RefreshTokenHash, hash) != 1 {
+		return nil, nil, s.revokeReusedSession(ctx, session)
+	}
+	return session, hash, nil
+}
+
+func (s *Service) revokeReusedSession(ctx context.Context, session *domain.Session) error {
+	slog.Warn("refresh token reused, revoking session", "userID", session.UserID, "sessionID", session.ID)
+	err := s.repo.RevokeSession(ctx, session.ID, session.UserID)
+	if err != nil && !errors.Is(err, repository.ErrSessionNotFound) {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// RefreshSession exchanges a refresh token for a new access token and a new refresh
+// token, which replaces the one presented.
+func (s *Service) RefreshSession(ctx context.Context, refreshToken string, client SessionClient, tokens TokenConfig) (*AuthTokens, error) {
+	session, oldHash, err := s.activeSession(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	generation, err := s.repo.GetTokenGeneration(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	session.ExpiresAt = s.now().Add(tokens.RefreshExpiresIn)
+	client.apply(session)
+	issued, err := issueTokens(session, generation, tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.RotateSession(ctx, session, oldHash)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		// The same token was used concurrently and only one use may succeed.
+		return nil, s.revokeReusedSession(ctx, session)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// Logout ends the session a refresh token belongs to.
+func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+	session, _, err := s.activeSession(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	err = s.repo.RevokeSession(ctx, session.ID, session.UserID)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return ErrInvalidRefreshToken
+	}
+	return err
+}
+
+// GetSessions lists the devices the user is signed in on, marking the one with
+// currentSessionID.
+func (s *Service) GetSessions(ctx context.Context, userID, currentSessionID uuid.UUID) ([]domain.Session, error) {
+	sessions, err := s.repo.GetActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// CheckAccessToken fails with ErrTokenRevoked unless an access token issued to the
+// session in generation is still accepted for the user: the session must be active
+// and the user's token generation unchanged.
+func (s *Service) CheckAccessToken(ctx context.Context, userID, sessionID uuid.UUID, generation int) error {
+	current, sessionActive, err := s.repo.GetAccessTokenState(ctx, userID, sessionID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return ErrTokenRevoked
+	}
+	if err != nil {
+		return err
+	}
+	if !sessionActive || generation != current {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// RevokeSession signs the user out of one of their sessions. Its refresh token and
+// the access tokens issued to it stop working at once, see CheckAccessToken.
+func (s *Service) RevokeSession(ctx context.Context, sessionID, userID uuid.UUID) error {
+	return s.repo.RevokeSession(ctx, sessionID, userID)
+}
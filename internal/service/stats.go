@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidDateRange = errors.New("invalid date range")
+
+// maxStatsRange bounds how far back a single stats request may reach.
+const maxStatsRange = 5 * 366 * 24 * time.Hour
+
+type HabitStatsParams struct {
+	HabitID uuid.UUID
+	// From and To bound the range, inclusive. Zero values default to the year up to
+	// the owner's current day.
+	From time.Time
+	To   time.Time
+}
+
+// GetHabitStats aggregates a habit's history. Profiles are public, so any viewer,
+// authenticated or not, may see the stats of a habit shown on one; archived habits
+// are only visible to their owner.
+func (s *Service) GetHabitStats(ctx context.Context, params HabitStatsParams, viewerID uuid.UUID) (*domain.HabitStats, error) {
+	habit, err := s.visibleHabit(ctx, params.HabitID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := s.repo.GetUserByID(ctx, habit.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := loadTimezone(owner.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	today := s.todayIn(owner.Timezone)
+
+	to := dateOf(params.To)
+	if params.To.IsZero() || to.After(today) {
+		to = today
+	}
+	from := dateOf(params.From)
+	if params.From.IsZero() {
+		from = to.AddDate(-1, 0, 1)
+	}
+	if from.After(to) || to.Sub(from) > maxStatsRange {
+		return nil, ErrInvalidDateRange
+	}
+	// Days before the habit existed are not held against it.
+	if created := dateOf(habit.CreatedAt.In(loc)); from.Before(created) {
+		from = created
+	}
+
+	stats, err := s.repo.GetHabitStats(ctx, habit.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if habit.IsBoolean {
+		stats.Values = nil
+	}
+	if habit.Polarity == domain.PolarityNegative {
+		countCleanDays(stats, from, to)
+	}
+	stats.CompletionRate = completionRate(habit.Schedule, stats, from, to)
+	stats.BestWeek, stats.WorstWeek = bestAndWorstWeeks(stats.ByWeek, from, to)
+
+	return stats, nil
+}
+
+// completionRate relates the completions in a range to what the schedule expected.
+// Completions beyond a period's requirement, or on days a habit was not due, do not
+// make up for misses elsewhere. Skipped days are excused and not expected.
+func completionRate(schedule domain.Schedule, stats *domain.HabitStats, from, to time.Time) float64 {
+	if from.After(to) {
+		return 0
+	}
+
+	var done, expected int
+	switch schedule.Frequency {
+	case domain.FrequencyWeekly, domain.FrequencyMonthly:
+		periods := stats.ByWeek
+		if schedule.Frequency == domain.FrequencyMonthly {
+			periods = stats.ByMonth
+		}
+		required := requiredPerPeriod(schedule)
+		for _, p := range periods {
+			needed := max(required-p.SkippedDays, 0)
+			done += min(p.CompletedDays, needed)
+			expected += needed
+		}
+	default:
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			if isDue(schedule, day) {
+				expected++
+			}
+		}
+		for _, w := range stats.ByWeekday {
+			if isDueOn(schedule, time.Weekday(w.Weekday)) {
+				done += w.CompletedDays
+				expected -= w.SkippedDays
+			}
+		}
+	}
+
+	if expected <= 0 {
+		return 0
+	}
+	return float64(done) / float64(expected)
+}
+
+// countCleanDays turns the completed days of a negative habit's stats, which count
+// its relapses, into the days in range that were neither relapses nor skipped. The
+// completion rate and best and worst weeks then follow from the clean days.
+func countCleanDays(stats *domain.HabitStats, from, to time.Time) {
+	stats.RelapseDays = stats.CompletedDays
+	stats.CompletedDays = daysIn(from, to, nil) - stats.CompletedDays - stats.SkippedDays
+	for i := range stats.ByWeekday {
+		w := &stats.ByWeekday[i]
+		weekday := time.Weekday(w.Weekday)
+		w.CompletedDays = daysIn(from, to, &weekday) - w.CompletedDays - w.SkippedDays
+	}
+	for i := range stats.ByWeek {
+		p := &stats.ByWeek[i]
+		end := p.Start.AddDate(0, 0, 6)
+		p.CompletedDays = daysIn(later(p.Start, from), earlier(end, to), nil) - p.CompletedDays - p.SkippedDays
+	}
+	for i := range stats.ByMonth {
+		p := &stats.ByMonth[i]
+		end := p.Start.AddDate(0, 1, -1)
+		p.CompletedDays = daysIn(later(p.Start, from), earlier(end, to), nil) - p.CompletedDays - p.SkippedDays
+	}
+}
+
+// daysIn counts the days from from to to inclusive, only those falling on weekday if
+// it is set.
+func daysIn(from, to time.Time, weekday *time.Weekday) int {
+	var n int
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if weekday == nil || day.Weekday() == *weekday {
+			n++
+		}
+	}
+	return n
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// bestAndWorstWeeks picks the weeks with the most and fewest completions among the
+// weeks lying entirely within the range.
+func bestAndWorstWeeks(weeks []domain.PeriodStats, from, to time.Time) (best, worst *domain.PeriodStats) {
+	for i := range weeks {
+		week := &weeks[i]
+		if week.Start.Before(from) || week.Start.AddDate(0, 0, 6).After(to) {
+			continue
+		}
+		if best == nil || week.CompletedDays > best.CompletedDays ||
+			(week.CompletedDays == best.CompletedDays && week.Total > best.Total) {
+			best = week
+		}
+		if worst == nil || week.CompletedDays < worst.CompletedDays ||
+			(week.CompletedDays == worst.CompletedDays && week.Total < worst.Total) {
+			worst = week
+		}
+	}
+	return best, worst
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/domain"
+)
+
+// dateOf truncates t to its calendar day, keeping the date as seen in t's location.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// completedDays returns the distinct, sorted days up to today on which the habit was
+// completed, and those that were skipped.
+func completedDays(habit domain.Habit, logs []domain.HabitLog, today time.Time) (completed, skipped []time.Time) {
+	seen := make(map[time.Time]bool, len(logs))
+	completed = make([]time.Time, 0, len(logs))
+	for _, log := range logs {
+		day := dateOf(log.LogDate)
+		if day.After(today) || seen[day] {
+			continue
+		}
+		if log.Status == domain.LogSkipped {
+			seen[day] = true
+			skipped = append(skipped, day)
+			continue
+		}
+		if _, done := logCompletion(habit, log); !done {
+			continue
+		}
+		seen[day] = true
+		completed = append(completed, day)
+	}
+	sort.Slice(completed, func(i, j int) bool { return completed[i].Before(completed[j]) })
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Before(skipped[j]) })
+	return completed, skipped
+}
+
+// countIn advances *next past the days before end, returning how many of them fall
+// on or after start and the first of those.
+func countIn(days []time.Time, next *int, start, end time.Time) (count int, first time.Time) {
+	for ; *next < len(days) && days[*next].Before(start); *next++ {
+	}
+	for ; *next < len(days) && days[*next].Before(end); *next++ {
+		if count == 0 {
+			first = days[*next]
+		}
+		count++
+	}
+	return count, first
+}
+
+// ComputeStreak derives the streak of a habit from its logs. today is the owner's
+// current calendar day and loc their location.
+//
+// Streaks are counted in schedule periods: days for daily and weekday habits, weeks
+// or months for habits that must be done N times per period. Days a weekday habit
+// is not due on are neither counted nor treated as misses. Skipped days excuse one
+// required completion each; a period excused entirely is neutral, like a rest day.
+// The period containing today never breaks a streak, since it can still be completed.
+// Negative habits count clean days instead, see cleanStreak.
+func ComputeStreak(habit domain.Habit, logs []domain.HabitLog, today time.Time, loc *time.Location) domain.Streak {
+	today = dateOf(today)
+	if habit.Polarity == domain.PolarityNegative {
+		return cleanStreak(habit, logs, today, loc)
+	}
+
+	days, skipped := completedDays(habit, logs, today)
+	if len(days) == 0 {
+		return domain.Streak{}
+	}
+
+	var streak domain.Streak
+	last := days[len(days)-1]
+	streak.LastCompletedOn = &last
+
+	schedule := habit.Schedule
+	required := requiredPerPeriod(schedule)
+
+	var run int
+	var runStart time.Time
+	next, nextSkipped := 0, 0
+	for start := periodStart(schedule, days[0]); !start.After(today); start = nextPeriodStart(schedule, start) {
+		end := nextPeriodStart(schedule, start)
+
+		count, first := countIn(days, &next, start, end)
+		excused, _ := countIn(skipped, &nextSkipped, start, end)
+
+		if schedule.Frequency == domain.FrequencyWeekdays && !isDue(schedule, start) {
+			continue
+		}
+
+		switch needed := required - excused; {
+		case count > 0 && count >= needed:
+			if run == 0 {
+				runStart = first
+			}
+			run++
+			streak.Longest = max(streak.Longest, run)
+		case needed <= 0:
+			// Every required completion was excused.
+		case end.After(today):
+			// The current period is still in progress.
+		default:
+			run = 0
+		}
+	}
+
+	if run > 0 {
+		streak.Current = run
+		streak.StartedOn = &runStart
+	}
+
+	return streak
+}
+
+// cleanStreak counts the days a negative habit went without a relapse, from the day it
+// was created in the owner's location loc, or its first log if earlier, up to today. Today counts as clean until a
+// relapse is logged. Skipped days are excused: they neither count nor break a streak.
+func cleanStreak(habit domain.Habit, logs []domain.HabitLog, today time.Time, loc *time.Location) domain.Streak {
+	var start time.Time
+	if !habit.CreatedAt.IsZero() {
+		start = dateOf(habit.CreatedAt.In(loc))
+	}
+	relapses := make(map[time.Time]bool)
+	skipped := make(map[time.Time]bool)
+	for _, log := range logs {
+		day := dateOf(log.LogDate)
+		if day.After(today) {
+			continue
+		}
+		if start.IsZero() || day.Before(start) {
+			start = day
+		}
+		switch {
+		case isRelapse(log):
+			relapses[day] = true
+		case log.Status == domain.LogSkipped:
+			skipped[day] = true
+		}
+	}
+	if start.IsZero() || start.After(today) {
+		return domain.Streak{}
+	}
+
+	var streak domain.Streak
+	var run int
+	var runStart time.Time
+	for day := start; !day.After(today); day = day.AddDate(0, 0, 1) {
+		switch {
+		case relapses[day]:
+			relapse := day
+			streak.LastRelapseOn = &relapse
+			run = 0
+		case skipped[day]:
+		default:
+			if run == 0 {
+				runStart = day
+			}
+			run++
+			streak.Longest = max(streak.Longest, run)
+		}
+	}
+
+	if run > 0 {
+		streak.Current = run
+		streak.StartedOn = &runStart
+	}
+
+	return streak
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func dayPtr(s string) *time.Time {
+	t := day(s)
+	return &t
+}
+
+func logsOn(dates ...string) []domain.HabitLog {
+	logs := make([]domain.HabitLog, len(dates))
+	for i, d := range dates {
+		logs[i] = domain.HabitLog{LogDate: day(d), Value: 1}
+	}
+	return logs
+}
+
+func TestComputeStreak(t *testing.T) {
+	today := day("2024-03-10")
+	daily := domain.Habit{Schedule: domain.Schedule{Frequency: domain.FrequencyDaily}}
+
+	tests := []struct {
+		name     string
+		logs     []domain.HabitLog
+		expected domain.Streak
+	}{
+		{
+			name:     "no logs",
+			logs:     nil,
+			expected: domain.Streak{},
+		},
+		{
+			name: "completed today only",
+			logs: logsOn("2024-03-10"),
+			expected: domain.Streak{
+				Current:         1,
+				Longest:         1,
+				LastCompletedOn: dayPtr("2024-03-10"),
+				StartedOn:       dayPtr("2024-03-10"),
+			},
+		},
+		{
+			name: "streak ending yesterday is still current",
+			logs: logsOn("2024-03-07", "2024-03-08", "2024-03-09"),
+			expected: domain.Streak{
+				Current:         3,
+				Longest:         3,
+				LastCompletedOn: dayPtr("2024-03-09"),
+				StartedOn:       dayPtr("2024-03-07"),
+			},
+		},
+		{
+			name: "streak broken two days ago",
+			logs: logsOn("2024-03-06", "2024-03-07", "2024-03-08"),
+			expected: domain.Streak{
+				Current:         0,
+				Longest:         3,
+				LastCompletedOn: dayPtr("2024-03-08"),
+			},
+		},
+		{
+			name: "longest streak in the past",
+			logs: logsOn("2024-02-01", "2024-02-02", "2024-02-03", "2024-02-04", "2024-03-09", "2024-03-10"),
+			expected: domain.Streak{
+				Current:         2,
+				Longest:         4,
+				LastCompletedOn: dayPtr("2024-03-10"),
+				StartedOn:       dayPtr("2024-03-09"),
+			},
+		},
+		{
+			name: "unsorted and duplicate logs",
+			logs: logsOn("2024-03-10", "2024-03-08", "2024-03-09", "2024-03-09"),
+			expected: domain.Streak{
+				Current:         3,
+				Longest:         3,
+				LastCompletedOn: dayPtr("2024-03-10"),
+				StartedOn:       dayPtr("2024-03-08"),
+			},
+		},
+		{
+			name: "zero values are not completions",
+			logs: []domain.HabitLog{
+				{LogDate: day("2024-03-08"), Value: 1},
+				{LogDate: day("2024-03-09"), Value: 0},
+				{LogDate: day("2024-03-10"), Value: 3},
+			},
+			expected: domain.Streak{
+				Current:         1,
+				Longest:         1,
+				LastCompletedOn: dayPtr("2024-03-10"),
+				StartedOn:       dayPtr("2024-03-10"),
+			},
+		},
+		{
+			name: "skipped days neither break nor extend a streak",
+			logs: []domain.HabitLog{
+				{LogDate: day("2024-03-07"), Value: 1},
+				{LogDate: day("2024-03-08"), Status: domain.LogSkipped},
+				{LogDate: day("2024-03-09"), Value: 1},
+				{LogDate: day("2024-03-10"), Value: 1},
+			},
+			expected: domain.Streak{
+				Current:         3,
+				Longest:         3,
+				LastCompletedOn: dayPtr("2024-03-10"),
+				StartedOn:       dayPtr("2024-03-07"),
+			},
+		},
+		{
+			name: "failed days break a streak",
+			logs: []domain.HabitLog{
+				{LogDate: day("2024-03-08"), Value: 1},
+				{LogDate: day("2024-03-09"), Status: domain.LogFailed},
+				{LogDate: day("2024-03-10"), Value: 1},
+			},
+			expected: domain.Streak{
+				Current:         1,
+				Longest:         1,
+				LastCompletedOn: dayPtr("2024-03-10"),
+				StartedOn:       dayPtr("2024-03-10"),
+			},
+		},
+		{
+			name: "future logs are ignored",
+			logs: logsOn("2024-03-10", "2024-03-11", "2024-03-12"),
+			expected: domain.Streak{
+				Current:         1,
+				Longest:         1,
+				LastCompletedOn: dayPtr("2024-03-10"),
+				StartedOn:       dayPtr("2024-03-10"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ComputeStreak(daily, tt.logs, today, time.UTC))
+		})
+	}
+}
+
+func TestComputeStreak_Schedules(t *testing.T) {
+	// 2024-03-10 is a Sunday.
+	today := day("2024-03-10")
+	monWedFri := domain.Habit{Schedule: domain.Schedule{
+		Frequency: domain.FrequencyWeekdays,
+		Weekdays:  []int{int(time.Monday), int(time.Wednesday), int(time.Friday)},
+	}}
+	threePerWeek := domain.Habit{Schedule: domain.Schedule{Frequency: domain.FrequencyWeekly, TimesPerPeriod: 3}}
+	twicePerMonth := domain.Habit{Schedule: domain.Schedule{Frequency: domain.FrequencyMonthly, TimesPerPeriod: 2}}
+
+	tests := []struct {
+		name     string
+		habit    domain.Habit
+		logs     []domain.HabitLog
+		expected domain.Streak
+	}{
+		{
+			name:  "rest days do not break a weekday streak",
+			habit: monWedFri,
+			logs:  logsOn("2024-02-26", "2024-02-28", "2024-03-01", "2024-03-04", "2024-03-06", "2024-03-08"),
+			expected: domain.Streak{
+				Current:         6,
+				Longest:         6,
+				LastCompletedOn: dayPtr("2024-03-08"),
+				StartedOn:       dayPtr("2024-02-26"),
+			},
+		},
+		{
+			name:  "missed due day breaks a weekday streak",
+			habit: monWedFri,
+			logs:  logsOn("2024-02-26", "2024-02-28", "2024-03-04", "2024-03-06", "2024-03-08"),
+			expected: domain.Streak{
+				Current:         3,
+				Longest:         3,
+				LastCompletedOn: dayPtr("2024-03-08"),
+				StartedOn:       dayPtr("2024-03-04"),
+			},
+		},
+		{
+			name:  "completions on rest days are not counted",
+			habit: monWedFri,
+			logs:  logsOn("2024-03-06", "2024-03-07", "2024-03-08", "2024-03-09"),
+			expected: domain.Streak{
+				Current:         2,
+				Longest:         2,
+				LastCompletedOn: dayPtr("2024-03-09"),
+				StartedOn:       dayPtr("2024-03-06"),
+			},
+		},
+		{
+			name:  "weekly target met in consecutive weeks",
+			habit: threePerWeek,
+			logs: logsOn(
+				"2024-02-19", "2024-02-21", "2024-02-23",
+				"2024-02-26", "2024-02-27", "2024-03-02",
+				"2024-03-04", "2024-03-05", "2024-03-10",
+			),
+			expected: domain.Streak{
+				Current:         3,
+				Longest:         3,
+				LastCompletedOn: dayPtr("2024-03-10"),
+				StartedOn:       dayPtr("2024-02-19"),
+			},
+		},
+		{
+			name:  "skipped days lower a week's requirement",
+			habit: threePerWeek,
+			logs: append(
+				logsOn("2024-02-26", "2024-02-27", "2024-03-04", "2024-03-05", "2024-03-06"),
+				domain.HabitLog{LogDate: day("2024-02-28"), Status: domain.LogSkipped},
+			),
+			expected: domain.Streak{
+				Current:         2,
+				Longest:         2,
+				LastCompletedOn: dayPtr("2024-03-06"),
+				StartedOn:       dayPtr("2024-02-26"),
+			},
+		},
+		{
+			name:  "unfinished current week does not break the streak",
+			habit: threePerWeek,
+			logs:  logsOn("2024-02-26", "2024-02-27", "2024-03-02", "2024-03-04"),
+			expected: domain.Streak{
+				Current:         1,
+				Longest:         1,
+				LastCompletedOn: dayPtr("2024-03-04"),
+				StartedOn:       dayPtr("2024-02-26"),
+			},
+		},
+		{
+			name:  "week below target breaks the streak",
+			habit: threePerWeek,
+			logs:  logsOn("2024-02-19", "2024-02-21", "2024-02-23", "2024-02-26", "2024-03-04"),
+			expected: domain.Streak{
+				Current:         0,
+				Longest:         1,
+				LastCompletedOn: dayPtr("2024-03-04"),
+			},
+		},
+		{
+			name:  "monthly target",
+			habit: twicePerMonth,
+			logs:  logsOn("2024-01-03", "2024-01-20", "2024-02-01", "2024-02-29", "2024-03-05"),
+			expected: domain.Streak{
+				Current:         2,
+				Longest:         2,
+				LastCompletedOn: dayPtr("2024-03-05"),
+				StartedOn:       dayPtr("2024-01-03"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ComputeStreak(tt.habit, tt.logs, today, time.UTC))
+		})
+	}
+}
+
+func TestComputeStreak_NegativeHabit(t *testing.T) {
+	today := day("2024-03-10")
+	quit := domain.Habit{
+		Polarity:  domain.PolarityNegative,
+		Schedule:  domain.Schedule{Frequency: domain.FrequencyDaily},
+		CreatedAt: time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name     string
+		logs     []domain.HabitLog
+		expected domain.Streak
+	}{
+		{
+			name: "clean since creation",
+			logs: nil,
+			expected: domain.Streak{
+				Current:   10,
+				Longest:   10,
+				StartedOn: dayPtr("2024-03-01"),
+			},
+		},
+		{
+			name: "relapse resets the streak",
+			logs: logsOn("2024-03-04", "2024-03-07"),
+			expected: domain.Streak{
+				Current:       3,
+				Longest:       3,
+				LastRelapseOn: dayPtr("2024-03-07"),
+				StartedOn:     dayPtr("2024-03-08"),
+			},
+		},
+		{
+			name: "relapse today",
+			logs: logsOn("2024-03-10"),
+			expected: domain.Streak{
+				Current:       0,
+				Longest:       9,
+				LastRelapseOn: dayPtr("2024-03-10"),
+			},
+		},
+		{
+			name: "skipped days are excused",
+			logs: []domain.HabitLog{
+				{LogDate: day("2024-03-05"), Value: 1, Status: domain.LogDone},
+				{LogDate: day("2024-03-08"), Status: domain.LogSkipped},
+				{LogDate: day("2024-03-09"), Status: domain.LogFailed},
+			},
+			expected: domain.Streak{
+				Current:       4,
+				Longest:       4,
+				LastRelapseOn: dayPtr("2024-03-05"),
+				StartedOn:     dayPtr("2024-03-06"),
+			},
+		},
+		{
+			name: "backfilled relapses before creation",
+			logs: logsOn("2024-02-25"),
+			expected: domain.Streak{
+				Current:       14,
+				Longest:       14,
+				LastRelapseOn: dayPtr("2024-02-25"),
+				StartedOn:     dayPtr("2024-02-26"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ComputeStreak(quit, tt.logs, today, time.UTC))
+		})
+	}
+}
+
+func TestComputeStreak_NegativeHabitCreatedInOwnerTimezone(t *testing.T) {
+	today := day("2024-03-10")
+	quit := domain.Habit{
+		Polarity:  domain.PolarityNegative,
+		Schedule:  domain.Schedule{Frequency: domain.FrequencyDaily},
+		CreatedAt: time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC),
+	}
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+
+	// 18:00 UTC is already March 2 in Tokyo.
+	streak := ComputeStreak(quit, nil, today, tokyo)
+	assert.Equal(t, 9, streak.Current)
+	assert.Equal(t, dayPtr("2024-03-02"), streak.StartedOn)
+
+	// 03:00 UTC is still February 29 in Los Angeles.
+	quit.CreatedAt = time.Date(2024, 3, 1, 3, 0, 0, 0, time.UTC)
+	streak = ComputeStreak(quit, nil, today, losAngeles)
+	assert.Equal(t, 11, streak.Current)
+	assert.Equal(t, dayPtr("2024-02-29"), streak.StartedOn)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidCategory = errors.New("invalid habit category")
+	ErrInvalidTagName  = errors.New("invalid tag name")
+)
+
+// normalizeCategory validates a category, treating an empty one as none.
+func normalizeCategory(category string) (*domain.HabitCategory, error) {
+	if category == "" {
+		return nil, nil
+	}
+	c := domain.HabitCategory(category)
+	if !slices.Contains(domain.HabitCategories, c) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCategory, category)
+	}
+	return &c, nil
+}
+
+type TagParams struct {
+	Name     string
+	ColorHue int
+}
+
+func (s *Service) CreateTag(ctx context.Context, params TagParams, userID uuid.UUID) (*domain.Tag, error) {
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		return nil, ErrInvalidTagName
+	}
+
+	tag := &domain.Tag{
+		ID:       uuid.New(),
+		UserID:   userID,
+		Name:     name,
+		ColorHue: params.ColorHue,
+	}
+	if err := s.repo.CreateTag(ctx, tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (s *Service) GetTags(ctx context.Context, userID uuid.UUID) ([]domain.Tag, error) {
+	return s.repo.GetTagsByUserID(ctx, userID)
+}
+
+func (s *Service) UpdateTag(ctx context.Context, tagID uuid.UUID, params TagParams, userID uuid.UUID) (*domain.Tag, error) {
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		return nil, ErrInvalidTagName
+	}
+
+	tag := &domain.Tag{
+		ID:       tagID,
+		UserID:   userID,
+		Name:     name,
+		ColorHue: params.ColorHue,
+	}
+	if err := s.repo.UpdateTag(ctx, tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (s *Service) DeleteTag(ctx context.Context, tagID, userID uuid.UUID) error {
+	return s.repo.DeleteTag(ctx, tagID, userID)
+}
+
+// resolveTags looks up the given tags among the user's own, failing with
+// repository.ErrTagNotFound if any of them belongs to someone else or does not exist.
+func (s *Service) resolveTags(ctx context.Context, userID uuid.UUID, tagIDs []uuid.UUID) ([]domain.Tag, error) {
+	owned, err := s.repo.GetTagsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]domain.Tag, 0, len(tagIDs))
+	for _, id := range tagIDs {
+		i := slices.IndexFunc(owned, func(t domain.Tag) bool { return t.ID == id })
+		if i < 0 {
+			return nil, repository.ErrTagNotFound
+		}
+		if !slices.ContainsFunc(tags, func(t domain.Tag) bool { return t.ID == id }) {
+			tags = append(tags, owned[i])
+		}
+	}
+	return tags, nil
+}
+
+// attachTags fills in the tags of each habit.
+func (s *Service) attachTags(ctx context.Context, habits []domain.Habit) error {
+	if len(habits) == 0 {
+		return nil
+	}
+
+	habitIDs := make([]uuid.UUID, len(habits))
+	for i, habit := range habits {
+		habitIDs[i] = habit.ID
+	}
+
+	tagsByHabitID, err := s.repo.GetTagsForHabits(ctx, habitIDs)
+	if err != nil {
+		return err
+	}
+	for i := range habits {
+		habits[i].Tags = tagsByHabitID[habits[i].ID]
+	}
+	return nil
+}
+
+// withTag keeps the habits carrying a tag named name, ignoring case.
+func withTag(habits []domain.Habit, name string) []domain.Habit {
+	return slices.DeleteFunc(habits, func(h domain.Habit) bool {
+		return !slices.ContainsFunc(h.Tags, func(t domain.Tag) bool { return strings.EqualFold(t.Name, name) })
+	})
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/axseem/peakstreak/internal/domain"
+)
+
+var ErrInvalidTarget = errors.New("invalid habit target")
+
+// normalizeTarget validates a target for a habit of the given type. An empty mode
+// defaults to "at least". Boolean habits are done or not, so they cannot have a target.
+func normalizeTarget(isBoolean bool, target domain.Target) (domain.Target, error) {
+	if target.Mode == "" {
+		target.Mode = domain.TargetAtLeast
+	}
+
+	switch target.Mode {
+	case domain.TargetAtLeast, domain.TargetExactly:
+		if target.Value != nil && *target.Value <= 0 {
+			return domain.Target{}, fmt.Errorf("%w: target must be positive", ErrInvalidTarget)
+		}
+	case domain.TargetAtMost:
+		if target.Value != nil && *target.Value < 0 {
+			return domain.Target{}, fmt.Errorf("%w: target cannot be negative", ErrInvalidTarget)
+		}
+	default:
+		return domain.Target{}, fmt.Errorf("%w: unknown mode %q", ErrInvalidTarget, target.Mode)
+	}
+
+	if isBoolean && target.Value != nil {
+		return domain.Target{}, fmt.Errorf("%w: boolean habits cannot have a target", ErrInvalidTarget)
+	}
+	if target.Value != nil {
+		value := roundValue(*target.Value)
+		target.Value = &value
+	}
+
+	return target, nil
+}
+
+// completionOf returns the fraction of the habit's target that value reaches, and
+// whether it counts as a completed day. Values of negative habits record relapses,
+// which never complete a day. A zero value completes nothing, except under an "at
+// most" target, where it stays within the limit.
+func completionOf(habit domain.Habit, value float64) (float64, bool) {
+	if habit.Polarity == domain.PolarityNegative {
+		return 0, false
+	}
+	if hasLimit(habit) {
+		v, t := max(value, 0), *habit.Target.Value
+		if v <= t {
+			return 1, true
+		}
+		return t / v, false
+	}
+	if value <= 0 {
+		return 0, false
+	}
+	if habit.IsBoolean || habit.Target.Value == nil {
+		return 1, true
+	}
+
+	v, t := value, *habit.Target.Value
+	switch habit.Mode {
+	case domain.TargetExactly:
+		if v == t {
+			return 1, true
+		}
+		return min(v, t) / max(v, t), false
+	default:
+		if v >= t {
+			return 1, true
+		}
+		return v / t, false
+	}
+}
+
+// hasLimit reports whether the habit is capped by an "at most" target, so a day
+// logged as zero is one kept within the limit rather than one without a log.
+func hasLimit(habit domain.Habit) bool {
+	return !habit.IsBoolean && habit.Target.Value != nil && habit.Mode == domain.TargetAtMost
+}
+
+// logCompletion is completionOf for a log. Only days marked as done can be complete.
+func logCompletion(habit domain.Habit, log domain.HabitLog) (float64, bool) {
+	if log.Status != "" && log.Status != domain.LogDone {
+		return 0, false
+	}
+	return completionOf(habit, log.Value)
+}
+
+// evaluateLogs fills in the derived completion fields of a habit's logs.
+func evaluateLogs(habit domain.Habit, logs []domain.HabitLog) {
+	for i := range logs {
+		logs[i].Completion, logs[i].Completed = logCompletion(habit, logs[i])
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionOf(t *testing.T) {
+	target := func(mode domain.TargetMode, value float64) domain.Habit {
+		return domain.Habit{Target: domain.Target{Mode: mode, Value: &value}}
+	}
+
+	tests := []struct {
+		name       string
+		habit      domain.Habit
+		value      float64
+		completion float64
+		completed  bool
+	}{
+		{"boolean done", domain.Habit{IsBoolean: true}, 1, 1, true},
+		{"boolean not done", domain.Habit{IsBoolean: true}, 0, 0, false},
+		{"numeric without target", domain.Habit{}, 7, 1, true},
+		{"at least, partial", target(domain.TargetAtLeast, 100), 25, 0.25, false},
+		{"at least, reached", target(domain.TargetAtLeast, 100), 100, 1, true},
+		{"at least, exceeded", target(domain.TargetAtLeast, 100), 150, 1, true},
+		{"at most, within", target(domain.TargetAtMost, 2), 1, 1, true},
+		{"at most, exceeded", target(domain.TargetAtMost, 2), 4, 0.5, false},
+		{"exactly, hit", target(domain.TargetExactly, 8), 8, 1, true},
+		{"exactly, under", target(domain.TargetExactly, 8), 6, 0.75, false},
+		{"exactly, over", target(domain.TargetExactly, 8), 10, 0.8, false},
+		{"at most, zero", target(domain.TargetAtMost, 2), 0, 1, true},
+		{"at most zero, zero", target(domain.TargetAtMost, 0), 0, 1, true},
+		{"at most zero, exceeded", target(domain.TargetAtMost, 0), 3, 0, false},
+		{"zero without target", domain.Habit{}, 0, 0, false},
+		{"at least, zero", target(domain.TargetAtLeast, 2), 0, 0, false},
+		{"exactly, zero", target(domain.TargetExactly, 2), 0, 0, false},
+		{"decimal at least, partial", target(domain.TargetAtLeast, 2.5), 1.25, 0.5, false},
+		{"decimal at least, reached", target(domain.TargetAtLeast, 2.5), 2.5, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			completion, completed := completionOf(tt.habit, tt.value)
+			assert.InDelta(t, tt.completion, completion, 1e-9)
+			assert.Equal(t, tt.completed, completed)
+		})
+	}
+}
+
+func TestNormalizeTarget(t *testing.T) {
+	zero, ten := 0.0, 10.0
+
+	target, err := normalizeTarget(false, domain.Target{Value: &ten})
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TargetAtLeast, target.Mode)
+
+	_, err = normalizeTarget(false, domain.Target{Value: &zero, Mode: domain.TargetExactly})
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+
+	_, err = normalizeTarget(false, domain.Target{Value: &zero, Mode: domain.TargetAtMost})
+	assert.NoError(t, err)
+
+	_, err = normalizeTarget(true, domain.Target{Value: &ten})
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+
+	fine := 2.12345
+	target, err = normalizeTarget(false, domain.Target{Value: &fine})
+	assert.NoError(t, err)
+	assert.Equal(t, 2.123, *target.Value)
+}
+
+func TestNormalizeUnit(t *testing.T) {
+	unit, err := normalizeUnit(false, "  km ")
+	assert.NoError(t, err)
+	assert.Equal(t, "km", *unit)
+
+	unit, err = normalizeUnit(false, " ")
+	assert.NoError(t, err)
+	assert.Nil(t, unit)
+
+	_, err = normalizeUnit(true, "km")
+	assert.ErrorIs(t, err, ErrInvalidUnit)
+
+	_, err = normalizeUnit(false, "kilometres-per-hour")
+	assert.ErrorIs(t, err, ErrInvalidUnit)
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/axseem/peakstreak/internal/domain"
+	"github.com/axseem/peakstreak/internal/repository"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidTemplate = errors.New("invalid habit template")
+
+const (
+	MaxTemplateDescriptionLength = 280
+	// maxPublishedTemplates bounds how many user-published templates are listed after
+	// the curated ones.
+	maxPublishedTemplates = 100
+)
+
+// SetTemplateCatalogue validates the curated habit templates and makes them available
+// to users, replacing any set before.
+func (s *Service) SetTemplateCatalogue(catalogue []domain.HabitTemplate) error {
+	seen := make(map[string]bool, len(catalogue))
+	templates := make([]domain.HabitTemplate, 0, len(catalogue))
+	for _, t := range catalogue {
+		if t.ID == "" || seen[t.ID] {
+			return fmt.Errorf("%w: template IDs must be present and unique, got %q", ErrInvalidTemplate, t.ID)
+		}
+		// Published templates are identified by a UUID, so curated ones cannot be.
+		if _, err := uuid.Parse(t.ID); err == nil {
+			return fmt.Errorf("%w: template ID %q cannot be a UUID", ErrInvalidTemplate, t.ID)
+		}
+		seen[t.ID] = true
+
+		normalized, err := normalizeTemplate(t)
+		if err != nil {
+			return fmt.Errorf("template %q: %w", t.ID, err)
+		}
+		normalized.Author, normalized.CreatedAt = nil, nil
+		templates = append(templates, normalized)
+	}
+	s.templates = templates
+	return nil
+}
+
+// normalizeTemplate validates a template the way a habit created from it would be.
+func normalizeTemplate(t domain.HabitTemplate) (domain.HabitTemplate, error) {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" || utf8.RuneCountInString(t.Name) > 100 {
+		return domain.HabitTemplate{}, fmt.Errorf("%w: name must be between 1 and 100 characters", ErrInvalidTemplate)
+	}
+	t.Description = strings.TrimSpace(t.Description)
+	if utf8.RuneCountInString(t.Description) > MaxTemplateDescriptionLength {
+		return domain.HabitTemplate{}, fmt.Errorf("%w: description must be at most %d characters", ErrInvalidTemplate, MaxTemplateDescriptionLength)
+	}
+	if t.ColorHue < 0 || t.ColorHue > 360 {
+		return domain.HabitTemplate{}, fmt.Errorf("%w: colour hue must be between 0 and 360", ErrInvalidTemplate)
+	}
+
+	var err error
+	if t.Polarity, err = normalizePolarity(string(t.Polarity)); err != nil {
+		return domain.HabitTemplate{}, err
+	}
+	if t.Schedule, err = normalizeSchedule(t.Schedule); err != nil {
+		return domain.HabitTemplate{}, err
+	}
+	if t.Target, err = normalizeTarget(t.IsBoolean, t.Target); err != nil {
+		return domain.HabitTemplate{}, err
+	}
+	var unit, category string
+	if t.Unit != nil {
+		unit = *t.Unit
+	}
+	if t.Category != nil {
+		category = string(*t.Category)
+	}
+	if t.Unit, err = normalizeUnit(t.IsBoolean, unit); err != nil {
+		return domain.HabitTemplate{}, err
+	}
+	if t.Category, err = normalizeCategory(category); err != nil {
+		return domain.HabitTemplate{}, err
+	}
+	habit := domain.Habit{IsBoolean: t.IsBoolean, Polarity: t.Polarity, Schedule: t.Schedule, Target: t.Target}
+	if err := checkPolarity(habit); err != nil {
+		return domain.HabitTemplate{}, err
+	}
+	return t, nil
+}
+
+// GetHabitTemplates lists the curated templates followed by the most recently
+// published ones, optionally only those in category.
+func (s *Service) GetHabitTemplates(ctx context.Context, category string) ([]domain.HabitTemplate, error) {
+	c, err := normalizeCategory(category)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]domain.HabitTemplate, 0, len(s.templates))
+	for _, t := range s.templates {
+		if c == nil || (t.Category != nil && *t.Category == *c) {
+			templates = append(templates, t)
+		}
+	}
+
+	var filter domain.HabitCategory
+	if c != nil {
+		filter = *c
+	}
+	published, err := s.repo.GetHabitTemplates(ctx, filter, maxPublishedTemplates)
+	if err != nil {
+		return nil, err
+	}
+	return append(templates, published...), nil
+}
+
+// GetHabitTemplate looks up a curated template by its ID, or a published one by its
+// UUID.
+func (s *Service) GetHabitTemplate(ctx context.Context, templateID string) (*domain.HabitTemplate, error) {
+	for _, t := range s.templates {
+		if t.ID == templateID {
+			return &t, nil
+		}
+	}
+	id, err := uuid.Parse(templateID)
+	if err != nil {
+		return nil, repository.ErrHabitTemplateNotFound
+	}
+	return s.repo.GetHabitTemplateByID(ctx, id)
+}
+
+// CreateHabitFromTemplate adds a habit set up like the template to the user's habits.
+func (s *Service) CreateHabitFromTemplate(ctx context.Context, templateID string, userID uuid.UUID) (*domain.Habit, error) {
+	t, err := s.GetHabitTemplate(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := CreateHabitParams{
+		Name:      t.Name,
+		ColorHue:  t.ColorHue,
+		IsBoolean: t.IsBoolean,
+		Polarity:  string(t.Polarity),
+		Schedule:  t.Schedule,
+		Target:    t.Target,
+	}
+	if t.Unit != nil {
+		params.Unit = *t.Unit
+	}
+	if t.Category != nil {
+		params.Category = string(*t.Category)
+	}
+	return s.CreateHabit(ctx, params, userID)
+}
+
+// PublishHabitTemplate shares one of the user's habits as a template anyone can
+// adopt. Logs are not part of a template. Publishing a habit again updates its
+// template to match the habit.
+func (s *Service) PublishHabitTemplate(ctx context.Context, habitID, userID uuid.UUID, description string) (*domain.HabitTemplate, error) {
+	habit, err := s.repo.GetHabitByID(ctx, habitID)
+	if err != nil {
+		return nil, err
+	}
+	if habit.UserID != userID {
+		return nil, ErrUserAccessDenied
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := normalizeTemplate(domain.HabitTemplate{
+		Name:        habit.Name,
+		Description: description,
+		ColorHue:    habit.ColorHue,
+		IsBoolean:   habit.IsBoolean,
+		Polarity:    habit.Polarity,
+		Schedule:    habit.Schedule,
+		Target:      habit.Target,
+		Unit:        habit.Unit,
+		Category:    habit.Category,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.PublishHabitTemplate(ctx, &t, userID, habitID); err != nil {
+		return nil, err
+	}
+	t.Author = &domain.PublicUser{ID: user.ID, Username: user.Username, AvatarURL: user.AvatarURL}
+	return &t, nil
+}
+
+// DeleteHabitTemplate withdraws a template the user published. Habits already
+// created from it are kept.
+func (s *Service) DeleteHabitTemplate(ctx context.Context, templateID, userID uuid.UUID) error {
+	return s.repo.DeleteHabitTemplate(ctx, templateID, userID)
+}
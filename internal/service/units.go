@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/axseem/peakstreak/internal/domain"
+)
+
+var ErrInvalidUnit = errors.New("invalid unit")
+
+const (
+	MaxUnitLength = 16
+	// ValueDecimals is how many decimal places of a value are stored.
+	ValueDecimals = 3
+)
+
+// normalizeUnit trims a unit label, returning nil for none. Only numeric habits can
+// have a unit.
+func normalizeUnit(isBoolean bool, unit string) (*string, error) {
+	unit = strings.TrimSpace(unit)
+	if unit == "" {
+		return nil, nil
+	}
+	if isBoolean {
+		return nil, fmt.Errorf("%w: boolean habits cannot have a unit", ErrInvalidUnit)
+	}
+	if utf8.RuneCountInString(unit) > MaxUnitLength {
+		return nil, fmt.Errorf("%w: at most %d characters are allowed", ErrInvalidUnit, MaxUnitLength)
+	}
+	return &unit, nil
+}
+
+// roundValue rounds a value to the precision it is stored with.
+func roundValue(v float64) float64 {
+	scale := math.Pow10(ValueDecimals)
+	return math.Round(v*scale) / scale
+}
+
+// formatValue formats a value without trailing zeros, followed by the habit's unit.
+func formatValue(habit domain.Habit, v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if habit.Unit != nil {
+		s += " " + *habit.Unit
+	}
+	return s
+}
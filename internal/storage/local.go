@@ -48,14 +48,11 @@ func (s *LocalStorage) Save(ctx context.Context, file io.Reader, filename string
 
 // Delete removes a file from the local disk based on its public URL.
 func (s *LocalStorage) Delete(ctx context.Context, url string) error {
-	if !strings.HasPrefix(url, s.publicURL) {
-		return fmt.Errorf("URL '%s' does not match public URL prefix '%s'", url, s.publicURL)
+	filePath, err := s.pathOf(url)
+	if err != nil {
+		return err
 	}
 
-	// Extract the filename from the URL.
-	filename := strings.TrimPrefix(url, s.publicURL)
-	filePath := filepath.Join(s.basePath, filename)
-
 	// Check if the file exists before trying to remove it.
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		// File doesn't exist, which is fine. The goal is for it to be gone.
@@ -68,3 +65,28 @@ func (s *LocalStorage) Delete(ctx context.Context, url string) error {
 
 	return nil
 }
+
+// Open opens a file on the local disk based on its public URL.
+func (s *LocalStorage) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	filePath, err := s.pathOf(url)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
+
+// pathOf maps a public URL to the file's path on disk.
+func (s *LocalStorage) pathOf(url string) (string, error) {
+	if !strings.HasPrefix(url, s.publicURL) {
+		return "", fmt.Errorf("URL '%s' does not match public URL prefix '%s'", url, s.publicURL)
+	}
+
+	// Extract the filename from the URL.
+	filename := strings.TrimPrefix(url, s.publicURL)
+	return filepath.Join(s.basePath, filename), nil
+}
@@ -11,4 +11,6 @@ type FileStorage interface {
 	Save(ctx context.Context, file io.Reader, filename string) (string, error)
 	// Delete removes a file given its public-facing URL.
 	Delete(ctx context.Context, url string) error
+	// Open returns the contents of a file given its public-facing URL.
+	Open(ctx context.Context, url string) (io.ReadCloser, error)
 }
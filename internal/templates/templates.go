@@ -0,0 +1,43 @@
+// Package templates holds the catalogue of curated habit templates new users can
+// adopt. The built-in catalogue is embedded in the binary; self-hosters can replace
+// it with a JSON file of the same shape.
+package templates
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/axseem/peakstreak/internal/domain"
+)
+
+//go:embed catalogue.json
+var builtin []byte
+
+// Load reads the catalogue from the JSON file at path, or the built-in one if path
+// is empty.
+func Load(path string) ([]domain.HabitTemplate, error) {
+	data := builtin
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("read habit templates: %w", err)
+		}
+	}
+	return Parse(data)
+}
+
+// Parse decodes a catalogue, a JSON array of templates. Unknown fields are rejected
+// so typos in a custom catalogue do not go unnoticed.
+func Parse(data []byte) ([]domain.HabitTemplate, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var catalogue []domain.HabitTemplate
+	if err := dec.Decode(&catalogue); err != nil {
+		return nil, fmt.Errorf("parse habit templates: %w", err)
+	}
+	return catalogue, nil
+}
@@ -0,0 +1,4 @@
+ALTER TABLE habits
+    DROP COLUMN IF EXISTS schedule_times,
+    DROP COLUMN IF EXISTS schedule_weekdays,
+    DROP COLUMN IF EXISTS schedule_frequency;
@@ -0,0 +1,5 @@
+ALTER TABLE habits
+    ADD COLUMN schedule_frequency VARCHAR(16) NOT NULL DEFAULT 'daily'
+        CHECK (schedule_frequency IN ('daily', 'weekdays', 'weekly', 'monthly')),
+    ADD COLUMN schedule_weekdays SMALLINT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN schedule_times SMALLINT NOT NULL DEFAULT 0;
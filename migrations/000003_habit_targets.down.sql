@@ -0,0 +1,3 @@
+ALTER TABLE habits
+    DROP COLUMN IF EXISTS target_mode,
+    DROP COLUMN IF EXISTS target_value;
@@ -0,0 +1,4 @@
+ALTER TABLE habits
+    ADD COLUMN target_value INTEGER CHECK (target_value >= 0),
+    ADD COLUMN target_mode VARCHAR(16) NOT NULL DEFAULT 'at_least'
+        CHECK (target_mode IN ('at_least', 'at_most', 'exactly'));
@@ -0,0 +1,7 @@
+DELETE FROM habits WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_habits_deleted_at;
+
+ALTER TABLE habits
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS archived_at;
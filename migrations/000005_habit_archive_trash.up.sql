@@ -0,0 +1,5 @@
+ALTER TABLE habits
+    ADD COLUMN archived_at TIMESTAMPTZ,
+    ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_habits_deleted_at ON habits (deleted_at) WHERE deleted_at IS NOT NULL;
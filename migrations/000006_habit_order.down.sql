@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_habits_user_id_position;
+
+ALTER TABLE habits
+    DROP COLUMN IF EXISTS pinned,
+    DROP COLUMN IF EXISTS position;
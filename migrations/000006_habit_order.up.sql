@@ -0,0 +1,14 @@
+ALTER TABLE habits
+    ADD COLUMN position INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Keep the previous newest-first order as the starting arrangement.
+UPDATE habits h
+SET position = ordered.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at DESC) - 1 AS position
+    FROM habits
+) ordered
+WHERE h.id = ordered.id;
+
+CREATE INDEX idx_habits_user_id_position ON habits (user_id, pinned DESC, position);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_habits_category;
+
+ALTER TABLE habits DROP COLUMN IF EXISTS category;
+
+DROP TABLE IF EXISTS habit_tags;
+DROP TABLE IF EXISTS tags;
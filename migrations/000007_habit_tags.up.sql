@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(32) NOT NULL,
+    color_hue SMALLINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_tags_user_id_name ON tags (user_id, lower(name));
+
+CREATE TABLE IF NOT EXISTS habit_tags (
+    habit_id UUID NOT NULL REFERENCES habits(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (habit_id, tag_id)
+);
+
+CREATE INDEX idx_habit_tags_tag_id ON habit_tags (tag_id);
+
+ALTER TABLE habits
+    ADD COLUMN category VARCHAR(32)
+        CHECK (category IN ('fitness', 'health', 'learning', 'mindfulness', 'productivity', 'creativity', 'finance', 'social'));
+
+CREATE INDEX idx_habits_category ON habits (category) WHERE category IS NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE habit_logs
+    DROP COLUMN IF EXISTS note_private,
+    DROP COLUMN IF EXISTS note;
@@ -0,0 +1,3 @@
+ALTER TABLE habit_logs
+    ADD COLUMN note TEXT CHECK (char_length(note) <= 500),
+    ADD COLUMN note_private BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,4 @@
+DELETE FROM habit_logs WHERE status = 'skipped';
+UPDATE habit_logs SET value = 0 WHERE status = 'failed';
+
+ALTER TABLE habit_logs DROP COLUMN IF EXISTS status;
@@ -0,0 +1,9 @@
+ALTER TABLE habit_logs
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'done'
+        CHECK (status IN ('done', 'skipped', 'failed'));
+
+-- Zero-valued rows were how logs used to be cleared. Drop them, unless they carry a
+-- note, in which case they are kept as done logs of zero: like the cleared days they
+-- were, they neither complete a habit nor break a streak.
+DELETE FROM habit_logs WHERE value <= 0 AND note IS NULL;
+UPDATE habit_logs SET value = 0 WHERE value < 0;
@@ -0,0 +1,3 @@
+ALTER TABLE habits DROP COLUMN IF EXISTS unit;
+ALTER TABLE habits ALTER COLUMN target_value TYPE INTEGER USING round(target_value);
+ALTER TABLE habit_logs ALTER COLUMN value TYPE INTEGER USING round(value);
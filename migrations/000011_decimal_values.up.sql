@@ -0,0 +1,4 @@
+-- NUMERIC(15, 3) holds every INTEGER exactly, so existing values convert losslessly.
+ALTER TABLE habit_logs ALTER COLUMN value TYPE NUMERIC(15, 3);
+ALTER TABLE habits ALTER COLUMN target_value TYPE NUMERIC(15, 3);
+ALTER TABLE habits ADD COLUMN unit VARCHAR(16);
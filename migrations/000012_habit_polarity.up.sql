@@ -0,0 +1,3 @@
+ALTER TABLE habits
+    ADD COLUMN polarity VARCHAR(16) NOT NULL DEFAULT 'positive'
+        CHECK (polarity IN ('positive', 'negative'));
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS habit_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- A habit can be published once; publishing it again updates its template.
+    habit_id UUID UNIQUE REFERENCES habits(id) ON DELETE SET NULL,
+    name VARCHAR(100) NOT NULL,
+    description VARCHAR(280),
+    color_hue SMALLINT NOT NULL DEFAULT 0,
+    is_boolean BOOLEAN NOT NULL DEFAULT TRUE,
+    polarity VARCHAR(16) NOT NULL DEFAULT 'positive'
+        CHECK (polarity IN ('positive', 'negative')),
+    schedule_frequency VARCHAR(16) NOT NULL DEFAULT 'daily'
+        CHECK (schedule_frequency IN ('daily', 'weekdays', 'weekly', 'monthly')),
+    schedule_weekdays SMALLINT[] NOT NULL DEFAULT '{}',
+    schedule_times SMALLINT NOT NULL DEFAULT 0,
+    target_value NUMERIC(15, 3) CHECK (target_value >= 0),
+    target_mode VARCHAR(16) NOT NULL DEFAULT 'at_least'
+        CHECK (target_mode IN ('at_least', 'at_most', 'exactly')),
+    unit VARCHAR(16),
+    category VARCHAR(32)
+        CHECK (category IN ('fitness', 'health', 'learning', 'mindfulness', 'productivity', 'creativity', 'finance', 'social')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_habit_templates_user_id ON habit_templates (user_id);
+CREATE INDEX idx_habit_templates_created_at ON habit_templates (created_at DESC);
@@ -0,0 +1,3 @@
+ALTER TABLE habits ADD COLUMN source_habit_id UUID REFERENCES habits(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_habits_source_habit_id ON habits (source_habit_id) WHERE source_habit_id IS NOT NULL;
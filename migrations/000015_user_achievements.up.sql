@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS user_achievements (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    achievement VARCHAR(32) NOT NULL,
+    earned_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, achievement)
+);
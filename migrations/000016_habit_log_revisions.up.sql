@@ -0,0 +1,17 @@
+-- Every create, change and delete of a log. A missing old state means the log was
+-- created, a missing new state that it was deleted.
+CREATE TABLE IF NOT EXISTS habit_log_revisions (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    habit_id UUID NOT NULL REFERENCES habits(id) ON DELETE CASCADE,
+    log_date DATE NOT NULL,
+    old_value NUMERIC(15, 3),
+    old_status VARCHAR(16),
+    new_value NUMERIC(15, 3),
+    new_status VARCHAR(16),
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    -- undoes is the revision this one reverted. A revision can be undone only once.
+    undoes BIGINT UNIQUE REFERENCES habit_log_revisions(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_habit_log_revisions_day ON habit_log_revisions (habit_id, log_date, id);
@@ -0,0 +1,3 @@
+-- Access tokens carry the generation they were issued in; bumping it, as changing the
+-- password does, invalidates every token issued before.
+ALTER TABLE users ADD COLUMN token_generation INTEGER NOT NULL DEFAULT 0;
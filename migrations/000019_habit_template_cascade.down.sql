@@ -0,0 +1,3 @@
+ALTER TABLE habit_templates DROP CONSTRAINT habit_templates_habit_id_fkey;
+ALTER TABLE habit_templates ADD CONSTRAINT habit_templates_habit_id_fkey
+    FOREIGN KEY (habit_id) REFERENCES habits(id) ON DELETE SET NULL;
@@ -0,0 +1,6 @@
+-- A published template goes when its habit is purged. Templates left behind by habits
+-- purged before now are removed too.
+DELETE FROM habit_templates WHERE habit_id IS NULL;
+ALTER TABLE habit_templates DROP CONSTRAINT habit_templates_habit_id_fkey;
+ALTER TABLE habit_templates ADD CONSTRAINT habit_templates_habit_id_fkey
+    FOREIGN KEY (habit_id) REFERENCES habits(id) ON DELETE CASCADE;